@@ -2,24 +2,44 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	osSignal "os/signal"
+	"slices"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/dedupe"
 	"github.com/user/mexc-bot/internal/engine"
 	"github.com/user/mexc-bot/internal/exchange"
 	"github.com/user/mexc-bot/internal/exchange/mexc"
+	"github.com/user/mexc-bot/internal/metrics"
+	"github.com/user/mexc-bot/internal/notify"
+	"github.com/user/mexc-bot/internal/position"
 	"github.com/user/mexc-bot/internal/risk"
 	signalpkg "github.com/user/mexc-bot/internal/signal"
+	"github.com/user/mexc-bot/internal/source"
+	"github.com/user/mexc-bot/internal/state"
 	"github.com/user/mexc-bot/internal/telegram"
+	"github.com/user/mexc-bot/internal/telegram/botapi"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "parse":
+			os.Exit(runParse(os.Args[2:]))
+		case "telegram":
+			os.Exit(runTelegram(os.Args[2:]))
+		}
+	}
+
 	var configPath string
 	flag.StringVar(&configPath, "config", "config/example.toml", "path to config file")
 	flag.Parse()
@@ -34,11 +54,78 @@ func main() {
 	logger.Info("configuration loaded", "path", cfg.ConfigPath, "environment", cfg.Mode.Environment, "market_type", cfg.Mode.MarketType, "dry_run", cfg.Debug.DryRun)
 
 	parser := signalpkg.NewParser(cfg.Parser)
-	riskManager := risk.NewSimpleManager(logger, cfg.Risk)
 
-	var executor exchange.Executor
+	var store *state.Store
+	if strings.TrimSpace(cfg.Infra.StatePath) != "" {
+		store, err = state.Open(cfg.Infra.StatePath)
+		if err != nil {
+			logger.Error("open state store", "error", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+	}
+
+	var positionStore position.Store
+	if store != nil {
+		positionStore = store
+	}
+	book, err := position.NewBook(positionStore)
+	if err != nil {
+		logger.Error("restore positions", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("positions restored", "open", len(book.OpenPositions()))
+
+	var (
+		riskManager risk.Manager
+		redisRisk   *risk.RedisManager
+	)
+	switch cfg.Risk.Backend {
+	case "redis":
+		redisRisk, err = risk.NewRedisManager(context.Background(), cfg, logger)
+		if err != nil {
+			logger.Error("initialise redis risk manager", "error", err)
+			os.Exit(1)
+		}
+		defer redisRisk.Close()
+		// Positions from the previous run still occupy max_open_positions slots.
+		open := make(map[string]int)
+		for _, p := range book.OpenPositions() {
+			if !p.Adopted {
+				open[p.Symbol]++
+			}
+		}
+		if err := redisRisk.Restore(context.Background(), open); err != nil {
+			logger.Error("restore redis open positions", "error", err)
+			os.Exit(1)
+		}
+		riskManager = redisRisk
+	case "local":
+		localRisk, err := risk.NewPersistentManager(logger, cfg.Risk, store)
+		if err != nil {
+			logger.Error("initialise local risk manager", "error", err)
+			os.Exit(1)
+		}
+		riskManager = localRisk
+	default:
+		riskManager = risk.NewSimpleManager(logger, cfg.Risk)
+	}
+
+	var (
+		executor exchange.Executor
+		account  exchange.AccountReader
+		prices   exchange.PriceFeed
+		listing  symbolLister
+	)
 	if cfg.Debug.DryRun {
 		executor = exchange.NewDryRunExecutor(logger)
+		marketData, err := mexc.NewMarketData(cfg)
+		if err != nil {
+			logger.Error("initialise mexc market data", "error", err)
+			os.Exit(1)
+		}
+		prices = marketData
+		listing = marketData
 	} else {
 		apiKey, err := cfg.Auth.APIKey.Resolve()
 		if err != nil {
@@ -56,46 +143,228 @@ func main() {
 			os.Exit(1)
 		}
 		executor = mexcExec
+		account = mexcExec
+		prices = mexcExec
+		listing = mexcExec
+	}
+
+	// Spot is always available; futures links only trade when a futures executor
+	// is registered. Dry-run simulates both.
+	router := exchange.NewRouter(executor)
+	if cfg.Debug.DryRun {
+		router.Register(exchange.MarketFutures, executor)
+	} else {
+		logger.Info("no futures executor configured; futures signals will be skipped")
 	}
+	executor = router
 
-	core, err := engine.New(cfg, parser, riskManager, executor, logger)
+	positions, err := position.NewManager(book, executor, account, riskManager, cfg.Trading.QuoteAsset, logger)
 	if err != nil {
-		logger.Error("initialise engine", "error", err)
+		logger.Error("initialise position manager", "error", err)
+		os.Exit(1)
+	}
+	if lots, ok := prices.(exchange.LotSizer); ok {
+		positions.SetLotSizer(lots)
+	}
+	monitor, err := position.NewMonitor(positions, prices, position.RulesFromConfig(cfg.Risk, cfg.PnLExit), time.Duration(cfg.PnLExit.PollIntervalMS)*time.Millisecond, logger)
+	if err != nil {
+		logger.Error("initialise exit monitor", "error", err)
 		os.Exit(1)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	go listenForShutdown(cancel)
-
-	msgCh := make(chan signalpkg.Message, 64)
+	var (
+		sources   []source.Source
+		listeners []*telegram.Listener
+	)
 	if cfg.Telegram.Enabled {
-		apiHashBytes, err := cfg.Telegram.APIHash.Resolve()
+		for _, account := range cfg.TelegramAccounts() {
+			accountLogger := logger.With("account", account.Telegram.Account)
+			apiHashBytes, err := account.Telegram.APIHash.Resolve()
+			if err != nil {
+				accountLogger.Error("resolve telegram api_hash", "error", err)
+				os.Exit(1)
+			}
+			storage, err := telegram.OpenSessionStorage(account.Telegram, account.Auth)
+			if err != nil {
+				accountLogger.Error("open telegram session storage", "error", err)
+				os.Exit(1)
+			}
+			if !storage.Encrypted() {
+				accountLogger.Warn("telegram session is stored unencrypted; set auth.telegram_session_passphrase", "path", storage.Path())
+			}
+			tgListener, err := telegram.NewListener(account.Telegram, strings.TrimSpace(string(apiHashBytes)), storage, accountLogger)
+			if err != nil {
+				accountLogger.Error("initialise telegram listener", "error", err)
+				os.Exit(1)
+			}
+			defer tgListener.Close()
+			listeners = append(listeners, tgListener)
+		}
+		sources = append(sources, telegram.NewGroup(logger, listeners...))
+	}
+	if cfg.Telegram.Bot.Enabled {
+		token, err := cfg.Telegram.Bot.Token.Resolve()
+		if err != nil {
+			logger.Error("resolve telegram bot token", "error", err)
+			os.Exit(1)
+		}
+		poller, err := botapi.NewPoller(cfg.Telegram.Bot, string(token), logger.With("source", "bot-api"))
+		if err != nil {
+			logger.Error("initialise telegram bot source", "error", err)
+			os.Exit(1)
+		}
+		sources = append(sources, poller)
+	}
+	if cfg.Ingest.Webhook.Enabled {
+		secret, err := cfg.Ingest.Webhook.Secret.Resolve()
+		if err != nil {
+			logger.Error("resolve webhook secret", "error", err)
+			os.Exit(1)
+		}
+		webhook, err := source.NewWebhook(cfg.Ingest.Webhook, []byte(strings.TrimSpace(string(secret))), logger.With("source", "webhook"))
+		if err != nil {
+			logger.Error("initialise webhook source", "error", err)
+			os.Exit(1)
+		}
+		sources = append(sources, webhook)
+	}
+	if cfg.Ingest.File.Enabled {
+		file, err := source.NewFile(cfg.Ingest.File, logger.With("source", "file"))
 		if err != nil {
-			logger.Error("resolve telegram api_hash", "error", err)
+			logger.Error("initialise file source", "error", err)
 			os.Exit(1)
 		}
-		tgListener, err := telegram.NewListener(cfg.Telegram, strings.TrimSpace(string(apiHashBytes)), logger)
+		sources = append(sources, file)
+	}
+	if len(sources) == 0 {
+		logger.Warn("no ingestion sources enabled; only exits of restored positions will run")
+	}
+
+	var notifier *notify.Notifier
+	if cfg.Notify.Enabled {
+		sendVia := listeners[0]
+		for _, l := range listeners {
+			if l.Account() == cfg.Notify.Account {
+				sendVia = l
+			}
+		}
+		sender, err := sendVia.Sender(cfg.Notify.Chat)
 		if err != nil {
-			logger.Error("initialise telegram listener", "error", err)
+			logger.Error("initialise notify chat", "error", err)
+			os.Exit(1)
+		}
+		notifier, err = notify.New(cfg.Notify, sender, logger.With("component", "notify", "account", sendVia.Account()))
+		if err != nil {
+			logger.Error("initialise notifier", "error", err)
+			os.Exit(1)
+		}
+		positions.SetNotifier(notifier)
+	}
+
+	dedupeCache := dedupe.New(time.Duration(cfg.Dedupe.TTLSeconds)*time.Second, cfg.Dedupe.MaxEntries, dedupe.EditPolicy(cfg.Dedupe.EditPolicy))
+
+	engineOpts := []engine.Option{engine.WithPositions(book), engine.WithExits(positions, prices), engine.WithDedupe(dedupeCache)}
+	if notifier != nil {
+		engineOpts = append(engineOpts, engine.WithNotifier(notifier))
+	}
+	for _, link := range cfg.Parser.Links {
+		if link.Short {
+			engineOpts = append(engineOpts, engine.WithLinkExpander(signalpkg.NewRedirectExpander(2*time.Second)))
+			break
+		}
+	}
+	var symbols *signalpkg.SymbolSet
+	if usesTagSource(cfg.Parser) {
+		symbols = signalpkg.NewSymbolSet(nil)
+		if err := refreshSymbols(context.Background(), listing, symbols); err != nil {
+			logger.Error("load exchange symbol list for tag fallback", "error", err)
 			os.Exit(1)
 		}
+		logger.Info("exchange symbol list loaded", "symbols", symbols.Len())
+		engineOpts = append(engineOpts, engine.WithSymbols(symbols))
+	}
 
+	core, err := engine.New(cfg, parser, riskManager, executor, logger, engineOpts...)
+	if err != nil {
+		logger.Error("initialise engine", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go listenForShutdown(cancel)
+
+	if redisRisk != nil {
 		go func() {
-			if err := tgListener.Run(ctx, msgCh); err != nil && ctx.Err() == nil {
-				logger.Error("telegram listener stopped", "error", err)
-				cancel()
+			if err := redisRisk.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("redis risk lease stopped", "error", err)
 			}
-			close(msgCh)
 		}()
+	}
+
+	if symbols != nil {
+		go runSymbolRefresh(ctx, listing, symbols, time.Hour, logger)
+	}
+
+	if retention := cfg.Infra.ClosedRetention(); store != nil && retention > 0 {
+		go runStatePrune(ctx, store, retention, 6*time.Hour, logger)
+	}
+
+	if cfg.Telemetry.MetricsPush && cfg.Telemetry.MetricsEndpoint != "" {
+		go metrics.Default.RunPusher(ctx, cfg.Telemetry.MetricsEndpoint, "mexc_bot", 10*time.Second, logger)
+	}
+
+	// The exit monitor acts on the book, so it only starts once the book has
+	// been checked against the account.
+	startMonitor := sync.OnceFunc(func() {
+		go func() {
+			if err := monitor.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("exit monitor stopped", "error", err)
+			}
+		}()
+	})
+	if account != nil {
+		reconciler, err := position.NewReconciler(positions, account, prices, cfg.Reconcile, logger)
+		if err != nil {
+			logger.Error("initialise reconciler", "error", err)
+			os.Exit(1)
+		}
+		if cfg.Reconcile.OnBoot {
+			if _, err := reconciler.Reconcile(ctx); err != nil {
+				logger.Error("boot reconciliation failed; refusing to resume exits on unverified positions", "error", err)
+				os.Exit(1)
+			}
+			startMonitor()
+		} else {
+			logger.Warn("exit monitor paused until a reconciliation succeeds; send SIGUSR1 to reconcile")
+		}
+		go listenForReconcile(ctx, reconciler, startMonitor, logger)
+	} else if restored := len(book.OpenPositions()); restored == 0 {
+		// Nothing to verify: every position the monitor will see is opened by this run.
+		startMonitor()
 	} else {
+		logger.Warn("exit monitor disabled: restored positions cannot be reconciled without account access", "open", restored)
+	}
+
+	if notifier != nil {
 		go func() {
-			<-ctx.Done()
-			close(msgCh)
+			if err := notifier.Run(ctx); err != nil && ctx.Err() == nil {
+				logger.Error("notifier stopped", "error", err)
+			}
 		}()
 	}
 
+	// RunAll closes msgCh once every source has returned; a failing source
+	// stops the bot.
+	msgCh := make(chan signalpkg.Message, 64)
+	go func() {
+		if err := source.RunAll(ctx, msgCh, logger, sources...); err != nil && ctx.Err() == nil {
+			logger.Error("ingestion source stopped", "error", err)
+			cancel()
+		}
+	}()
+
 	go func() {
 		for {
 			select {
@@ -139,6 +408,27 @@ func newLogger(level string) *slog.Logger {
 	return slog.New(handler)
 }
 
+// listenForReconcile re-runs reconciliation whenever the process receives
+// SIGUSR1, calling reconciled after every pass that succeeds.
+func listenForReconcile(ctx context.Context, reconciler *position.Reconciler, reconciled func(), logger *slog.Logger) {
+	sigCh := make(chan os.Signal, 1)
+	osSignal.Notify(sigCh, syscall.SIGUSR1)
+	defer osSignal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			if _, err := reconciler.Reconcile(ctx); err != nil {
+				logger.Error("on-demand reconciliation failed", "error", err)
+				continue
+			}
+			reconciled()
+		}
+	}
+}
+
 func listenForShutdown(cancel context.CancelFunc) {
 	sigCh := make(chan os.Signal, 1)
 	osSignal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -146,3 +436,73 @@ func listenForShutdown(cancel context.CancelFunc) {
 	<-sigCh
 	cancel()
 }
+
+// symbolLister is satisfied by the MEXC market data client and executor.
+type symbolLister interface {
+	Symbols(ctx context.Context) ([]string, error)
+}
+
+// usesTagSource reports whether any parser template can resolve symbols from tags.
+func usesTagSource(cfg config.ParserConfig) bool {
+	if cfg.TagFallback {
+		return true
+	}
+	for _, tpl := range cfg.Templates {
+		if slices.Contains(tpl.SymbolFrom, "tags") {
+			return true
+		}
+	}
+	return false
+}
+
+func refreshSymbols(ctx context.Context, listing symbolLister, set *signalpkg.SymbolSet) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	symbols, err := listing.Symbols(ctx)
+	if err != nil {
+		return err
+	}
+	if len(symbols) == 0 {
+		return errors.New("exchange returned no tradable symbols")
+	}
+	set.Replace(symbols)
+	return nil
+}
+
+// runStatePrune drops closed positions older than retention from the state
+// store, once at startup and then every interval.
+func runStatePrune(ctx context.Context, store *state.Store, retention, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if pruned, err := store.PruneClosed(time.Now().Add(-retention)); err != nil {
+			logger.Warn("prune closed positions", "error", err)
+		} else if pruned > 0 {
+			logger.Info("closed positions pruned", "count", pruned, "retention", retention)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runSymbolRefresh keeps the tag fallback's symbol list current so new listings
+// become tradable; on failure the previous list stays in place.
+func runSymbolRefresh(ctx context.Context, listing symbolLister, set *signalpkg.SymbolSet, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := refreshSymbols(ctx, listing, set); err != nil {
+				logger.Warn("refresh exchange symbol list", "error", err)
+				continue
+			}
+			logger.Debug("exchange symbol list refreshed", "symbols", set.Len())
+		}
+	}
+}
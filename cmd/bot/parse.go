@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+// runParse implements "bot parse": it runs the configured parser over sample
+// messages and explains each verdict. It exits 1 when any message is rejected
+// and 2 on usage or input errors.
+func runParse(args []string) int {
+	fs := flag.NewFlagSet("parse", flag.ContinueOnError)
+	configPath := fs.String("config", "config/example.toml", "path to config file")
+	chat := fs.String("chat", "", "apply the [channels.<key>] profile for this chat (<id> for a channel, or <type>:<id>)")
+	format := fs.String("format", "auto", "input format: text, jsonl (replay of signal.Message), export (Telegram Desktop result.json) or auto")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: bot parse [flags] [file]\n\nReads message text from file, or stdin when no file is given.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+		return 2
+	}
+
+	var (
+		input io.Reader = os.Stdin
+		name            = "stdin"
+	)
+	if fs.NArg() > 0 {
+		name = fs.Arg(0)
+		f, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "open input: %v\n", err)
+			return 2
+		}
+		defer f.Close()
+		input = f
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read input: %v\n", err)
+		return 2
+	}
+
+	messages, err := decodeMessages(data, resolveFormat(*format, name, data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decode %s: %v\n", name, err)
+		return 2
+	}
+	if len(messages) == 0 {
+		fmt.Fprintln(os.Stderr, "no messages in input")
+		return 2
+	}
+
+	parserCfg := cfg.Parser
+	var chatType string
+	var chatID int64
+	if *chat != "" {
+		if chatType, chatID, err = config.ChannelKey(*chat); err != nil {
+			fmt.Fprintf(os.Stderr, "-chat: %v\n", err)
+			return 2
+		}
+		for key, ch := range cfg.Channels {
+			if t, id, _ := config.ChannelKey(key); t == chatType && id == chatID {
+				parserCfg = ch.ParserConfig(cfg.Parser)
+			}
+		}
+	}
+	parser := signalpkg.NewParser(parserCfg)
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	ctx := context.Background()
+	rejected := 0
+	for i, msg := range messages {
+		if chatID != 0 && msg.ChatID == 0 {
+			msg.ChatID, msg.ChatType = chatID, chatType
+		}
+		ex := parser.Explain(ctx, msg)
+		if ex.Err != nil {
+			rejected++
+		}
+		writeExplanation(w, i+1, msg, ex)
+	}
+	if len(messages) > 1 {
+		fmt.Fprintf(w, "%d message(s), %d accepted, %d rejected\n", len(messages), len(messages)-rejected, rejected)
+	}
+	if rejected > 0 {
+		return 1
+	}
+	return 0
+}
+
+func resolveFormat(format, name string, data []byte) string {
+	if format != "auto" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl":
+		return "jsonl"
+	case ".json":
+		return "export"
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		if bytes.Contains(trimmed, []byte(`"messages"`)) {
+			return "export"
+		}
+		return "jsonl"
+	}
+	return "text"
+}
+
+func decodeMessages(data []byte, format string) ([]signalpkg.Message, error) {
+	switch format {
+	case "text":
+		return []signalpkg.Message{{Text: string(data), Timestamp: time.Now()}}, nil
+	case "jsonl":
+		var out []signalpkg.Message
+		for i, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var msg signalpkg.Message
+			if err := json.Unmarshal(line, &msg); err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			out = append(out, msg)
+		}
+		return out, nil
+	case "export":
+		return decodeExport(data)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// exportChat is the subset of a Telegram Desktop chat export (result.json) the
+// parser needs.
+type exportChat struct {
+	ID       int64           `json:"id"`
+	Name     string          `json:"name"`
+	Messages []exportMessage `json:"messages"`
+}
+
+type exportMessage struct {
+	ID           int64            `json:"id"`
+	Type         string           `json:"type"`
+	DateUnix     string           `json:"date_unixtime"`
+	From         string           `json:"from"`
+	Text         json.RawMessage  `json:"text"`
+	InlineButton [][]exportButton `json:"inline_bot_buttons"`
+}
+
+// exportText is one element of a formatted export text array.
+type exportText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	Href string `json:"href"`
+}
+
+type exportButton struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+	URL  string `json:"url"`
+	Data string `json:"data"`
+}
+
+func decodeExport(data []byte) ([]signalpkg.Message, error) {
+	var chat exportChat
+	if err := json.Unmarshal(data, &chat); err != nil {
+		return nil, err
+	}
+	out := make([]signalpkg.Message, 0, len(chat.Messages))
+	for _, m := range chat.Messages {
+		if m.Type != "" && m.Type != "message" {
+			continue
+		}
+		msg := signalpkg.Message{ID: m.ID, ChatID: chat.ID, ChatTitle: chat.Name, SenderName: m.From}
+		if ts, err := strconv.ParseInt(m.DateUnix, 10, 64); err == nil {
+			msg.Timestamp = time.Unix(ts, 0)
+		}
+		text, links, err := exportTextAndLinks(m.Text)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", m.ID, err)
+		}
+		msg.Text, msg.Links = text, links
+		for _, row := range m.InlineButton {
+			for _, b := range row {
+				target := b.URL
+				if target == "" {
+					target = b.Data
+				}
+				if strings.Contains(target, "://") {
+					msg.Links = append(msg.Links, signalpkg.Link{URL: target, Kind: "button", Label: b.Text})
+				}
+			}
+		}
+		if strings.TrimSpace(msg.Text) == "" && len(msg.Links) == 0 {
+			continue
+		}
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+// exportTextAndLinks flattens an export "text" field, which is either a string
+// or an array of strings and formatted parts.
+func exportTextAndLinks(raw json.RawMessage) (string, []signalpkg.Link, error) {
+	if len(raw) == 0 {
+		return "", nil, nil
+	}
+	var plain string
+	if err := json.Unmarshal(raw, &plain); err == nil {
+		return plain, nil, nil
+	}
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return "", nil, errors.New("text is neither a string nor an array")
+	}
+	var (
+		b     strings.Builder
+		links []signalpkg.Link
+	)
+	for _, part := range parts {
+		if err := json.Unmarshal(part, &plain); err == nil {
+			b.WriteString(plain)
+			continue
+		}
+		var ft exportText
+		if err := json.Unmarshal(part, &ft); err != nil {
+			return "", nil, err
+		}
+		b.WriteString(ft.Text)
+		if ft.Type == "text_link" && ft.Href != "" {
+			links = append(links, signalpkg.Link{URL: ft.Href, Kind: "text_url", Label: ft.Text})
+		}
+	}
+	return b.String(), links, nil
+}
+
+func writeExplanation(w io.Writer, n int, msg signalpkg.Message, ex signalpkg.Explanation) {
+	fmt.Fprintf(w, "message %d", n)
+	if msg.ID != 0 || msg.ChatID != 0 {
+		fmt.Fprintf(w, " (chat %d, id %d)", msg.ChatID, msg.ID)
+	}
+	fmt.Fprintln(w)
+	if ex.Normalized != msg.Text {
+		fmt.Fprintf(w, "  normalised: %q\n", ex.Normalized)
+	}
+
+	for _, tr := range ex.Templates {
+		fmt.Fprintf(w, "  template %s [%s]\n", tr.Name, tr.Kind)
+		for _, tok := range tr.Tokens {
+			fmt.Fprintf(w, "    %-9s %-24q %s\n", tok.Rule, tok.Token, foundLabel(tok.Found))
+		}
+		if tr.Pattern != "" {
+			fmt.Fprintf(w, "    pattern   %q", tr.Pattern)
+			if len(tr.Captures) > 0 {
+				fmt.Fprintf(w, " captures %v", tr.Captures)
+			}
+			fmt.Fprintln(w)
+		}
+		for _, src := range tr.Sources {
+			if src.Err != nil {
+				fmt.Fprintf(w, "    symbol    %-9s failed: %v\n", src.Source, src.Err)
+				continue
+			}
+			fmt.Fprintf(w, "    symbol    %-9s %s (%s)\n", src.Source, src.PairCode, src.Market)
+		}
+		if tr.Err != nil {
+			fmt.Fprintf(w, "    -> rejected: %v\n", tr.Err)
+		} else {
+			fmt.Fprintln(w, "    -> matched")
+		}
+	}
+
+	if len(ex.Links) > 0 {
+		fmt.Fprintln(w, "  link candidates")
+		for _, lt := range ex.Links {
+			if lt.Err != nil {
+				fmt.Fprintf(w, "    %s: %v\n", lt.URL, lt.Err)
+				continue
+			}
+			fmt.Fprintf(w, "    %s: %s (%s)\n", lt.URL, lt.PairCode, lt.Market)
+		}
+	}
+
+	if ex.Err != nil {
+		fmt.Fprintf(w, "  verdict: REJECT %v\n\n", ex.Err)
+		return
+	}
+	sig := ex.Signal
+	fmt.Fprintf(w, "  verdict: ACCEPT %s %s", sig.Kind, orDash(sig.Symbol))
+	fmt.Fprintf(w, " market=%s template=%s symbol_from=%s confidence=%.2f", sig.Market, sig.Template, orDash(sig.SymbolFrom), sig.Confidence)
+	if sig.CloseFraction > 0 {
+		fmt.Fprintf(w, " close_fraction=%.2f", sig.CloseFraction)
+	}
+	if len(sig.Targets) > 0 || sig.Entry != nil || sig.Stop != nil {
+		fmt.Fprintf(w, " targets=%v entry=%s stop=%s", sig.Targets, orDash(entryString(sig.Entry)), orDash(levelString(sig.Stop)))
+	}
+	fmt.Fprint(w, "\n\n")
+}
+
+func foundLabel(found bool) string {
+	if found {
+		return "found"
+	}
+	return "absent"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func entryString(e *signalpkg.EntryRange) string {
+	if e == nil {
+		return ""
+	}
+	return e.String()
+}
+
+func levelString(l *signalpkg.Level) string {
+	if l == nil {
+		return ""
+	}
+	return l.String()
+}
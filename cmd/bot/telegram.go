@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	osSignal "os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/telegram"
+)
+
+// runTelegram dispatches the "bot telegram <command>" session tools.
+func runTelegram(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: bot telegram login|import-session [flags]")
+		return 2
+	}
+	switch args[0] {
+	case "login":
+		return runTelegramLogin(args[1:])
+	case "import-session":
+		return runTelegramImport(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown telegram command %q\n", args[0])
+		return 2
+	}
+}
+
+// runTelegramLogin signs in interactively with the account's api_id/api_hash
+// and writes the session to its session_storage_path.
+func runTelegramLogin(args []string) int {
+	fs := flag.NewFlagSet("telegram login", flag.ContinueOnError)
+	configPath := fs.String("config", "config/example.toml", "path to config file")
+	accountName := fs.String("account", "", "[[telegram.accounts]] name (required when several are configured)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+		return 2
+	}
+	account, err := cfg.TelegramAccount(*accountName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	apiHash, err := account.Telegram.APIHash.Resolve()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "resolve telegram api_hash: %v\n", err)
+		return 2
+	}
+
+	storage, err := telegram.OpenSessionStorage(account.Telegram, account.Auth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open telegram session storage: %v\n", err)
+		return 2
+	}
+
+	ctx, stop := osSignal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := telegram.Login(ctx, account.Telegram, strings.TrimSpace(string(apiHash)), storage, os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "telegram login: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runTelegramImport converts a Telethon .session file (or StringSession text)
+// or a TDesktop tdata directory into the gotd session file the listener loads.
+func runTelegramImport(args []string) int {
+	fs := flag.NewFlagSet("telegram import-session", flag.ContinueOnError)
+	configPath := fs.String("config", "config/example.toml", "path to config file")
+	from := fs.String("from", "", "Telethon .session file, StringSession text file or TDesktop tdata directory")
+	out := fs.String("out", "", "destination session file (default: the account's session_storage_path, encrypted with its passphrase)")
+	accountName := fs.String("account", "", "[[telegram.accounts]] name to import into (required when several are configured)")
+	tdataAccount := fs.Int("tdata-account", 0, "TDesktop account index when tdata holds several")
+	passcodeEnv := fs.String("passcode-env", "", "environment variable holding the TDesktop local passcode")
+	force := fs.Bool("force", false, "overwrite an existing session file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" {
+		fmt.Fprintln(os.Stderr, "telegram import-session: -from is required")
+		return 2
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "load config: %v\n", err)
+		return 2
+	}
+	account, err := cfg.TelegramAccount(*accountName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *out != "" {
+		account.Telegram.SessionStoragePath = *out
+	}
+	storage, err := telegram.OpenSessionStorage(account.Telegram, account.Auth)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open telegram session storage: %v\n", err)
+		return 2
+	}
+
+	opts := telegram.ImportOptions{Account: *tdataAccount, Force: *force}
+	if *passcodeEnv != "" {
+		opts.Passcode = []byte(os.Getenv(*passcodeEnv))
+	}
+
+	res, err := telegram.ImportSession(context.Background(), *from, storage, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "telegram import-session: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Imported %s session (DC %d, %s) to %s", res.Format, res.DC, res.Addr, storage.Path())
+	if storage.Encrypted() {
+		fmt.Print(" (encrypted)")
+	}
+	fmt.Println(".")
+	return 0
+}
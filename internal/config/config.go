@@ -3,28 +3,38 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"github.com/pelletier/go-toml/v2"
 )
 
 // Config captures runtime configuration for the trading bot.
 type Config struct {
-	Mode       ModeConfig             `toml:"mode"`
-	Auth       AuthConfig             `toml:"auth"`
-	Trading    TradingConfig          `toml:"trading"`
-	Parser     ParserConfig           `toml:"parser"`
-	Telegram   TelegramConfig         `toml:"telegram"`
-	Risk       RiskConfig             `toml:"risk"`
-	PnLExit    PnLExitConfig          `toml:"pnl_exit"`
-	Latency    LatencyBudget          `toml:"latency"`
-	Telemetry  TelemetryConfig        `toml:"telemetry"`
-	Infra      InfraConfig            `toml:"infra"`
-	Debug      DebugConfig            `toml:"debug"`
-	Overrides  map[string]AssetConfig `toml:"target_overrides"`
-	ConfigPath string                 `toml:"-"`
+	Mode       ModeConfig               `toml:"mode"`
+	Auth       AuthConfig               `toml:"auth"`
+	Trading    TradingConfig            `toml:"trading"`
+	Parser     ParserConfig             `toml:"parser"`
+	Telegram   TelegramConfig           `toml:"telegram"`
+	Ingest     IngestConfig             `toml:"ingest"`
+	Notify     NotifyConfig             `toml:"notify"`
+	Dedupe     DedupeConfig             `toml:"dedupe"`
+	Risk       RiskConfig               `toml:"risk"`
+	PnLExit    PnLExitConfig            `toml:"pnl_exit"`
+	Reconcile  ReconcileConfig          `toml:"reconcile"`
+	Latency    LatencyBudget            `toml:"latency"`
+	Telemetry  TelemetryConfig          `toml:"telemetry"`
+	Infra      InfraConfig              `toml:"infra"`
+	Debug      DebugConfig              `toml:"debug"`
+	Overrides  map[string]AssetConfig   `toml:"target_overrides"`
+	Channels   map[string]ChannelConfig `toml:"channels"`
+	ConfigPath string                   `toml:"-"`
 }
 
 type ModeConfig struct {
@@ -37,6 +47,9 @@ type AuthConfig struct {
 	APIKey          SecretRef `toml:"api_key"`
 	APISecret       SecretRef `toml:"api_secret"`
 	TelegramSession SecretRef `toml:"telegram_session"`
+	// TelegramSessionPassphrase encrypts the session file at
+	// telegram.session_storage_path; required when TelegramSession is set.
+	TelegramSessionPassphrase SecretRef `toml:"telegram_session_passphrase"`
 }
 
 type TradingConfig struct {
@@ -45,27 +58,295 @@ type TradingConfig struct {
 	MaxOpenPositions    int     `toml:"max_open_positions"`
 	OrderType           string  `toml:"order_type"`
 	SlippageBps         int     `toml:"slippage_bps"`
+	QuoteAsset          string  `toml:"quote_asset"`
 }
 
 type ParserConfig struct {
-	RequiredTokens []string `toml:"required_tokens"`
-	LinkHost       string   `toml:"link_host"`
-	LinkPathPrefix string   `toml:"link_path_prefix"`
-	PairSeparator  string   `toml:"pair_separator"`
+	RequiredTokens []string         `toml:"required_tokens"`
+	LinkHost       string           `toml:"link_host"`
+	LinkPathPrefix string           `toml:"link_path_prefix"`
+	PairSeparator  string           `toml:"pair_separator"`
+	QuoteAsset     string           `toml:"quote_asset"`
+	Templates      []TemplateConfig `toml:"templates"`
+	// Links are extra URL forms tried after link_host/link_path_prefix and
+	// before the built-in MEXC spot, futures, locale and query-string forms.
+	Links []LinkRuleConfig `toml:"links"`
+	// TagFallback appends the "tags" symbol source to every template, so posts
+	// without a link can still resolve from scored hashtags/cashtags.
+	TagFallback bool `toml:"tag_fallback"`
+	// MinTagConfidence is the score a tag-resolved symbol needs; unset means
+	// 0.6, and an explicit 0 accepts any unambiguous tag.
+	MinTagConfidence *float64 `toml:"min_tag_confidence"`
+}
+
+// LinkRuleConfig maps one URL form under [[parser.links]] to a pair and market.
+// PathPattern needs a named "pair" group unless QueryParam names the parameter
+// holding the pair. Short rules expand the link via HTTP redirects first.
+type LinkRuleConfig struct {
+	Name        string   `toml:"name"`
+	Hosts       []string `toml:"hosts"`
+	PathPattern string   `toml:"path_pattern"`
+	QueryParam  string   `toml:"query_param"`
+	Market      string   `toml:"market"`
+	Short       bool     `toml:"short"`
+}
+
+// TemplateConfig describes one named message format under [[parser.templates]].
+// Templates are tried in order; the first that matches produces the signal.
+type TemplateConfig struct {
+	Name            string   `toml:"name"`
+	RequiredTokens  []string `toml:"required_tokens"`
+	OptionalTokens  []string `toml:"optional_tokens"`
+	MinOptional     int      `toml:"min_optional"`
+	ForbiddenTokens []string `toml:"forbidden_tokens"`
+	// Pattern is a regular expression that must match; named groups symbol,
+	// pair, base and quote feed the "capture" symbol source.
+	Pattern string `toml:"pattern"`
+	// SymbolFrom lists symbol sources in priority order: link, capture, header, hashtag, tags.
+	SymbolFrom []string `toml:"symbol_from"`
+	// Kind is what a match means: open (default), close, partial_close or cancel.
+	Kind string `toml:"kind"`
+	// ChatWide lets a non-open template that resolves no symbol act on every
+	// position from the chat; otherwise such posts are rejected.
+	ChatWide bool `toml:"chat_wide"`
+	// CloseFraction sizes partial_close when the post states no percentage.
+	CloseFraction float64 `toml:"close_fraction"`
+}
+
+// ChannelConfig is a per-source profile under [channels.<key>], where the key
+// is "<type>:<id>" (see ChannelKey). Empty parser fields inherit from [parser].
+type ChannelConfig struct {
+	Enabled            *bool    `toml:"enabled"`
+	RequiredTokens     []string `toml:"required_tokens"`
+	Templates          []string `toml:"templates"`
+	LinkHost           string   `toml:"link_host"`
+	LinkPathPrefix     string   `toml:"link_path_prefix"`
+	PairSeparator      string   `toml:"pair_separator"`
+	NotionalMultiplier float64  `toml:"notional_multiplier"`
+	OrderType          string   `toml:"order_type"`
+}
+
+// chatTypes are the source chat namespaces; IDs are only unique within one.
+var chatTypes = map[string]bool{"channel": true, "chat": true, "user": true, "webhook": true, "file": true}
+
+// ChannelKey splits a [channels] key into chat type and ID. Keys are
+// "<type>:<id>" with type channel, chat, user, webhook or file; a bare ID
+// names a Telegram channel.
+func ChannelKey(key string) (chatType string, id int64, err error) {
+	chatType, raw, typed := strings.Cut(key, ":")
+	if !typed {
+		chatType, raw = "channel", key
+	}
+	if !chatTypes[chatType] {
+		return "", 0, fmt.Errorf("unknown chat type %q (expected channel, chat, user, webhook or file)", chatType)
+	}
+	id, err = strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("chat id %q is not numeric", raw)
+	}
+	return chatType, id, nil
+}
+
+// DefaultMinTagConfidence applies when parser.min_tag_confidence is unset.
+const DefaultMinTagConfidence = 0.6
+
+// TagConfidence returns min_tag_confidence, or DefaultMinTagConfidence when unset.
+func (c ParserConfig) TagConfidence() float64 {
+	if c.MinTagConfidence == nil {
+		return DefaultMinTagConfidence
+	}
+	return *c.MinTagConfidence
+}
+
+// IsEnabled reports whether signals from the channel are traded; defaults to true.
+func (c ChannelConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// ParserConfig merges the channel overrides onto the global parser config.
+func (c ChannelConfig) ParserConfig(base ParserConfig) ParserConfig {
+	out := base
+	if len(c.RequiredTokens) > 0 {
+		out.RequiredTokens = c.RequiredTokens
+	}
+	if len(c.Templates) > 0 {
+		selected := make([]TemplateConfig, 0, len(c.Templates))
+		for _, name := range c.Templates {
+			for _, tpl := range base.Templates {
+				if tpl.Name == name {
+					selected = append(selected, tpl)
+				}
+			}
+		}
+		out.Templates = selected
+	}
+	if c.LinkHost != "" {
+		out.LinkHost = c.LinkHost
+	}
+	if c.LinkPathPrefix != "" {
+		out.LinkPathPrefix = c.LinkPathPrefix
+	}
+	if c.PairSeparator != "" {
+		out.PairSeparator = c.PairSeparator
+	}
+	return out
 }
 
 type TelegramConfig struct {
 	Enabled            bool      `toml:"enabled"`
-	AllowedChatIDs     []int64   `toml:"allowed_chat_ids"`
+	AllowedChatIDs     []int64   `toml:"allowed_chat_ids"` // bare IDs, matched against any peer type
+	AllowedChats       []string  `toml:"allowed_chats"`    // @username, t.me links or typed IDs, resolved at startup
 	APIID              int       `toml:"api_id"`
 	APIHash            SecretRef `toml:"api_hash"`
 	SessionStoragePath string    `toml:"session_storage_path"`
 	DeviceModel        string    `toml:"device_model"`
 	SystemLanguage     string    `toml:"system_language"`
 	ApplicationVersion string    `toml:"application_version"`
+
+	// Accounts lists redundant user sessions; when empty the fields above
+	// describe the single account.
+	Accounts []TelegramAccountConfig `toml:"accounts"`
+	// Bot configures the Bot API ingestion source, independent of Enabled.
+	Bot TelegramBotConfig `toml:"bot"`
+	// Account names the session this config describes; set by
+	// Config.TelegramAccounts.
+	Account string `toml:"-"`
+}
+
+// TelegramBotConfig is the [telegram.bot] section: a bot added as admin to
+// the signal channels, long-polling getUpdates for channel posts.
+type TelegramBotConfig struct {
+	Enabled            bool      `toml:"enabled"`
+	Token              SecretRef `toml:"token"`
+	APIURL             string    `toml:"api_url"`              // default https://api.telegram.org
+	PollTimeoutSeconds int       `toml:"poll_timeout_seconds"` // getUpdates long-poll timeout, default 30
+	AllowedChats       []string  `toml:"allowed_chats"`        // @username or Bot API chat ID (-100...)
+	OffsetPath         string    `toml:"offset_path"`          // keeps the getUpdates offset across restarts; empty keeps it in memory
+}
+
+// TelegramAccountConfig is one [[telegram.accounts]] entry. Unset fields fall
+// back to [telegram] and [auth]; session_storage_path must be unique.
+type TelegramAccountConfig struct {
+	Name               string    `toml:"name"`
+	APIID              int       `toml:"api_id"`
+	APIHash            SecretRef `toml:"api_hash"`
+	SessionStoragePath string    `toml:"session_storage_path"`
+	Session            SecretRef `toml:"session"`
+	SessionPassphrase  SecretRef `toml:"session_passphrase"`
+	DeviceModel        string    `toml:"device_model"`
+}
+
+// TelegramAccount is the effective configuration of one user session.
+type TelegramAccount struct {
+	Telegram TelegramConfig
+	Auth     AuthConfig
+}
+
+// TelegramAccounts returns every account the listener runs: each
+// [[telegram.accounts]] entry merged onto [telegram] and [auth], or a single
+// "default" account built from them when no accounts are listed.
+func (c *Config) TelegramAccounts() []TelegramAccount {
+	base := c.Telegram
+	base.Accounts = nil
+	if len(c.Telegram.Accounts) == 0 {
+		base.Account = "default"
+		return []TelegramAccount{{Telegram: base, Auth: c.Auth}}
+	}
+
+	out := make([]TelegramAccount, 0, len(c.Telegram.Accounts))
+	for _, a := range c.Telegram.Accounts {
+		tg, auth := base, c.Auth
+		tg.Account = a.Name
+		if a.APIID != 0 {
+			tg.APIID = a.APIID
+		}
+		if a.APIHash.Value != "" {
+			tg.APIHash = a.APIHash
+		}
+		tg.SessionStoragePath = a.SessionStoragePath
+		if a.DeviceModel != "" {
+			tg.DeviceModel = a.DeviceModel
+		}
+		// The shared seed belongs to the top-level session, not to each account.
+		auth.TelegramSession = a.Session
+		if a.SessionPassphrase.Value != "" {
+			auth.TelegramSessionPassphrase = a.SessionPassphrase
+		}
+		out = append(out, TelegramAccount{Telegram: tg, Auth: auth})
+	}
+	return out
+}
+
+// TelegramAccount returns the account called name.
+func (c *Config) TelegramAccount(name string) (TelegramAccount, error) {
+	accounts := c.TelegramAccounts()
+	if name == "" && len(accounts) == 1 {
+		return accounts[0], nil
+	}
+	for _, a := range accounts {
+		if a.Telegram.Account == name {
+			return a, nil
+		}
+	}
+	names := make([]string, 0, len(accounts))
+	for _, a := range accounts {
+		names = append(names, a.Telegram.Account)
+	}
+	return TelegramAccount{}, fmt.Errorf("unknown telegram account %q (configured: %s)", name, strings.Join(names, ", "))
+}
+
+type IngestConfig struct {
+	MaxSignalAgeMS int `toml:"max_signal_age_ms"`
+	MaxClockSkewMS int `toml:"max_clock_skew_ms"`
+	// RecoveredPolicy handles messages fetched by update gap recovery:
+	// "age" (default) applies max_signal_age_ms, "drop" rejects them and
+	// "exits_only" still acts on close/cancel signals but never opens.
+	RecoveredPolicy string `toml:"recovered_policy"`
+
+	// Webhook and File are ingestion sources besides Telegram.
+	Webhook WebhookSourceConfig `toml:"webhook"`
+	File    FileSourceConfig    `toml:"file"`
+}
+
+// WebhookSourceConfig is the [ingest.webhook] section: an HTTP endpoint that
+// accepts HMAC-signed JSON messages from scrapers and relays.
+type WebhookSourceConfig struct {
+	Enabled        bool      `toml:"enabled"`
+	Listen         string    `toml:"listen"`           // host:port, default 127.0.0.1:8089
+	Path           string    `toml:"path"`             // default /signals
+	Secret         SecretRef `toml:"secret"`           // HMAC-SHA256 key shared with senders
+	MaxSkewSeconds int       `toml:"max_skew_seconds"` // accepted signature timestamp drift, default 300
+}
+
+// FileSourceConfig is the [ingest.file] section: messages read from stdin or
+// a file, for manual testing and replays.
+type FileSourceConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Path    string `toml:"path"`    // "-" reads stdin
+	Follow  bool   `toml:"follow"`  // tail the file from its end instead of reading it once
+	Format  string `toml:"format"`  // text (records separated by "---" lines) or jsonl
+	ChatID  int64  `toml:"chat_id"` // reported as the source chat so [channels."file:<id>"] applies
+}
+
+// NotifyConfig is the [notify] section: trade and error notifications sent
+// through a Telegram user session.
+type NotifyConfig struct {
+	Enabled      bool              `toml:"enabled"`
+	Account      string            `toml:"account"`        // telegram account that sends; default the first
+	Chat         string            `toml:"chat"`           // "me" for Saved Messages, @username, t.me link or channel:<id>
+	Events       []string          `toml:"events"`         // event kinds to send; empty sends all
+	MaxPerMinute int               `toml:"max_per_minute"` // default 20; excess notifications are dropped
+	Templates    map[string]string `toml:"templates"`      // text/template per event kind, overriding the defaults
+}
+
+type DedupeConfig struct {
+	TTLSeconds int    `toml:"ttl_seconds"`
+	MaxEntries int    `toml:"max_entries"`
+	EditPolicy string `toml:"edit_policy"`
 }
 
 type RiskConfig struct {
+	Backend          string  `toml:"backend"`
 	TakeProfitPct    float64 `toml:"take_profit_pct"`
 	StopLossPct      float64 `toml:"stop_loss_pct"`
 	BreakevenArmPct  float64 `toml:"breakeven_arm_pct"`
@@ -73,12 +354,20 @@ type RiskConfig struct {
 	MaxDailyLoss     float64 `toml:"max_daily_loss"`
 	MaxDailyTrades   int     `toml:"max_daily_trades"`
 	MaxPositionHours int     `toml:"max_position_hours"`
+	UseSignalLevels  bool    `toml:"use_signal_levels"`
 }
 
 type PnLExitConfig struct {
 	TrailingEnable bool    `toml:"trailing_enable"`
 	TrailStartPct  float64 `toml:"trail_start_pct"`
 	TrailStepPct   float64 `toml:"trail_step_pct"`
+	PollIntervalMS int     `toml:"poll_interval_ms"`
+}
+
+type ReconcileConfig struct {
+	OnBoot          bool    `toml:"on_boot"`
+	UnknownHoldings string  `toml:"unknown_holdings"`
+	MinNotional     float64 `toml:"min_notional"`
 }
 
 type LatencyBudget struct {
@@ -96,8 +385,25 @@ type TelemetryConfig struct {
 }
 
 type InfraConfig struct {
-	RedisURL string `toml:"redis_url"`
-	PgDSN    string `toml:"pg_dsn"`
+	RedisURL       string `toml:"redis_url"`
+	RedisKeyPrefix string `toml:"redis_key_prefix"`
+	PgDSN          string `toml:"pg_dsn"`
+	StatePath      string `toml:"state_path"`
+	// ClosedRetentionDays is how long closed positions stay in the state
+	// store; unset means 30 and 0 keeps them forever.
+	ClosedRetentionDays *int `toml:"closed_retention_days"`
+}
+
+// DefaultClosedRetentionDays applies when infra.closed_retention_days is unset.
+const DefaultClosedRetentionDays = 30
+
+// ClosedRetention returns how long closed positions are kept, or 0 to keep them forever.
+func (c InfraConfig) ClosedRetention() time.Duration {
+	days := DefaultClosedRetentionDays
+	if c.ClosedRetentionDays != nil {
+		days = *c.ClosedRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
 }
 
 type DebugConfig struct {
@@ -158,6 +464,12 @@ func Load(path string) (*Config, error) {
 		return nil, fmt.Errorf("parse config: %w", err)
 	}
 	cfg.ConfigPath = path
+	if cfg.Trading.QuoteAsset == "" {
+		cfg.Trading.QuoteAsset = "USDT"
+	}
+	if cfg.Parser.QuoteAsset == "" {
+		cfg.Parser.QuoteAsset = cfg.Trading.QuoteAsset
+	}
 
 	if err = cfg.Validate(); err != nil {
 		return nil, err
@@ -166,6 +478,25 @@ func Load(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// checkTokens rejects template tokens made only of whitespace, emoji and other
+// symbols. The parser compares tokens with those characters folded away, so
+// such a token would match every message.
+func checkTokens(tokens []string) error {
+	for _, tok := range tokens {
+		blank := true
+		for _, r := range tok {
+			if !unicode.IsSpace(r) && !unicode.In(r, unicode.So, unicode.Sk, unicode.Mn, unicode.Me, unicode.Cf, unicode.Variation_Selector) {
+				blank = false
+				break
+			}
+		}
+		if blank {
+			return fmt.Errorf("token %q has no letters, digits or punctuation; emoji and symbols are ignored when matching", tok)
+		}
+	}
+	return nil
+}
+
 // Validate ensures core configuration is sane before boot.
 func (c *Config) Validate() error {
 	if c.Mode.Exchange != "mexc" {
@@ -200,16 +531,216 @@ func (c *Config) Validate() error {
 	if c.Parser.PairSeparator == "" {
 		return errors.New("parser pair_separator required")
 	}
+	if c.Infra.ClosedRetentionDays != nil && *c.Infra.ClosedRetentionDays < 0 {
+		return errors.New("infra closed_retention_days must not be negative")
+	}
+	if floor := c.Parser.TagConfidence(); floor < 0 || floor > 1 {
+		return errors.New("parser min_tag_confidence must be between 0 and 1")
+	}
+	if err := checkTokens(c.Parser.RequiredTokens); err != nil {
+		return fmt.Errorf("parser required_tokens: %w", err)
+	}
+	templateNames := make(map[string]struct{}, len(c.Parser.Templates))
+	for i, tpl := range c.Parser.Templates {
+		if strings.TrimSpace(tpl.Name) == "" {
+			return fmt.Errorf("parser.templates[%d]: name required", i)
+		}
+		if _, dup := templateNames[tpl.Name]; dup {
+			return fmt.Errorf("parser.templates: duplicate name %q", tpl.Name)
+		}
+		templateNames[tpl.Name] = struct{}{}
+		if tpl.Pattern != "" {
+			if _, err := regexp.Compile(tpl.Pattern); err != nil {
+				return fmt.Errorf("parser.templates.%s: invalid pattern: %w", tpl.Name, err)
+			}
+		}
+		for _, tokens := range [][]string{tpl.RequiredTokens, tpl.OptionalTokens, tpl.ForbiddenTokens} {
+			if err := checkTokens(tokens); err != nil {
+				return fmt.Errorf("parser.templates.%s: %w", tpl.Name, err)
+			}
+		}
+		if tpl.MinOptional < 0 || tpl.MinOptional > len(tpl.OptionalTokens) {
+			return fmt.Errorf("parser.templates.%s: min_optional must be between 0 and the number of optional_tokens", tpl.Name)
+		}
+		switch tpl.Kind {
+		case "", "open", "close", "partial_close", "cancel":
+		default:
+			return fmt.Errorf("parser.templates.%s: kind must be open, close, partial_close or cancel", tpl.Name)
+		}
+		if tpl.ChatWide && (tpl.Kind == "" || tpl.Kind == "open") {
+			return fmt.Errorf("parser.templates.%s: chat_wide only applies to close, partial_close and cancel", tpl.Name)
+		}
+		if tpl.CloseFraction < 0 || tpl.CloseFraction > 1 {
+			return fmt.Errorf("parser.templates.%s: close_fraction must be between 0 and 1", tpl.Name)
+		}
+		for _, src := range tpl.SymbolFrom {
+			switch src {
+			case "link", "capture", "header", "hashtag", "tags":
+			default:
+				return fmt.Errorf("parser.templates.%s: unknown symbol_from %q", tpl.Name, src)
+			}
+		}
+	}
+	for i, link := range c.Parser.Links {
+		if strings.TrimSpace(link.Name) == "" {
+			return fmt.Errorf("parser.links[%d]: name required", i)
+		}
+		if len(link.Hosts) == 0 {
+			return fmt.Errorf("parser.links.%s: hosts required", link.Name)
+		}
+		switch link.Market {
+		case "", "spot", "futures":
+		default:
+			return fmt.Errorf("parser.links.%s: market must be spot or futures", link.Name)
+		}
+		if link.Short {
+			continue
+		}
+		if link.PathPattern == "" && link.QueryParam == "" {
+			return fmt.Errorf("parser.links.%s: path_pattern or query_param required", link.Name)
+		}
+		if link.PathPattern != "" {
+			re, err := regexp.Compile(link.PathPattern)
+			if err != nil {
+				return fmt.Errorf("parser.links.%s: invalid path_pattern: %w", link.Name, err)
+			}
+			if link.QueryParam == "" && re.SubexpIndex("pair") < 0 {
+				return fmt.Errorf("parser.links.%s: path_pattern needs a (?P<pair>...) group", link.Name)
+			}
+		}
+	}
+	for key, ch := range c.Channels {
+		if _, _, err := ChannelKey(key); err != nil {
+			return fmt.Errorf("channels.%s: %w", key, err)
+		}
+		if err := checkTokens(ch.RequiredTokens); err != nil {
+			return fmt.Errorf("channels.%s: required_tokens: %w", key, err)
+		}
+		for _, name := range ch.Templates {
+			if _, ok := templateNames[name]; !ok {
+				return fmt.Errorf("channels.%s: unknown template %q", key, name)
+			}
+		}
+		if ch.NotionalMultiplier < 0 {
+			return fmt.Errorf("channels.%s: notional_multiplier must be >= 0", key)
+		}
+		switch ch.OrderType {
+		case "", "market", "limit":
+		default:
+			return fmt.Errorf("channels.%s: order_type must be market or limit, got %q", key, ch.OrderType)
+		}
+	}
 	if c.Telegram.Enabled {
-		if c.Telegram.APIID <= 0 {
-			return errors.New("telegram api_id must be > 0 when enabled")
+		for i, entry := range c.Telegram.AllowedChats {
+			if strings.TrimSpace(entry) == "" {
+				return fmt.Errorf("telegram allowed_chats[%d] must not be empty", i)
+			}
+		}
+		names := make(map[string]struct{})
+		paths := make(map[string]string)
+		for _, a := range c.TelegramAccounts() {
+			name, tg := a.Telegram.Account, a.Telegram
+			prefix := "telegram"
+			if len(c.Telegram.Accounts) > 0 {
+				prefix = fmt.Sprintf("telegram.accounts %q", name)
+				if strings.TrimSpace(name) == "" {
+					return errors.New("telegram.accounts entries must have a name")
+				}
+				if _, dup := names[name]; dup {
+					return fmt.Errorf("telegram.accounts: duplicate name %q", name)
+				}
+				names[name] = struct{}{}
+			}
+			if tg.APIID <= 0 {
+				return fmt.Errorf("%s api_id must be > 0 when enabled", prefix)
+			}
+			if strings.TrimSpace(tg.APIHash.Value) == "" {
+				return fmt.Errorf("%s api_hash must be provided when enabled", prefix)
+			}
+			path := strings.TrimSpace(tg.SessionStoragePath)
+			if path == "" {
+				return fmt.Errorf("%s session_storage_path must be provided when enabled", prefix)
+			}
+			if other, dup := paths[filepath.Clean(path)]; dup {
+				return fmt.Errorf("%s shares session_storage_path with %q", prefix, other)
+			}
+			paths[filepath.Clean(path)] = name
+			if a.Auth.TelegramSession.Value != "" && a.Auth.TelegramSessionPassphrase.Value == "" {
+				return fmt.Errorf("%s session requires a session passphrase", prefix)
+			}
+		}
+	}
+	if bot := c.Telegram.Bot; bot.Enabled {
+		if strings.TrimSpace(bot.Token.Value) == "" {
+			return errors.New("telegram.bot token must be provided when enabled")
+		}
+		if bot.PollTimeoutSeconds < 0 || bot.PollTimeoutSeconds > 50 {
+			return errors.New("telegram.bot poll_timeout_seconds must be between 0 and 50")
+		}
+		if bot.APIURL != "" {
+			if u, err := url.Parse(bot.APIURL); err != nil || u.Scheme == "" || u.Host == "" {
+				return fmt.Errorf("telegram.bot api_url %q is not an absolute URL", bot.APIURL)
+			}
+		}
+	}
+	if c.Auth.TelegramSession.Value != "" && c.Auth.TelegramSessionPassphrase.Value == "" {
+		return errors.New("auth telegram_session_passphrase must be provided when telegram_session is set")
+	}
+	if c.Ingest.MaxSignalAgeMS < 0 || c.Ingest.MaxClockSkewMS < 0 {
+		return errors.New("ingest max_signal_age_ms and max_clock_skew_ms must be >= 0")
+	}
+	switch c.Ingest.RecoveredPolicy {
+	case "", "age", "drop", "exits_only":
+	default:
+		return fmt.Errorf("ingest recovered_policy must be age, drop or exits_only, got %q", c.Ingest.RecoveredPolicy)
+	}
+	if wh := c.Ingest.Webhook; wh.Enabled {
+		if strings.TrimSpace(wh.Secret.Value) == "" {
+			return errors.New("ingest.webhook secret must be provided when enabled")
+		}
+		if wh.Path != "" && !strings.HasPrefix(wh.Path, "/") {
+			return fmt.Errorf("ingest.webhook path must start with /, got %q", wh.Path)
+		}
+		if wh.MaxSkewSeconds < 0 {
+			return errors.New("ingest.webhook max_skew_seconds must be >= 0")
+		}
+	}
+	if f := c.Ingest.File; f.Enabled {
+		if strings.TrimSpace(f.Path) == "" {
+			return errors.New("ingest.file path must be provided when enabled (use \"-\" for stdin)")
+		}
+		if f.Follow && f.Path == "-" {
+			return errors.New("ingest.file follow requires a file path, not stdin")
+		}
+		switch f.Format {
+		case "", "text", "jsonl":
+		default:
+			return fmt.Errorf("ingest.file format must be text or jsonl, got %q", f.Format)
+		}
+	}
+	if n := c.Notify; n.Enabled {
+		if !c.Telegram.Enabled {
+			return errors.New("notify requires telegram.enabled: notifications are sent through the user session")
 		}
-		if strings.TrimSpace(c.Telegram.APIHash.Value) == "" {
-			return errors.New("telegram api_hash must be provided when enabled")
+		if strings.TrimSpace(n.Chat) == "" {
+			return errors.New("notify chat must be provided when enabled (use \"me\" for Saved Messages)")
 		}
-		if strings.TrimSpace(c.Telegram.SessionStoragePath) == "" {
-			return errors.New("telegram session_storage_path must be provided when enabled")
+		if n.MaxPerMinute < 0 {
+			return errors.New("notify max_per_minute must be >= 0")
 		}
+		if n.Account != "" {
+			if _, err := c.TelegramAccount(n.Account); err != nil {
+				return fmt.Errorf("notify account: %w", err)
+			}
+		}
+	}
+	if c.Dedupe.TTLSeconds < 0 || c.Dedupe.MaxEntries < 0 {
+		return errors.New("dedupe ttl_seconds and max_entries must be >= 0")
+	}
+	switch c.Dedupe.EditPolicy {
+	case "", "ignore", "reparse_failed":
+	default:
+		return fmt.Errorf("dedupe edit_policy must be ignore or reparse_failed, got %q", c.Dedupe.EditPolicy)
 	}
 	if c.Risk.TakeProfitPct <= 0 || c.Risk.StopLossPct <= 0 {
 		return errors.New("risk take_profit_pct and stop_loss_pct must be > 0")
@@ -217,11 +748,35 @@ func (c *Config) Validate() error {
 	if c.Risk.MaxDailyTrades <= 0 {
 		return errors.New("risk max_daily_trades must be > 0")
 	}
+	switch c.Risk.Backend {
+	case "", "memory":
+	case "local":
+		if strings.TrimSpace(c.Infra.StatePath) == "" {
+			return errors.New("infra state_path required when risk backend is local")
+		}
+	case "redis":
+		if strings.TrimSpace(c.Infra.RedisURL) == "" {
+			return errors.New("infra redis_url required when risk backend is redis")
+		}
+	default:
+		return fmt.Errorf("risk backend must be memory, local or redis, got %q", c.Risk.Backend)
+	}
 	if c.Risk.MaxPositionHours < 0 {
 		return errors.New("risk max_position_hours must be >= 0")
 	}
 	if c.PnLExit.TrailStepPct < 0 {
 		return errors.New("pnl_exit trail_step_pct must be >= 0")
 	}
+	if c.PnLExit.PollIntervalMS < 0 {
+		return errors.New("pnl_exit poll_interval_ms must be >= 0")
+	}
+	switch c.Reconcile.UnknownHoldings {
+	case "", "flag", "adopt":
+	default:
+		return fmt.Errorf("reconcile unknown_holdings must be flag or adopt, got %q", c.Reconcile.UnknownHoldings)
+	}
+	if c.Reconcile.MinNotional < 0 {
+		return errors.New("reconcile min_notional must be >= 0")
+	}
 	return nil
 }
@@ -0,0 +1,167 @@
+package dedupe
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+// EditPolicy decides what happens to edited versions of a message.
+type EditPolicy string
+
+const (
+	// EditIgnore drops every edit.
+	EditIgnore EditPolicy = "ignore"
+	// EditReparseFailed processes an edit only if the first version failed to parse.
+	EditReparseFailed EditPolicy = "reparse_failed"
+)
+
+// Key identifies a message; message IDs are only unique per chat, and chat
+// IDs only per chat type.
+type Key struct {
+	Chat      signal.ChatKey
+	MessageID int64
+}
+
+// KeyOf derives the dedupe key for msg.
+func KeyOf(msg signal.Message) Key {
+	return Key{Chat: msg.Chat(), MessageID: msg.ID}
+}
+
+type outcome int
+
+const (
+	outcomePending outcome = iota
+	outcomeParsed
+	outcomeFailed
+)
+
+type entry struct {
+	key     Key
+	outcome outcome
+	seenAt  time.Time
+}
+
+// Cache is a bounded TTL cache of recently seen messages and their parse outcome.
+type Cache struct {
+	ttl    time.Duration
+	max    int
+	policy EditPolicy
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[Key]*list.Element
+	order   *list.List
+}
+
+const (
+	defaultTTL        = time.Hour
+	defaultMaxEntries = 10000
+)
+
+// New builds a cache holding at most maxEntries keys for ttl each; zero values
+// fall back to one hour and 10000 entries.
+func New(ttl time.Duration, maxEntries int, policy EditPolicy) *Cache {
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxEntries
+	}
+	if policy == "" {
+		policy = EditIgnore
+	}
+	return &Cache{
+		ttl:     ttl,
+		max:     maxEntries,
+		policy:  policy,
+		now:     time.Now,
+		entries: make(map[Key]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Admit reports whether msg should be processed. Admitted messages are recorded
+// as pending until Record is called; reason explains a rejection.
+func (c *Cache) Admit(msg signal.Message) (ok bool, reason string) {
+	key := KeyOf(msg)
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(now)
+	elem, seen := c.entries[key]
+
+	if !msg.Edited {
+		if seen {
+			return false, "duplicate"
+		}
+		c.insert(key, now)
+		return true, ""
+	}
+
+	switch c.policy {
+	case EditReparseFailed:
+		if !seen {
+			c.insert(key, now)
+			return true, ""
+		}
+		e := elem.Value.(*entry)
+		if e.outcome != outcomeFailed {
+			return false, "edit_of_processed_message"
+		}
+		e.outcome = outcomePending
+		return true, ""
+	default:
+		return false, "edit_ignored"
+	}
+}
+
+// Record stores whether the admitted msg parsed into a signal.
+func (c *Cache) Record(msg signal.Message, parsed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[KeyOf(msg)]
+	if !ok {
+		return
+	}
+	e := elem.Value.(*entry)
+	if parsed {
+		e.outcome = outcomeParsed
+	} else {
+		e.outcome = outcomeFailed
+	}
+}
+
+// Len returns the number of tracked keys.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) insert(key Key, now time.Time) {
+	c.entries[key] = c.order.PushBack(&entry{key: key, seenAt: now})
+	for c.order.Len() > c.max {
+		c.remove(c.order.Front())
+	}
+}
+
+// expire drops entries older than ttl; entries are kept in insertion order.
+func (c *Cache) expire(now time.Time) {
+	for front := c.order.Front(); front != nil; front = c.order.Front() {
+		if now.Sub(front.Value.(*entry).seenAt) < c.ttl {
+			return
+		}
+		c.remove(front)
+	}
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).key)
+}
@@ -0,0 +1,85 @@
+package dedupe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+func TestCacheDropsDuplicates(t *testing.T) {
+	cache := New(time.Minute, 10, EditIgnore)
+	msg := signal.Message{ID: 7, ChatID: 100}
+
+	if ok, _ := cache.Admit(msg); !ok {
+		t.Fatalf("expected first delivery admitted")
+	}
+	if ok, reason := cache.Admit(msg); ok || reason != "duplicate" {
+		t.Fatalf("expected duplicate rejected, got ok=%v reason=%q", ok, reason)
+	}
+
+	// Same message ID in another chat is a different message.
+	if ok, _ := cache.Admit(signal.Message{ID: 7, ChatID: 200}); !ok {
+		t.Fatalf("expected message from another chat admitted")
+	}
+	// So is one from a webhook sender reusing the chat ID.
+	if ok, _ := cache.Admit(signal.Message{ID: 7, ChatID: 100, ChatType: "webhook"}); !ok {
+		t.Fatalf("expected message from another chat type admitted")
+	}
+}
+
+func TestCacheEditPolicies(t *testing.T) {
+	original := signal.Message{ID: 1, ChatID: 100}
+	edit := signal.Message{ID: 1, ChatID: 100, Edited: true}
+
+	ignore := New(time.Minute, 10, EditIgnore)
+	ignore.Admit(original)
+	ignore.Record(original, false)
+	if ok, reason := ignore.Admit(edit); ok || reason != "edit_ignored" {
+		t.Fatalf("ignore policy: expected edit dropped, got ok=%v reason=%q", ok, reason)
+	}
+
+	reparse := New(time.Minute, 10, EditReparseFailed)
+	reparse.Admit(original)
+	reparse.Record(original, true)
+	if ok, _ := reparse.Admit(edit); ok {
+		t.Fatalf("reparse_failed policy: expected edit of parsed message dropped")
+	}
+
+	failed := signal.Message{ID: 2, ChatID: 100}
+	reparse.Admit(failed)
+	reparse.Record(failed, false)
+	failedEdit := failed
+	failedEdit.Edited = true
+	if ok, _ := reparse.Admit(failedEdit); !ok {
+		t.Fatalf("reparse_failed policy: expected edit of failed message admitted")
+	}
+	reparse.Record(failedEdit, true)
+	if ok, _ := reparse.Admit(failedEdit); ok {
+		t.Fatalf("reparse_failed policy: expected second edit dropped once parsed")
+	}
+}
+
+func TestCacheBoundsAndExpiry(t *testing.T) {
+	now := time.Unix(1710000000, 0)
+	cache := New(time.Minute, 2, EditIgnore)
+	cache.now = func() time.Time { return now }
+
+	for id := int64(1); id <= 3; id++ {
+		cache.Admit(signal.Message{ID: id, ChatID: 1})
+	}
+	if cache.Len() != 2 {
+		t.Fatalf("expected cache bounded to 2 entries, got %d", cache.Len())
+	}
+	if ok, _ := cache.Admit(signal.Message{ID: 1, ChatID: 1}); !ok {
+		t.Fatalf("expected evicted key admitted again")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if ok, _ := cache.Admit(signal.Message{ID: 3, ChatID: 1}); !ok {
+		t.Fatalf("expected expired key admitted again")
+	}
+	if cache.Len() != 1 {
+		t.Fatalf("expected expired entries dropped, got %d", cache.Len())
+	}
+}
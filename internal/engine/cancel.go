@@ -0,0 +1,63 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+// cancelWindow is how long a cancel post keeps suppressing entries from its chat.
+const cancelWindow = 10 * time.Minute
+
+// cancelKey scopes a cancellation to a chat and, when the post named one, a
+// symbol. An empty symbol covers the whole chat.
+type cancelKey struct {
+	chat   signal.ChatKey
+	symbol string
+}
+
+// cancelGuard remembers recent cancel posts so an entry still pending for the
+// cancelled call (a redelivery, an edit or a late copy) is not traded. Only
+// calls posted before the cancel are suppressed; a fresh call goes through.
+type cancelGuard struct {
+	mu      sync.Mutex
+	posted  map[cancelKey]time.Time
+	expires map[cancelKey]time.Time
+}
+
+func newCancelGuard() *cancelGuard {
+	return &cancelGuard{
+		posted:  make(map[cancelKey]time.Time),
+		expires: make(map[cancelKey]time.Time),
+	}
+}
+
+// record registers a cancel for symbol in chat posted at posted.
+func (g *cancelGuard) record(chat signal.ChatKey, symbol string, posted, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for k, until := range g.expires {
+		if !now.Before(until) {
+			delete(g.expires, k)
+			delete(g.posted, k)
+		}
+	}
+	k := cancelKey{chat, symbol}
+	g.posted[k] = posted
+	g.expires[k] = now.Add(cancelWindow)
+}
+
+// cancelled reports whether an entry for symbol in chat, posted at posted,
+// falls under a recent cancellation.
+func (g *cancelGuard) cancelled(chat signal.ChatKey, symbol string, posted, now time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, k := range []cancelKey{{chat, symbol}, {chat, ""}} {
+		until, ok := g.expires[k]
+		if ok && now.Before(until) && !posted.After(g.posted[k]) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,13 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
 	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/dedupe"
 	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/metrics"
+	"github.com/user/mexc-bot/internal/notify"
+	"github.com/user/mexc-bot/internal/position"
 	"github.com/user/mexc-bot/internal/risk"
 	"github.com/user/mexc-bot/internal/signal"
 )
 
+var (
+	messagesRejected = metrics.Default.Counter("mexc_bot_messages_rejected_total", "Messages dropped before an order was submitted, by reason.", "reason")
+	ordersSubmitted  = metrics.Default.Counter("mexc_bot_orders_submitted_total", "Orders accepted by the executor.", "executor")
+)
+
 // Engine ties signal parsing, risk evaluation, and order execution together.
 type Engine struct {
 	logger   *slog.Logger
@@ -19,9 +29,81 @@ type Engine struct {
 	parser   *signal.Parser
 	risk     risk.Manager
 	executor exchange.Executor
+	book     *position.Book
+	exits    *position.Manager
+	prices   exchange.PriceFeed
+	dedupe   *dedupe.Cache
+	age      *ageGuard
+	channels map[signal.ChatKey]*channelProfile
+	notifier notify.Sink
+	cancels  *cancelGuard
+}
+
+// channelProfile is the resolved [channels.<key>] block for one source chat.
+type channelProfile struct {
+	enabled    bool
+	parser     *signal.Parser
+	multiplier float64
+	orderType  string
+}
+
+// Option configures optional Engine collaborators.
+type Option func(*Engine)
+
+// WithPositions records every executed entry as an open position in book.
+func WithPositions(book *position.Book) Option {
+	return func(e *Engine) {
+		e.book = book
+	}
+}
+
+// WithExits routes close, partial_close and cancel signals to manager; prices
+// supplies the exit price recorded for PnL.
+func WithExits(manager *position.Manager, prices exchange.PriceFeed) Option {
+	return func(e *Engine) {
+		e.exits = manager
+		e.prices = prices
+	}
+}
+
+// WithDedupe drops repeated deliveries and edits of already handled messages.
+func WithDedupe(cache *dedupe.Cache) Option {
+	return func(e *Engine) {
+		e.dedupe = cache
+	}
+}
+
+// WithNotifier reports order submissions, risk denials and entry failures
+// to sink.
+func WithNotifier(sink notify.Sink) Option {
+	return func(e *Engine) {
+		e.notifier = sink
+	}
+}
+
+// WithSymbols lets the global and per-channel parsers check tag candidates
+// against the exchange symbol list.
+func WithSymbols(dir signal.SymbolDirectory) Option {
+	return func(e *Engine) {
+		e.parser.SetSymbols(dir)
+		for _, ch := range e.channels {
+			ch.parser.SetSymbols(dir)
+		}
+	}
 }
 
-func New(cfg *config.Config, parser *signal.Parser, riskManager risk.Manager, executor exchange.Executor, logger *slog.Logger) (*Engine, error) {
+// WithLinkExpander lets the parsers follow short links configured with
+// short = true under [[parser.links]].
+func WithLinkExpander(expander signal.LinkExpander) Option {
+	return func(e *Engine) {
+		e.parser.SetLinkExpander(expander)
+		for _, ch := range e.channels {
+			ch.parser.SetLinkExpander(expander)
+		}
+	}
+}
+
+func New(cfg *config.Config, parser *signal.Parser, riskManager risk.Manager, executor exchange.Executor, logger *slog.Logger, opts ...Option) (*Engine, error) {
 	if cfg == nil {
 		return nil, errors.New("config must not be nil")
 	}
@@ -35,65 +117,285 @@ func New(cfg *config.Config, parser *signal.Parser, riskManager risk.Manager, ex
 		return nil, errors.New("executor must not be nil")
 	}
 
-	return &Engine{
+	e := &Engine{
 		logger:   logger,
 		cfg:      cfg,
 		parser:   parser,
 		risk:     riskManager,
 		executor: executor,
-	}, nil
+		age:      newAgeGuard(cfg.Ingest),
+		cancels:  newCancelGuard(),
+		channels: make(map[signal.ChatKey]*channelProfile, len(cfg.Channels)),
+	}
+	for key, ch := range cfg.Channels {
+		chatType, chatID, err := config.ChannelKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("channel %q: %w", key, err)
+		}
+		e.channels[signal.ChatKey{Type: chatType, ID: chatID}] = &channelProfile{
+			enabled:    ch.IsEnabled(),
+			parser:     signal.NewParser(ch.ParserConfig(cfg.Parser)),
+			multiplier: ch.NotionalMultiplier,
+			orderType:  ch.OrderType,
+		}
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
 }
 
 // HandleMessage ingests a Telegram message and attempts to trade it.
 func (e *Engine) HandleMessage(ctx context.Context, msg signal.Message) error {
-	sig, err := e.parser.Parse(msg)
+	if age, fresh := e.age.check(msg); !fresh {
+		messagesRejected.Inc("stale_signal")
+		e.logger.WarnContext(ctx, "signal rejected as stale", "chat_id", msg.ChatID, "message_id", msg.ID, "age", age, "max_age", e.age.maxAge, "recovered", msg.Recovered, "reason", "stale_signal")
+		return nil
+	}
+	if msg.Recovered && e.cfg.Ingest.RecoveredPolicy == "drop" {
+		messagesRejected.Inc("recovered_signal")
+		e.logger.WarnContext(ctx, "recovered message dropped by policy", "chat_id", msg.ChatID, "message_id", msg.ID, "reason", "recovered_signal")
+		return nil
+	}
+
+	parser := e.parser
+	profile := e.channels[msg.Chat()]
+	if profile != nil {
+		if !profile.enabled {
+			messagesRejected.Inc("channel_disabled")
+			e.logger.DebugContext(ctx, "message from disabled channel", "source", msg.Source(), "message_id", msg.ID)
+			return nil
+		}
+		parser = profile.parser
+	}
+
+	// Admit only what passed the gates above: a message dropped there must
+	// not leave a pending entry that turns away its later edits.
+	if e.dedupe != nil {
+		if ok, reason := e.dedupe.Admit(msg); !ok {
+			messagesRejected.Inc("dedupe_" + reason)
+			e.logger.DebugContext(ctx, "message skipped by dedupe", "chat_id", msg.ChatID, "message_id", msg.ID, "edited", msg.Edited, "reason", reason)
+			return nil
+		}
+	}
+
+	if e.logger.Enabled(ctx, slog.LevelDebug) {
+		if normalized := signal.Normalize(msg.Text); normalized != msg.Text {
+			e.logger.DebugContext(ctx, "message text normalised", "chat_id", msg.ChatID, "message_id", msg.ID, "original", msg.Text, "normalised", normalized)
+		}
+	}
+
+	sig, err := parser.Parse(ctx, msg)
+	if e.dedupe != nil {
+		e.dedupe.Record(msg, err == nil)
+	}
 	if err != nil {
-		return fmt.Errorf("parse signal: %w", err)
+		messagesRejected.Inc("parse_error")
+		return fmt.Errorf("parse signal from %s/%d: %w", msg.Source(), msg.ID, err)
 	}
 
-	notional := e.resolveNotional(sig.Symbol)
+	if sig.Kind != signal.KindOpen {
+		return e.handleExit(ctx, sig)
+	}
+	if e.cancels.cancelled(msg.Chat(), sig.Symbol, postedAt(msg), time.Now()) {
+		messagesRejected.Inc("signal_cancelled")
+		e.logger.InfoContext(ctx, "open signal skipped: cancelled by a later post", "symbol", sig.Symbol, "source", msg.Source(), "message_id", msg.ID, "reason", "signal_cancelled")
+		return nil
+	}
+	if msg.Recovered && e.cfg.Ingest.RecoveredPolicy == "exits_only" {
+		messagesRejected.Inc("recovered_signal")
+		e.logger.WarnContext(ctx, "recovered open signal skipped by policy", "symbol", sig.Symbol, "source", msg.Source(), "message_id", msg.ID, "reason", "recovered_signal")
+		return nil
+	}
+
+	if router, ok := e.executor.(interface{ Supports(exchange.Market) bool }); ok && !router.Supports(sig.Market) {
+		messagesRejected.Inc("market_unavailable")
+		e.logger.WarnContext(ctx, "signal skipped: no executor for market", "symbol", sig.Symbol, "market", sig.Market, "source", msg.Source(), "message_id", msg.ID)
+		return nil
+	}
+
+	notional := e.resolveNotional(sig.Symbol, profile)
 
 	decision, err := e.risk.Evaluate(ctx, *sig, notional)
 	if err != nil {
 		return fmt.Errorf("risk evaluation failed: %w", err)
 	}
 	if !decision.Allow {
-		e.logger.InfoContext(ctx, "signal skipped by risk", "symbol", sig.Symbol, "reason", decision.Reason)
+		messagesRejected.Inc("risk_" + decision.Reason)
+		e.logger.InfoContext(ctx, "signal skipped by risk", "symbol", sig.Symbol, "reason", decision.Reason, "source", msg.Source(), "message_id", msg.ID)
+		e.notify(notify.Event{Kind: notify.KindRiskDenied, Symbol: sig.Symbol, Market: string(sig.Market), Notional: notional, Reason: decision.Reason, Source: msg.Source()})
 		return nil
 	}
 
 	req := exchange.OrderRequest{
 		Symbol:      sig.Symbol,
+		Market:      sig.Market,
 		Notional:    decision.Notional,
 		Side:        exchange.OrderSideBuy,
-		Type:        e.resolveOrderType(),
+		Type:        e.resolveOrderType(profile),
 		SlippageBps: e.cfg.Trading.SlippageBps,
 		Metadata: map[string]string{
+			"source_chat_id":    fmt.Sprintf("%d", msg.ChatID),
+			"source_chat_type":  msg.ChatType,
 			"source_message_id": fmt.Sprintf("%d", msg.ID),
+			"source":            msg.Source(),
 		},
 	}
 
 	ack, err := e.executor.Submit(ctx, req)
 	if err != nil {
+		e.risk.RecordFailure(ctx, *sig)
+		messagesRejected.Inc("executor_error")
+		e.notify(notify.Event{Kind: notify.KindExecutorFailed, Symbol: req.Symbol, Market: string(req.Market), Side: string(req.Side), Notional: req.Notional, Source: msg.Source(), Error: err.Error()})
 		return fmt.Errorf("order submission failed: %w", err)
 	}
 
 	e.risk.RecordExecution(ctx, *sig, decision.Notional)
+	ordersSubmitted.Inc(e.executor.Name())
+	e.openPosition(ctx, *sig, req, ack)
 
-	e.logger.InfoContext(ctx, "order submitted", "order_id", ack.OrderID, "executor", e.executor.Name(), "symbol", req.Symbol, "notional", req.Notional)
+	e.logger.InfoContext(ctx, "order submitted", "order_id", ack.OrderID, "executor", e.executor.Name(), "symbol", req.Symbol, "market", sig.Market, "notional", req.Notional, "template", sig.Template, "symbol_from", sig.SymbolFrom, "confidence", sig.Confidence, "source", msg.Source(), "chat_id", msg.ChatID, "message_id", msg.ID, "sender", msg.SenderName)
+	e.notify(notify.Event{Kind: notify.KindOrderSubmitted, Symbol: req.Symbol, Market: string(req.Market), Side: string(req.Side), OrderID: ack.OrderID, Notional: req.Notional, Source: msg.Source()})
 
 	return nil
 }
 
-func (e *Engine) resolveNotional(symbol string) float64 {
-	size := e.cfg.Trading.DefaultBaseNotional
-	if ov, ok := e.cfg.Overrides[symbol]; ok {
-		if ov.DefaultBaseNotional > 0 {
-			size = ov.DefaultBaseNotional
+func (e *Engine) openPosition(ctx context.Context, sig signal.Signal, req exchange.OrderRequest, ack exchange.OrderAck) {
+	if e.book == nil {
+		return
+	}
+	p := position.Position{
+		Symbol:          req.Symbol,
+		Market:          req.Market,
+		OrderID:         ack.OrderID,
+		Notional:        req.Notional,
+		SourceChatID:    sig.RawMessage.ChatID,
+		SourceChatType:  sig.RawMessage.ChatType,
+		SourceMessageID: sig.RawMessage.ID,
+		OpenedAt:        ack.SubmittedAt,
+		Stop:            sig.Stop,
+	}
+	if len(sig.Targets) > 0 {
+		target := sig.Targets[0]
+		p.Target = &target
+	}
+	pos, err := e.book.Open(p)
+	if err != nil {
+		e.logger.ErrorContext(ctx, "record open position", "symbol", req.Symbol, "error", err)
+		return
+	}
+	e.logger.DebugContext(ctx, "position opened", "position_id", pos.ID, "symbol", pos.Symbol)
+}
+
+// handleExit closes, reduces or cancels the open positions a follow-up post
+// refers to: those opened from the same chat, narrowed to the symbol when the
+// post names one. A post without a symbol only acts chat-wide when its
+// template opts in.
+func (e *Engine) handleExit(ctx context.Context, sig *signal.Signal) error {
+	msg := sig.RawMessage
+	if sig.Symbol == "" && !sig.ChatWide {
+		messagesRejected.Inc("exit_without_symbol")
+		e.logger.WarnContext(ctx, "exit signal names no symbol", "kind", sig.Kind, "template", sig.Template, "source", msg.Source(), "message_id", msg.ID)
+		return nil
+	}
+	if sig.Kind == signal.KindCancel {
+		e.cancels.record(msg.Chat(), sig.Symbol, postedAt(msg), time.Now())
+	}
+	if e.exits == nil {
+		if sig.Kind == signal.KindCancel {
+			return nil
+		}
+		messagesRejected.Inc("exits_unavailable")
+		e.logger.WarnContext(ctx, "exit signal ignored: no position manager", "kind", sig.Kind, "symbol", sig.Symbol, "source", msg.Source(), "message_id", msg.ID)
+		return nil
+	}
+
+	var matched []position.Position
+	for _, p := range e.exits.Book().OpenPositions() {
+		if !p.FromChat(msg.Chat()) {
+			continue
+		}
+		if sig.Symbol != "" && p.Symbol != sig.Symbol {
+			continue
 		}
-		if ov.MaxNotional > 0 && size > ov.MaxNotional {
-			size = ov.MaxNotional
+		matched = append(matched, p)
+	}
+	if len(matched) == 0 {
+		if sig.Kind == signal.KindCancel {
+			e.logger.InfoContext(ctx, "cancel recorded; no open position to withdraw", "symbol", sig.Symbol, "source", msg.Source(), "message_id", msg.ID)
+			return nil
+		}
+		messagesRejected.Inc("no_matching_position")
+		e.logger.InfoContext(ctx, "exit signal matched no open position", "kind", sig.Kind, "symbol", sig.Symbol, "template", sig.Template, "source", msg.Source(), "message_id", msg.ID)
+		return nil
+	}
+
+	reason := "signal_" + string(sig.Kind)
+	var errs []error
+	for _, p := range matched {
+		if sig.Kind == signal.KindCancel {
+			// A cancelled call withdraws a resting entry; a filled one is
+			// left to the exit rules rather than dumped at market.
+			cancelled, err := e.exits.Cancel(ctx, p, reason)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("cancel %s: %w", p.ID, err))
+			} else if !cancelled {
+				e.logger.InfoContext(ctx, "cancel signal: entry already filled, position kept", "position_id", p.ID, "symbol", p.Symbol, "source", msg.Source(), "message_id", msg.ID)
+			}
+			continue
+		}
+
+		var price float64
+		if e.prices != nil {
+			var err error
+			if price, err = e.prices.LastPrice(ctx, p.Symbol); err != nil {
+				e.logger.WarnContext(ctx, "exit price unavailable; PnL will not be recorded", "symbol", p.Symbol, "error", err)
+			}
+		}
+		var err error
+		if sig.Kind == signal.KindPartialClose {
+			_, err = e.exits.Reduce(ctx, p, sig.CloseFraction, reason, price)
+		} else {
+			_, err = e.exits.Exit(ctx, p, reason, price)
+		}
+		if err != nil {
+			messagesRejected.Inc("executor_error")
+			errs = append(errs, fmt.Errorf("%s %s: %w", sig.Kind, p.ID, err))
+			continue
 		}
+		ordersSubmitted.Inc(e.executor.Name())
+	}
+	return errors.Join(errs...)
+}
+
+// postedAt is when msg was posted, falling back to its receive time.
+func postedAt(msg signal.Message) time.Time {
+	if !msg.Timestamp.IsZero() {
+		return msg.Timestamp
+	}
+	if !msg.ReceivedAt.IsZero() {
+		return msg.ReceivedAt
+	}
+	return time.Now()
+}
+
+func (e *Engine) notify(ev notify.Event) {
+	if e.notifier != nil {
+		e.notifier.Notify(ev)
+	}
+}
+
+func (e *Engine) resolveNotional(symbol string, profile *channelProfile) float64 {
+	size := e.cfg.Trading.DefaultBaseNotional
+	ov, hasOverride := e.cfg.Overrides[symbol]
+	if hasOverride && ov.DefaultBaseNotional > 0 {
+		size = ov.DefaultBaseNotional
+	}
+	if profile != nil && profile.multiplier > 0 {
+		size *= profile.multiplier
+	}
+	if hasOverride && ov.MaxNotional > 0 && size > ov.MaxNotional {
+		size = ov.MaxNotional
 	}
 	if size > e.cfg.Trading.MaxNotional {
 		size = e.cfg.Trading.MaxNotional
@@ -101,8 +403,12 @@ func (e *Engine) resolveNotional(symbol string) float64 {
 	return size
 }
 
-func (e *Engine) resolveOrderType() exchange.OrderType {
-	switch e.cfg.Trading.OrderType {
+func (e *Engine) resolveOrderType(profile *channelProfile) exchange.OrderType {
+	orderType := e.cfg.Trading.OrderType
+	if profile != nil && profile.orderType != "" {
+		orderType = profile.orderType
+	}
+	switch orderType {
 	case "limit":
 		return exchange.OrderTypeLimit
 	default:
@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/dedupe"
+	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/position"
+	"github.com/user/mexc-bot/internal/risk"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+type recordingExecutor struct {
+	orders []exchange.OrderRequest
+}
+
+func (r *recordingExecutor) Name() string { return "recording" }
+
+func (r *recordingExecutor) Submit(ctx context.Context, req exchange.OrderRequest) (exchange.OrderAck, error) {
+	r.orders = append(r.orders, req)
+	return exchange.OrderAck{OrderID: "test", SubmittedAt: time.Now()}, nil
+}
+
+func testConfig() *config.Config {
+	disabled := false
+	return &config.Config{
+		Trading: config.TradingConfig{
+			DefaultBaseNotional: 200,
+			MaxNotional:         1000,
+			MaxOpenPositions:    5,
+			OrderType:           "market",
+		},
+		Parser: config.ParserConfig{
+			RequiredTokens: []string{"MEGA PUMP SIGNAL"},
+			LinkHost:       "www.mexc.com",
+			LinkPathPrefix: "/exchange/",
+			PairSeparator:  "_",
+		},
+		Risk: config.RiskConfig{MaxDailyTrades: 10},
+		Channels: map[string]config.ChannelConfig{
+			"100": {RequiredTokens: []string{"PUMP ALERT"}, NotionalMultiplier: 0.5, OrderType: "limit"},
+			"200": {Enabled: &disabled},
+		},
+	}
+}
+
+func newTestEngine(t *testing.T, cfg *config.Config, opts ...Option) (*Engine, *recordingExecutor) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	executor := &recordingExecutor{}
+	e, err := New(cfg, signal.NewParser(cfg.Parser), risk.NewSimpleManager(logger, cfg.Risk), executor, logger, opts...)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+	return e, executor
+}
+
+func TestEngineChannelProfiles(t *testing.T) {
+	ctx := context.Background()
+	e, executor := newTestEngine(t, testConfig())
+
+	// Channel 100 uses its own template token, half size and limit orders.
+	if err := e.HandleMessage(ctx, signal.Message{ID: 1, ChatID: 100, ChatType: "channel", Text: "PUMP ALERT https://www.mexc.com/exchange/AAA_USDT"}); err != nil {
+		t.Fatalf("channel 100: unexpected error: %v", err)
+	}
+	if err := e.HandleMessage(ctx, signal.Message{ID: 2, ChatID: 100, ChatType: "channel", Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/BBB_USDT"}); err == nil {
+		t.Fatalf("channel 100: expected global template token to be rejected")
+	}
+
+	// Channel 200 is disabled.
+	if err := e.HandleMessage(ctx, signal.Message{ID: 3, ChatID: 200, ChatType: "channel", Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/CCC_USDT"}); err != nil {
+		t.Fatalf("channel 200: unexpected error: %v", err)
+	}
+	// A webhook sender reusing chat ID 200 is a different source.
+	if err := e.HandleMessage(ctx, signal.Message{ID: 3, ChatID: 200, ChatType: "webhook", Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/EEE_USDT"}); err != nil {
+		t.Fatalf("webhook 200: unexpected error: %v", err)
+	}
+
+	// Unknown channels fall back to the global parser and sizing.
+	if err := e.HandleMessage(ctx, signal.Message{ID: 4, ChatID: 300, Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/DDD_USDT"}); err != nil {
+		t.Fatalf("channel 300: unexpected error: %v", err)
+	}
+
+	if len(executor.orders) != 3 || executor.orders[1].Symbol != "EEEUSDT" {
+		t.Fatalf("expected 3 orders, got %+v", executor.orders)
+	}
+	first, second := executor.orders[0], executor.orders[2]
+	if first.Symbol != "AAAUSDT" || first.Notional != 100 || first.Type != exchange.OrderTypeLimit {
+		t.Fatalf("unexpected channel 100 order: %+v", first)
+	}
+	if second.Symbol != "DDDUSDT" || second.Notional != 200 || second.Type != exchange.OrderTypeMarket {
+		t.Fatalf("unexpected default order: %+v", second)
+	}
+}
+
+func TestEngineRoutesByMarket(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	spot := &recordingExecutor{}
+	e, err := New(cfg, signal.NewParser(cfg.Parser), risk.NewSimpleManager(logger, cfg.Risk), exchange.NewRouter(spot), logger)
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+
+	if err := e.HandleMessage(ctx, signal.Message{ID: 1, ChatID: 300, Text: "MEGA PUMP SIGNAL https://futures.mexc.com/exchange/AAA_USDT"}); err != nil {
+		t.Fatalf("futures: unexpected error: %v", err)
+	}
+	if err := e.HandleMessage(ctx, signal.Message{ID: 2, ChatID: 300, Text: "MEGA PUMP SIGNAL https://www.mexc.com/en-US/exchange/BBB_USDT"}); err != nil {
+		t.Fatalf("spot: unexpected error: %v", err)
+	}
+	if len(spot.orders) != 1 || spot.orders[0].Symbol != "BBBUSDT" || spot.orders[0].Market != exchange.MarketSpot {
+		t.Fatalf("expected only the spot order, got %+v", spot.orders)
+	}
+}
+
+func TestEngineExitSignals(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.Parser.Templates = []config.TemplateConfig{
+		{Name: "close", RequiredTokens: []string{"Close"}, SymbolFrom: []string{"hashtag"}, Kind: "close"},
+		{Name: "partial", RequiredTokens: []string{"Take partial"}, Kind: "partial_close", ChatWide: true},
+		{Name: "open", RequiredTokens: []string{"MEGA PUMP SIGNAL"}},
+	}
+	cfg.Parser.QuoteAsset = "USDT"
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	executor := &recordingExecutor{}
+	book, err := position.NewBook(nil)
+	if err != nil {
+		t.Fatalf("new book: %v", err)
+	}
+	riskManager := risk.NewSimpleManager(logger, cfg.Risk)
+	exits, err := position.NewManager(book, executor, nil, riskManager, "USDT", logger)
+	if err != nil {
+		t.Fatalf("new position manager: %v", err)
+	}
+	e, err := New(cfg, signal.NewParser(cfg.Parser), riskManager, executor, logger, WithPositions(book), WithExits(exits, nil))
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+
+	for i, text := range []string{
+		"MEGA PUMP SIGNAL https://www.mexc.com/exchange/AAA_USDT",
+		"MEGA PUMP SIGNAL https://www.mexc.com/exchange/BBB_USDT",
+		"Take partial profits now: 25%",
+		"Close #AAA now",
+	} {
+		if err := e.HandleMessage(ctx, signal.Message{ID: int64(i + 1), ChatID: 300, Text: text}); err != nil {
+			t.Fatalf("message %d: unexpected error: %v", i+1, err)
+		}
+	}
+
+	if len(executor.orders) != 5 {
+		t.Fatalf("expected 2 buys, 2 partial sells and 1 close, got %+v", executor.orders)
+	}
+	for _, o := range executor.orders[2:4] {
+		if o.Side != exchange.OrderSideSell || o.Notional != 50 {
+			t.Fatalf("expected 25%% partial sells, got %+v", o)
+		}
+	}
+	if last := executor.orders[4]; last.Side != exchange.OrderSideSell || last.Symbol != "AAAUSDT" || last.Notional != 150 {
+		t.Fatalf("expected full close of the AAA remainder, got %+v", last)
+	}
+	open := book.OpenPositions()
+	if len(open) != 1 || open[0].Symbol != "BBBUSDT" || open[0].Notional != 150 {
+		t.Fatalf("expected reduced BBB position to stay open, got %+v", open)
+	}
+}
+
+type cancellingExecutor struct {
+	recordingExecutor
+	cancelled []string
+	resting   []exchange.OpenOrder
+}
+
+func (c *cancellingExecutor) CancelOrder(ctx context.Context, market exchange.Market, symbol, orderID string) error {
+	c.cancelled = append(c.cancelled, orderID)
+	return nil
+}
+
+func (c *cancellingExecutor) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	return nil, nil
+}
+
+func (c *cancellingExecutor) OpenOrders(ctx context.Context, symbol string) ([]exchange.OpenOrder, error) {
+	return c.resting, nil
+}
+
+func TestEngineCancelWithdrawsPendingEntry(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.Parser.Templates = []config.TemplateConfig{
+		{Name: "cancel", RequiredTokens: []string{"Cancel"}, SymbolFrom: []string{"hashtag"}, Kind: "cancel"},
+		{Name: "close", RequiredTokens: []string{"Close"}, Kind: "close"},
+		{Name: "open", RequiredTokens: []string{"MEGA PUMP SIGNAL"}},
+	}
+	cfg.Parser.QuoteAsset = "USDT"
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	executor := &cancellingExecutor{resting: []exchange.OpenOrder{{Symbol: "AAAUSDT", OrderID: "test", OrigQty: 10}}}
+	book, err := position.NewBook(nil)
+	if err != nil {
+		t.Fatalf("new book: %v", err)
+	}
+	riskManager := risk.NewSimpleManager(logger, cfg.Risk)
+	exits, err := position.NewManager(book, executor, executor, riskManager, "USDT", logger)
+	if err != nil {
+		t.Fatalf("new position manager: %v", err)
+	}
+	e, err := New(cfg, signal.NewParser(cfg.Parser), riskManager, executor, logger, WithPositions(book), WithExits(exits, nil))
+	if err != nil {
+		t.Fatalf("new engine: %v", err)
+	}
+
+	posted := time.Now().Add(-time.Minute)
+	open := signal.Message{ID: 1, ChatID: 300, Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/AAA_USDT", Timestamp: posted}
+	messages := []signal.Message{
+		open,
+		{ID: 2, ChatID: 300, Text: "Cancel #AAA", Timestamp: posted.Add(10 * time.Second)},
+		// A late copy of the cancelled call must not re-enter.
+		open,
+	}
+	for i, msg := range messages {
+		if err := e.HandleMessage(ctx, msg); err != nil {
+			t.Fatalf("message %d: unexpected error: %v", i+1, err)
+		}
+	}
+	// A symbol-less close without chat_wide must not touch the chat.
+	if err := e.HandleMessage(ctx, signal.Message{ID: 3, ChatID: 300, Text: "Close everything", Timestamp: posted.Add(20 * time.Second)}); err == nil {
+		t.Fatalf("expected symbol-less close to be rejected")
+	}
+
+	if len(executor.orders) != 1 || executor.orders[0].Side != exchange.OrderSideBuy {
+		t.Fatalf("expected only the original buy and no sells, got %+v", executor.orders)
+	}
+	if len(executor.cancelled) != 1 || executor.cancelled[0] != "test" {
+		t.Fatalf("expected the resting entry cancelled, got %v", executor.cancelled)
+	}
+	if open := book.OpenPositions(); len(open) != 0 {
+		t.Fatalf("expected the unfilled position closed, got %+v", open)
+	}
+
+	// A fresh call posted after the cancel goes through.
+	fresh := signal.Message{ID: 4, ChatID: 300, Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/AAA_USDT", Timestamp: posted.Add(30 * time.Second)}
+	if err := e.HandleMessage(ctx, fresh); err != nil {
+		t.Fatalf("fresh call: unexpected error: %v", err)
+	}
+	if len(executor.orders) != 2 {
+		t.Fatalf("expected the fresh call traded, got %+v", executor.orders)
+	}
+}
+
+func TestEngineEditOfStaleMessage(t *testing.T) {
+	ctx := context.Background()
+	cfg := testConfig()
+	cfg.Ingest.MaxSignalAgeMS = 5000
+	e, executor := newTestEngine(t, cfg, WithDedupe(dedupe.New(time.Minute, 100, dedupe.EditReparseFailed)))
+
+	now := time.Now()
+	posted := now.Add(-time.Minute)
+	original := signal.Message{ID: 1, ChatID: 300, Text: "MEGA PUMP SIGNAL soon", Timestamp: posted, ReceivedAt: now}
+	if err := e.HandleMessage(ctx, original); err != nil {
+		t.Fatalf("stale original: unexpected error: %v", err)
+	}
+
+	// The link is added a minute later; the edit is fresh even though the post is not.
+	edit := original
+	edit.Text, edit.Edited, edit.EditedAt = "MEGA PUMP SIGNAL https://www.mexc.com/exchange/AAA_USDT", true, now.Add(-time.Second)
+	if err := e.HandleMessage(ctx, edit); err != nil {
+		t.Fatalf("edit: unexpected error: %v", err)
+	}
+	if len(executor.orders) != 1 || executor.orders[0].Symbol != "AAAUSDT" {
+		t.Fatalf("expected the edit traded, got %+v", executor.orders)
+	}
+}
+
+func TestEngineRecoveredPolicy(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range []string{"drop", "exits_only"} {
+		cfg := testConfig()
+		cfg.Ingest.RecoveredPolicy = policy
+		e, executor := newTestEngine(t, cfg)
+
+		recovered := signal.Message{ID: 1, ChatID: 300, Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/AAA_USDT", Recovered: true}
+		if err := e.HandleMessage(ctx, recovered); err != nil {
+			t.Fatalf("%s: unexpected error: %v", policy, err)
+		}
+		live := signal.Message{ID: 2, ChatID: 300, Text: "MEGA PUMP SIGNAL https://www.mexc.com/exchange/BBB_USDT"}
+		if err := e.HandleMessage(ctx, live); err != nil {
+			t.Fatalf("%s: unexpected error: %v", policy, err)
+		}
+		if len(executor.orders) != 1 || executor.orders[0].Symbol != "BBBUSDT" {
+			t.Fatalf("%s: expected only the live order, got %+v", policy, executor.orders)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+// skewWindow is how many recent deliveries the clock-skew estimate looks back over.
+const skewWindow = 32
+
+// ageGuard rejects messages whose server timestamp is too far behind the local
+// receive time. Telegram dates come from the server clock, so the guard keeps a
+// running estimate of local-minus-server skew: the smallest delay seen over the
+// last skewWindow deliveries, clamped to maxSkew. Catch-up bursts after a
+// reconnect cannot widen the estimate beyond that clamp.
+type ageGuard struct {
+	maxAge  time.Duration
+	maxSkew time.Duration
+
+	mu     sync.Mutex
+	delays []time.Duration
+	next   int
+}
+
+func newAgeGuard(cfg config.IngestConfig) *ageGuard {
+	return &ageGuard{
+		maxAge:  time.Duration(cfg.MaxSignalAgeMS) * time.Millisecond,
+		maxSkew: time.Duration(cfg.MaxClockSkewMS) * time.Millisecond,
+	}
+}
+
+// check returns the skew-corrected age of msg and whether it is still fresh.
+// Edits are aged from the edit, not the original post. Messages without a
+// timestamp are treated as fresh. Recovered messages are late because of the
+// gap, not the clock, so they do not feed the estimate.
+func (g *ageGuard) check(msg signal.Message) (time.Duration, bool) {
+	posted := msg.Timestamp
+	if msg.Edited && !msg.EditedAt.IsZero() {
+		posted = msg.EditedAt
+	}
+	if g.maxAge <= 0 || posted.IsZero() {
+		return 0, true
+	}
+	received := msg.ReceivedAt
+	if received.IsZero() {
+		received = time.Now()
+	}
+
+	delay := received.Sub(posted)
+	var skew time.Duration
+	if msg.Recovered {
+		skew = g.current()
+	} else {
+		skew = g.observe(delay)
+	}
+	age := delay - skew
+	if age < 0 {
+		age = 0
+	}
+	return age, age <= g.maxAge
+}
+
+// observe records a raw delay and returns the current skew correction.
+func (g *ageGuard) observe(delay time.Duration) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.delays) < skewWindow {
+		g.delays = append(g.delays, delay)
+	} else {
+		g.delays[g.next] = delay
+		g.next = (g.next + 1) % skewWindow
+	}
+	return g.estimate()
+}
+
+// current returns the skew correction without recording a delay.
+func (g *ageGuard) current() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.delays) == 0 {
+		return 0
+	}
+	return g.estimate()
+}
+
+// estimate computes the clamped skew; g.mu must be held and delays non-empty.
+func (g *ageGuard) estimate() time.Duration {
+	skew := g.delays[0]
+	for _, d := range g.delays[1:] {
+		if d < skew {
+			skew = d
+		}
+	}
+	if skew > g.maxSkew {
+		skew = g.maxSkew
+	}
+	if skew < -g.maxSkew {
+		skew = -g.maxSkew
+	}
+	return skew
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+func TestAgeGuardRejectsStaleMessages(t *testing.T) {
+	guard := newAgeGuard(config.IngestConfig{MaxSignalAgeMS: 10000, MaxClockSkewMS: 3000})
+	now := time.Unix(1710000000, 0)
+
+	fresh := signal.Message{Timestamp: now.Add(-2 * time.Second), ReceivedAt: now}
+	if _, ok := guard.check(fresh); !ok {
+		t.Fatalf("expected fresh message accepted")
+	}
+
+	stale := signal.Message{Timestamp: now.Add(-2 * time.Minute), ReceivedAt: now}
+	if age, ok := guard.check(stale); ok {
+		t.Fatalf("expected stale message rejected, age=%v", age)
+	}
+}
+
+func TestAgeGuardCorrectsClockSkew(t *testing.T) {
+	guard := newAgeGuard(config.IngestConfig{MaxSignalAgeMS: 1000, MaxClockSkewMS: 5000})
+	now := time.Unix(1710000000, 0)
+
+	// Local clock runs 4s ahead of Telegram: every live post looks 4s old.
+	for i := 0; i < 5; i++ {
+		msg := signal.Message{Timestamp: now.Add(-4 * time.Second), ReceivedAt: now}
+		if age, ok := guard.check(msg); !ok {
+			t.Fatalf("expected skewed live post accepted, age=%v", age)
+		}
+	}
+
+	// Skew beyond the cap is not forgiven.
+	late := signal.Message{Timestamp: now.Add(-30 * time.Second), ReceivedAt: now}
+	if _, ok := guard.check(late); ok {
+		t.Fatalf("expected late post rejected despite skew correction")
+	}
+}
+
+func TestAgeGuardDisabled(t *testing.T) {
+	guard := newAgeGuard(config.IngestConfig{})
+	msg := signal.Message{Timestamp: time.Unix(0, 0), ReceivedAt: time.Now()}
+	if _, ok := guard.check(msg); !ok {
+		t.Fatalf("expected guard disabled when max age is zero")
+	}
+}
+
+func TestAgeGuardIgnoresRecoveredDelays(t *testing.T) {
+	guard := newAgeGuard(config.IngestConfig{MaxSignalAgeMS: 1000, MaxClockSkewMS: 5000})
+	now := time.Unix(1710000000, 0)
+
+	// A replayed post is late because of the gap; it must neither pass as
+	// skew nor widen the estimate for the next live post.
+	recovered := signal.Message{Timestamp: now.Add(-3 * time.Second), ReceivedAt: now, Recovered: true}
+	if _, ok := guard.check(recovered); ok {
+		t.Fatalf("expected recovered post rejected")
+	}
+	live := signal.Message{Timestamp: now.Add(-3 * time.Second), ReceivedAt: now}
+	if _, ok := guard.check(live); !ok {
+		t.Fatalf("expected first live post to seed the skew estimate")
+	}
+	if _, ok := guard.check(recovered); !ok {
+		t.Fatalf("expected recovered post judged with the live skew estimate")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math"
 	"time"
 )
 
@@ -23,10 +24,21 @@ const (
 	OrderTypeLimit  OrderType = "LIMIT"
 )
 
+// Market is the exchange product an order trades on.
+type Market string
+
+const (
+	MarketSpot    Market = "spot"
+	MarketFutures Market = "futures"
+)
+
 // OrderRequest contains the required data to submit an exchange order.
+// Quantity, when positive, sizes the order in base units instead of quote notional.
 type OrderRequest struct {
 	Symbol      string
+	Market      Market // empty means spot
 	Notional    float64
+	Quantity    float64
 	Side        OrderSide
 	Type        OrderType
 	SlippageBps int
@@ -45,6 +57,66 @@ type Executor interface {
 	Name() string
 }
 
+// Balance is the account holding of a single asset.
+type Balance struct {
+	Asset  string
+	Free   float64
+	Locked float64
+}
+
+// Total returns free plus locked quantity.
+func (b Balance) Total() float64 {
+	return b.Free + b.Locked
+}
+
+// OrderCanceller is implemented by executors that can cancel resting orders.
+type OrderCanceller interface {
+	CancelOrder(ctx context.Context, market Market, symbol, orderID string) error
+}
+
+// OpenOrder is a resting order reported by the exchange.
+type OpenOrder struct {
+	Symbol      string
+	OrderID     string
+	Side        OrderSide
+	Type        OrderType
+	Price       float64
+	OrigQty     float64
+	ExecutedQty float64
+}
+
+// AccountReader exposes the account state needed to reconcile local positions.
+type AccountReader interface {
+	Balances(ctx context.Context) ([]Balance, error)
+	OpenOrders(ctx context.Context, symbol string) ([]OpenOrder, error)
+}
+
+// PriceFeed returns the latest traded price for a symbol.
+type PriceFeed interface {
+	LastPrice(ctx context.Context, symbol string) (float64, error)
+}
+
+// LotSizer reports the base-quantity increment the exchange accepts for a
+// spot symbol; order quantities must be a whole number of steps.
+type LotSizer interface {
+	QuantityStep(ctx context.Context, symbol string) (float64, error)
+}
+
+// FloorToStep rounds qty down to a whole number of steps, trimming float noise
+// so 0.1*3 formats as 0.3. A non-positive step leaves qty unchanged.
+func FloorToStep(qty, step float64) float64 {
+	if step <= 0 {
+		return qty
+	}
+	steps := math.Floor(qty/step + 1e-9)
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step) - 1e-9))
+	}
+	scale := math.Pow10(decimals)
+	return math.Round(steps*step*scale) / scale
+}
+
 // DryRunExecutor logs orders without sending anything to the exchange.
 type DryRunExecutor struct {
 	logger *slog.Logger
@@ -59,8 +131,8 @@ func (d *DryRunExecutor) Name() string {
 }
 
 func (d *DryRunExecutor) Submit(ctx context.Context, req OrderRequest) (OrderAck, error) {
-	d.logger.InfoContext(ctx, "dry-run order", "symbol", req.Symbol, "side", req.Side, "notional", req.Notional, "type", req.Type)
-	if req.Notional <= 0 {
+	d.logger.InfoContext(ctx, "dry-run order", "symbol", req.Symbol, "side", req.Side, "notional", req.Notional, "quantity", req.Quantity, "type", req.Type)
+	if req.Notional <= 0 && req.Quantity <= 0 {
 		return OrderAck{}, fmt.Errorf("invalid notional %f", req.Notional)
 	}
 	return OrderAck{
@@ -68,3 +140,8 @@ func (d *DryRunExecutor) Submit(ctx context.Context, req OrderRequest) (OrderAck
 		SubmittedAt: time.Now(),
 	}, nil
 }
+
+func (d *DryRunExecutor) CancelOrder(ctx context.Context, market Market, symbol, orderID string) error {
+	d.logger.InfoContext(ctx, "dry-run cancel", "symbol", symbol, "market", market, "order_id", orderID)
+	return nil
+}
@@ -0,0 +1,129 @@
+package mexc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mexc-bot/internal/exchange"
+)
+
+// Balances returns non-zero spot balances for the authenticated account.
+func (e *Executor) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	var payload accountResponse
+	if err := e.signedGet(ctx, "/api/v3/account", nil, &payload); err != nil {
+		return nil, fmt.Errorf("fetch account: %w", err)
+	}
+
+	balances := make([]exchange.Balance, 0, len(payload.Balances))
+	for _, b := range payload.Balances {
+		free, _ := strconv.ParseFloat(b.Free, 64)
+		locked, _ := strconv.ParseFloat(b.Locked, 64)
+		if free == 0 && locked == 0 {
+			continue
+		}
+		balances = append(balances, exchange.Balance{
+			Asset:  strings.ToUpper(b.Asset),
+			Free:   free,
+			Locked: locked,
+		})
+	}
+	return balances, nil
+}
+
+// OpenOrders returns resting orders for symbol.
+func (e *Executor) OpenOrders(ctx context.Context, symbol string) ([]exchange.OpenOrder, error) {
+	var payload []openOrderResponse
+	params := map[string]string{"symbol": strings.ToUpper(symbol)}
+	if err := e.signedGet(ctx, "/api/v3/openOrders", params, &payload); err != nil {
+		return nil, fmt.Errorf("fetch open orders %s: %w", symbol, err)
+	}
+
+	orders := make([]exchange.OpenOrder, 0, len(payload))
+	for _, o := range payload {
+		price, _ := strconv.ParseFloat(o.Price, 64)
+		origQty, _ := strconv.ParseFloat(o.OrigQty, 64)
+		executedQty, _ := strconv.ParseFloat(o.ExecutedQty, 64)
+		orders = append(orders, exchange.OpenOrder{
+			Symbol:      o.Symbol,
+			OrderID:     o.OrderID,
+			Side:        exchange.OrderSide(o.Side),
+			Type:        exchange.OrderType(o.Type),
+			Price:       price,
+			OrigQty:     origQty,
+			ExecutedQty: executedQty,
+		})
+	}
+	return orders, nil
+}
+
+// CancelOrder cancels a resting spot order.
+func (e *Executor) CancelOrder(ctx context.Context, market exchange.Market, symbol, orderID string) error {
+	if market != "" && market != exchange.MarketSpot {
+		return fmt.Errorf("market %s not yet supported", market)
+	}
+	params := map[string]string{"symbol": strings.ToUpper(symbol), "orderId": orderID}
+	var payload struct {
+		OrderID string `json:"orderId"`
+		Status  string `json:"status"`
+	}
+	if err := e.signedRequest(ctx, http.MethodDelete, "/api/v3/order", params, &payload); err != nil {
+		return fmt.Errorf("cancel order %s %s: %w", symbol, orderID, err)
+	}
+	return nil
+}
+
+func (e *Executor) signedGet(ctx context.Context, path string, params map[string]string, out any) error {
+	return e.signedRequest(ctx, http.MethodGet, path, params, out)
+}
+
+func (e *Executor) signedRequest(ctx context.Context, method, path string, params map[string]string, out any) error {
+	signed := map[string]string{
+		"timestamp":  strconv.FormatInt(time.Now().UnixMilli(), 10),
+		"recvWindow": "5000",
+	}
+	for k, v := range params {
+		signed[k] = v
+	}
+	query := canonicalQuery(signed)
+	endpoint := e.baseURL + path + "?" + query + "&signature=" + e.sign(query)
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("X-MEXC-APIKEY", e.apiKey)
+
+	resp, err := e.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+type accountResponse struct {
+	Balances []struct {
+		Asset  string `json:"asset"`
+		Free   string `json:"free"`
+		Locked string `json:"locked"`
+	} `json:"balances"`
+}
+
+type openOrderResponse struct {
+	Symbol      string `json:"symbol"`
+	OrderID     string `json:"orderId"`
+	Price       string `json:"price"`
+	OrigQty     string `json:"origQty"`
+	ExecutedQty string `json:"executedQty"`
+	Type        string `json:"type"`
+	Side        string `json:"side"`
+}
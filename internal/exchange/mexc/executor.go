@@ -27,6 +27,7 @@ type Executor struct {
 	apiSecret string
 	baseURL   string
 	market    string
+	*MarketData
 }
 
 // NewExecutor constructs a live MEXC executor.
@@ -41,14 +42,19 @@ func NewExecutor(cfg *config.Config, apiKey, apiSecret string, logger *slog.Logg
 	if err != nil {
 		return nil, err
 	}
+	market, err := NewMarketData(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	return &Executor{
-		logger:    logger,
-		client:    &http.Client{Timeout: 5 * time.Second},
-		apiKey:    apiKey,
-		apiSecret: apiSecret,
-		baseURL:   baseURL,
-		market:    cfg.Mode.MarketType,
+		logger:     logger,
+		client:     &http.Client{Timeout: 5 * time.Second},
+		apiKey:     apiKey,
+		apiSecret:  apiSecret,
+		baseURL:    baseURL,
+		market:     cfg.Mode.MarketType,
+		MarketData: market,
 	}, nil
 }
 
@@ -86,8 +92,12 @@ func (e *Executor) submitSpot(ctx context.Context, req exchange.OrderRequest) (e
 	}
 
 	if req.Type == exchange.OrderTypeMarket {
-		// Use quoteOrderQty to target notional size.
-		params["quoteOrderQty"] = formatFloat(req.Notional)
+		if req.Quantity > 0 {
+			params["quantity"] = formatFloat(req.Quantity)
+		} else {
+			// Use quoteOrderQty to target notional size.
+			params["quoteOrderQty"] = formatFloat(req.Notional)
+		}
 	}
 
 	query := canonicalQuery(params)
@@ -0,0 +1,148 @@
+package mexc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+)
+
+// MarketData reads public MEXC spot endpoints; it needs no API credentials and
+// is safe to use in dry-run mode.
+type MarketData struct {
+	client  *http.Client
+	baseURL string
+
+	mu    sync.Mutex
+	steps map[string]float64 // symbol -> base quantity step
+}
+
+// NewMarketData constructs a public market data client for the configured environment.
+func NewMarketData(cfg *config.Config) (*MarketData, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	baseURL, err := resolveBaseURL(cfg.Mode.Environment)
+	if err != nil {
+		return nil, err
+	}
+	return &MarketData{
+		client:  &http.Client{Timeout: 5 * time.Second},
+		baseURL: baseURL,
+		steps:   make(map[string]float64),
+	}, nil
+}
+
+// LastPrice returns the latest traded price for symbol.
+func (m *MarketData) LastPrice(ctx context.Context, symbol string) (float64, error) {
+	query := url.Values{"symbol": {strings.ToUpper(symbol)}}
+	var payload tickerPriceResponse
+	if err := m.getJSON(ctx, "/api/v3/ticker/price?"+query.Encode(), &payload); err != nil {
+		return 0, err
+	}
+	price, err := strconv.ParseFloat(payload.Price, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse price %q: %w", payload.Price, err)
+	}
+	return price, nil
+}
+
+// Symbols returns the spot symbols currently open for trading, e.g. TWIFUSDT.
+func (m *MarketData) Symbols(ctx context.Context) ([]string, error) {
+	var payload exchangeInfoResponse
+	if err := m.getJSON(ctx, "/api/v3/exchangeInfo", &payload); err != nil {
+		return nil, err
+	}
+	symbols := make([]string, 0, len(payload.Symbols))
+	for _, s := range payload.Symbols {
+		// MEXC reports status "1" (or "ENABLED" on older responses) for tradable pairs.
+		if (s.Status == "1" || s.Status == "ENABLED") && s.IsSpotTradingAllowed {
+			symbols = append(symbols, s.Symbol)
+		}
+	}
+	return symbols, nil
+}
+
+// QuantityStep returns the base quantity increment MEXC accepts for symbol:
+// baseSizePrecision when it is set, otherwise one unit in the last place of
+// baseAssetPrecision. Steps are cached for the life of the process.
+func (m *MarketData) QuantityStep(ctx context.Context, symbol string) (float64, error) {
+	symbol = strings.ToUpper(symbol)
+	m.mu.Lock()
+	step, ok := m.steps[symbol]
+	m.mu.Unlock()
+	if ok {
+		return step, nil
+	}
+
+	query := url.Values{"symbol": {symbol}}
+	var payload exchangeInfoResponse
+	if err := m.getJSON(ctx, "/api/v3/exchangeInfo?"+query.Encode(), &payload); err != nil {
+		return 0, err
+	}
+	for _, s := range payload.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+		step, _ = strconv.ParseFloat(s.BaseSizePrecision, 64)
+		if step <= 0 {
+			step = math.Pow10(-s.BaseAssetPrecision)
+		}
+		m.mu.Lock()
+		m.steps[symbol] = step
+		m.mu.Unlock()
+		return step, nil
+	}
+	return 0, fmt.Errorf("symbol %s not listed", symbol)
+}
+
+func (m *MarketData) getJSON(ctx context.Context, path string, out any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, m.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := m.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return decodeError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s: %w", path, err)
+	}
+	return nil
+}
+
+func decodeError(resp *http.Response) error {
+	var apiErr mexcError
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+		return fmt.Errorf("request rejected status %d", resp.StatusCode)
+	}
+	return fmt.Errorf("request rejected: %s (%d)", apiErr.Msg, apiErr.Code)
+}
+
+type tickerPriceResponse struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+type exchangeInfoResponse struct {
+	Symbols []struct {
+		Symbol               string `json:"symbol"`
+		Status               string `json:"status"`
+		IsSpotTradingAllowed bool   `json:"isSpotTradingAllowed"`
+		BaseAssetPrecision   int    `json:"baseAssetPrecision"`
+		BaseSizePrecision    string `json:"baseSizePrecision"`
+	} `json:"symbols"`
+}
@@ -0,0 +1,69 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Router sends each order to the executor registered for its market. Orders
+// without a market go to spot.
+type Router struct {
+	executors map[Market]Executor
+}
+
+// NewRouter returns a router with spot registered; further markets are added
+// with Register.
+func NewRouter(spot Executor) *Router {
+	return &Router{executors: map[Market]Executor{MarketSpot: spot}}
+}
+
+// Register routes orders for market to executor.
+func (r *Router) Register(market Market, executor Executor) {
+	r.executors[market] = executor
+}
+
+// Supports reports whether an executor is registered for market.
+func (r *Router) Supports(market Market) bool {
+	_, ok := r.executors[marketOrSpot(market)]
+	return ok
+}
+
+// Name lists the registered executors, e.g. "futures=dry-run,spot=mexc".
+func (r *Router) Name() string {
+	parts := make([]string, 0, len(r.executors))
+	for market, executor := range r.executors {
+		parts = append(parts, string(market)+"="+executor.Name())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (r *Router) Submit(ctx context.Context, req OrderRequest) (OrderAck, error) {
+	executor, ok := r.executors[marketOrSpot(req.Market)]
+	if !ok {
+		return OrderAck{}, fmt.Errorf("no executor for %s market", req.Market)
+	}
+	return executor.Submit(ctx, req)
+}
+
+// CancelOrder forwards to the market's executor when it can cancel orders.
+func (r *Router) CancelOrder(ctx context.Context, market Market, symbol, orderID string) error {
+	executor, ok := r.executors[marketOrSpot(market)]
+	if !ok {
+		return fmt.Errorf("no executor for %s market", market)
+	}
+	canceller, ok := executor.(OrderCanceller)
+	if !ok {
+		return fmt.Errorf("%s executor cannot cancel orders", executor.Name())
+	}
+	return canceller.CancelOrder(ctx, market, symbol, orderID)
+}
+
+func marketOrSpot(m Market) Market {
+	if m == "" {
+		return MarketSpot
+	}
+	return m
+}
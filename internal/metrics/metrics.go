@@ -0,0 +1,256 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default is the process-wide registry the bot's packages record into.
+var Default = NewRegistry()
+
+// Registry holds counters and histograms and renders them in the Prometheus
+// text exposition format.
+type Registry struct {
+	mu         sync.Mutex
+	counters   map[string]*CounterVec
+	histograms map[string]*HistogramVec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		counters:   make(map[string]*CounterVec),
+		histograms: make(map[string]*HistogramVec),
+	}
+}
+
+// Counter returns the counter family name, creating it on first use.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+	c := &CounterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+	r.counters[name] = c
+	return c
+}
+
+// Histogram returns the histogram family name with the given upper bounds,
+// creating it on first use.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if h, ok := r.histograms[name]; ok {
+		return h
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: sorted, series: make(map[string]*histogram)}
+	r.histograms[name] = h
+	return h
+}
+
+// CounterVec is a family of monotonically increasing counters keyed by label values.
+type CounterVec struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+// Inc adds one to the series identified by labelValues.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the series identified by labelValues.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	key := seriesKey(labelValues)
+	c.mu.Lock()
+	c.values[key] += v
+	c.mu.Unlock()
+}
+
+// Value returns the current value of a series.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[seriesKey(labelValues)]
+}
+
+// HistogramVec is a family of cumulative histograms keyed by label values.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records v in the series identified by labelValues.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, bound := range h.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// WriteText renders every family in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	counters := make([]*CounterVec, 0, len(r.counters))
+	for _, c := range r.counters {
+		counters = append(counters, c)
+	}
+	histograms := make([]*HistogramVec, 0, len(r.histograms))
+	for _, h := range r.histograms {
+		histograms = append(histograms, h)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(counters, func(i, j int) bool { return counters[i].name < counters[j].name })
+	sort.Slice(histograms, func(i, j int) bool { return histograms[i].name < histograms[j].name })
+
+	var buf bytes.Buffer
+	for _, c := range counters {
+		c.mu.Lock()
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+		for _, key := range sortedKeys(c.values) {
+			fmt.Fprintf(&buf, "%s%s %s\n", c.name, formatLabels(c.labels, key, ""), formatValue(c.values[key]))
+		}
+		c.mu.Unlock()
+	}
+	for _, h := range histograms {
+		h.mu.Lock()
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+		for _, key := range sortedKeys(h.series) {
+			s := h.series[key]
+			for i, bound := range h.buckets {
+				fmt.Fprintf(&buf, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, formatValue(bound)), s.counts[i])
+			}
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "+Inf"), s.count)
+			fmt.Fprintf(&buf, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, ""), formatValue(s.sum))
+			fmt.Fprintf(&buf, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, ""), s.count)
+		}
+		h.mu.Unlock()
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Push replaces this job's metrics on a Prometheus Pushgateway.
+func (r *Registry) Push(ctx context.Context, endpoint, job string) error {
+	var body bytes.Buffer
+	if err := r.WriteText(&body); err != nil {
+		return err
+	}
+	target := strings.TrimRight(endpoint, "/") + "/metrics/job/" + url.PathEscape(job)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("pushgateway status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RunPusher pushes every interval until ctx is cancelled.
+func (r *Registry) RunPusher(ctx context.Context, endpoint, job string, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Push(ctx, endpoint, job); err != nil && ctx.Err() == nil {
+				logger.Warn("push metrics", "endpoint", endpoint, "error", err)
+			}
+		}
+	}
+}
+
+const labelSep = "\xff"
+
+func seriesKey(values []string) string {
+	return strings.Join(values, labelSep)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatLabels(names []string, key, le string) string {
+	var parts []string
+	if len(names) > 0 {
+		values := strings.Split(key, labelSep)
+		for i, name := range names {
+			var v string
+			if i < len(values) {
+				v = values[i]
+			}
+			parts = append(parts, name+"="+strconv.Quote(v))
+		}
+	}
+	if le != "" {
+		parts = append(parts, `le="`+le+`"`)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteTextExposition(t *testing.T) {
+	r := NewRegistry()
+	rejected := r.Counter("bot_rejected_total", "Rejected messages.", "reason")
+	rejected.Inc("stale")
+	rejected.Add(2, `quote"d`)
+	r.Counter("bot_started_total", "Starts.").Inc()
+	latency := r.Histogram("bot_latency_seconds", "Latency.", []float64{1, 0.1}, "account")
+	latency.Observe(0.05, "a")
+	latency.Observe(0.5, "a")
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := `# HELP bot_rejected_total Rejected messages.
+# TYPE bot_rejected_total counter
+bot_rejected_total{reason="quote\"d"} 2
+bot_rejected_total{reason="stale"} 1
+# HELP bot_started_total Starts.
+# TYPE bot_started_total counter
+bot_started_total 1
+# HELP bot_latency_seconds Latency.
+# TYPE bot_latency_seconds histogram
+bot_latency_seconds_bucket{account="a",le="0.1"} 1
+bot_latency_seconds_bucket{account="a",le="1"} 2
+bot_latency_seconds_bucket{account="a",le="+Inf"} 2
+bot_latency_seconds_sum{account="a"} 0.55
+bot_latency_seconds_count{account="a"} 2
+`
+	if got := b.String(); got != want {
+		t.Fatalf("unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+	if got := rejected.Value("stale"); got != 1 {
+		t.Fatalf("expected counter value 1, got %v", got)
+	}
+}
@@ -0,0 +1,249 @@
+// Package notify turns trading events into short chat messages, rate limited
+// and rendered from configurable templates, so operators learn what the bot
+// did without watching logs.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/metrics"
+)
+
+var (
+	notificationsSent = metrics.Default.Counter("mexc_bot_notifications_sent_total",
+		"Notifications delivered, by event kind.", "kind")
+	notificationsDropped = metrics.Default.Counter("mexc_bot_notifications_dropped_total",
+		"Notifications not delivered, by reason (queue_full, rate_limited, send_error).", "reason")
+)
+
+// Kind identifies an event type; it is also the key of [notify.templates].
+type Kind string
+
+const (
+	KindOrderSubmitted Kind = "order_submitted"
+	// KindOrderFilled is reported when an entry's price is established. MEXC
+	// acks carry no fill, so market entries by notional are priced at the
+	// first observation of the exit monitor.
+	KindOrderFilled    Kind = "order_filled"
+	KindPositionExited Kind = "position_exited"
+	KindRiskDenied     Kind = "risk_denied"
+	KindExecutorFailed Kind = "executor_failed"
+)
+
+// Kinds lists every event kind in display order.
+var Kinds = []Kind{KindOrderSubmitted, KindOrderFilled, KindPositionExited, KindRiskDenied, KindExecutorFailed}
+
+var defaultTemplates = map[Kind]string{
+	KindOrderSubmitted: `Order submitted: {{.Side}} {{.Symbol}} {{.Market}} for {{printf "%.2f" .Notional}}{{if .OrderID}} (order {{.OrderID}}){{end}}{{if .Source}} from {{.Source}}{{end}}`,
+	KindOrderFilled:    `Entry filled: {{.Symbol}} {{printf "%g" .Quantity}} @ {{printf "%g" .Price}}`,
+	KindPositionExited: `{{if .Partial}}Reduced {{.Symbol}} by {{printf "%.0f" (pct .Fraction)}}%{{else}}Exited {{.Symbol}}{{end}} ({{.Reason}}){{if .Price}} @ {{printf "%g" .Price}}{{end}}{{if not .Partial}}{{if .Price}}: PnL {{printf "%+.2f" .PnL}} ({{printf "%+.2f" .PnLPct}}%){{end}}{{end}}`,
+	KindRiskDenied:     `Signal {{.Symbol}} denied by risk: {{.Reason}}{{if .Source}} (from {{.Source}}){{end}}`,
+	KindExecutorFailed: `Order failed: {{.Side}} {{.Symbol}}{{if .Reason}} ({{.Reason}}){{end}}: {{.Error}}`,
+}
+
+// Event is one notification. Fields that do not apply to a kind are zero.
+type Event struct {
+	Kind     Kind
+	Time     time.Time
+	Symbol   string
+	Market   string
+	Side     string
+	OrderID  string
+	Notional float64
+	Quantity float64
+	Price    float64
+	PnL      float64
+	Fraction float64 // share of the position sold by a partial exit
+	Reason   string  // risk denial or exit reason
+	Source   string  // signal source chat
+	Error    string
+}
+
+// Partial reports whether the event is a partial exit.
+func (e Event) Partial() bool {
+	return e.Fraction > 0 && e.Fraction < 1
+}
+
+// PnLPct is the realised PnL as a percentage of the position notional.
+func (e Event) PnLPct() float64 {
+	if e.Notional <= 0 {
+		return 0
+	}
+	return e.PnL / e.Notional * 100
+}
+
+// Sink receives events. Implementations must not block the caller.
+type Sink interface {
+	Notify(Event)
+}
+
+// Sender delivers rendered text to the configured chat.
+type Sender interface {
+	Send(ctx context.Context, text string) error
+}
+
+// Notifier queues events and sends them from Run, so a slow or failing chat
+// never delays trading.
+type Notifier struct {
+	logger       *slog.Logger
+	sender       Sender
+	templates    map[Kind]*template.Template
+	maxPerMinute int
+	queue        chan Event
+
+	sent       []time.Time // send times within the last minute
+	suppressed int
+}
+
+var _ Sink = (*Notifier)(nil)
+
+// New builds a notifier for the configured events; templates in cfg override
+// the defaults.
+func New(cfg config.NotifyConfig, sender Sender, logger *slog.Logger) (*Notifier, error) {
+	if sender == nil {
+		return nil, errors.New("notify sender must not be nil")
+	}
+	known := make(map[Kind]bool, len(Kinds))
+	for _, k := range Kinds {
+		known[k] = true
+	}
+	for key := range cfg.Templates {
+		if !known[Kind(key)] {
+			return nil, fmt.Errorf("notify template for unknown event %q", key)
+		}
+	}
+
+	enabled := Kinds
+	if len(cfg.Events) > 0 {
+		enabled = nil
+		for _, e := range cfg.Events {
+			if !known[Kind(e)] {
+				return nil, fmt.Errorf("notify event %q is unknown (expected one of %s)", e, kindList())
+			}
+			enabled = append(enabled, Kind(e))
+		}
+	}
+
+	funcs := template.FuncMap{"pct": func(f float64) float64 { return f * 100 }}
+	templates := make(map[Kind]*template.Template, len(enabled))
+	for _, k := range enabled {
+		text := defaultTemplates[k]
+		if custom, ok := cfg.Templates[string(k)]; ok {
+			text = custom
+		}
+		tpl, err := template.New(string(k)).Funcs(funcs).Parse(text)
+		if err == nil {
+			// Catch references to unknown fields at startup, not on the first trade.
+			err = tpl.Execute(io.Discard, Event{Kind: k})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("notify template %s: %w", k, err)
+		}
+		templates[k] = tpl
+	}
+
+	maxPerMinute := cfg.MaxPerMinute
+	if maxPerMinute <= 0 {
+		maxPerMinute = 20
+	}
+	return &Notifier{
+		logger:       logger,
+		sender:       sender,
+		templates:    templates,
+		maxPerMinute: maxPerMinute,
+		queue:        make(chan Event, 64),
+	}, nil
+}
+
+// Notify queues ev if its kind is enabled, dropping it when the queue is full.
+func (n *Notifier) Notify(ev Event) {
+	if _, ok := n.templates[ev.Kind]; !ok {
+		return
+	}
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	select {
+	case n.queue <- ev:
+	default:
+		notificationsDropped.Inc("queue_full")
+	}
+}
+
+// Run sends queued events until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev := <-n.queue:
+			n.deliver(ctx, ev)
+		}
+	}
+}
+
+func (n *Notifier) deliver(ctx context.Context, ev Event) {
+	if !n.allow(time.Now()) {
+		n.suppressed++
+		notificationsDropped.Inc("rate_limited")
+		return
+	}
+	text, err := n.render(ev)
+	if err != nil {
+		n.logger.WarnContext(ctx, "render notification", "kind", ev.Kind, "error", err)
+		return
+	}
+	if n.suppressed > 0 {
+		text += fmt.Sprintf("\n(%d earlier notifications suppressed by rate limit)", n.suppressed)
+		n.suppressed = 0
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := n.sender.Send(sendCtx, text); err != nil {
+		notificationsDropped.Inc("send_error")
+		n.logger.WarnContext(ctx, "send notification", "kind", ev.Kind, "error", err)
+		return
+	}
+	notificationsSent.Inc(string(ev.Kind))
+}
+
+// allow applies the per-minute limit with a sliding window.
+func (n *Notifier) allow(now time.Time) bool {
+	kept := n.sent[:0]
+	for _, at := range n.sent {
+		if now.Sub(at) < time.Minute {
+			kept = append(kept, at)
+		}
+	}
+	n.sent = kept
+	if len(n.sent) >= n.maxPerMinute {
+		return false
+	}
+	n.sent = append(n.sent, now)
+	return true
+}
+
+func (n *Notifier) render(ev Event) (string, error) {
+	var b strings.Builder
+	if err := n.templates[ev.Kind].Execute(&b, ev); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func kindList() string {
+	names := make([]string, len(Kinds))
+	for i, k := range Kinds {
+		names[i] = string(k)
+	}
+	return strings.Join(names, ", ")
+}
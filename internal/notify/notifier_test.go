@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+)
+
+type recordingSender struct {
+	sent []string
+}
+
+func (r *recordingSender) Send(ctx context.Context, text string) error {
+	r.sent = append(r.sent, text)
+	return nil
+}
+
+func newTestNotifier(t *testing.T, cfg config.NotifyConfig) (*Notifier, *recordingSender) {
+	t.Helper()
+	sender := &recordingSender{}
+	n, err := New(cfg, sender, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new notifier: %v", err)
+	}
+	return n, sender
+}
+
+// drain delivers every queued event.
+func drain(n *Notifier) {
+	for {
+		select {
+		case ev := <-n.queue:
+			n.deliver(context.Background(), ev)
+		default:
+			return
+		}
+	}
+}
+
+func TestNotifierRendersTemplates(t *testing.T) {
+	n, sender := newTestNotifier(t, config.NotifyConfig{
+		Events:    []string{"order_submitted", "position_exited"},
+		Templates: map[string]string{"order_submitted": "BUY {{.Symbol}} #{{.OrderID}}"},
+	})
+
+	n.Notify(Event{Kind: KindOrderSubmitted, Symbol: "ABCUSDT", OrderID: "42"})
+	n.Notify(Event{Kind: KindPositionExited, Symbol: "ABCUSDT", Notional: 100, Price: 1.5, PnL: 12.5, Fraction: 1, Reason: "take_profit"})
+	n.Notify(Event{Kind: KindPositionExited, Symbol: "ABCUSDT", Notional: 50, Price: 1.4, Fraction: 0.5, Reason: "signal_partial_close"})
+	n.Notify(Event{Kind: KindRiskDenied, Symbol: "ABCUSDT", Reason: "max_open_positions"}) // not enabled
+	drain(n)
+
+	want := []string{
+		"BUY ABCUSDT #42",
+		"Exited ABCUSDT (take_profit) @ 1.5: PnL +12.50 (+12.50%)",
+		"Reduced ABCUSDT by 50% (signal_partial_close) @ 1.4",
+	}
+	if strings.Join(sender.sent, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected notifications:\n%s", strings.Join(sender.sent, "\n"))
+	}
+}
+
+func TestNotifierRateLimits(t *testing.T) {
+	n, sender := newTestNotifier(t, config.NotifyConfig{MaxPerMinute: 2})
+
+	for i := 0; i < 5; i++ {
+		n.Notify(Event{Kind: KindExecutorFailed, Side: "BUY", Symbol: "ABCUSDT", Error: "timeout"})
+	}
+	drain(n)
+	if len(sender.sent) != 2 {
+		t.Fatalf("expected 2 notifications within the limit, got %d", len(sender.sent))
+	}
+
+	// Once the window has passed the next message reports what was dropped.
+	for i := range n.sent {
+		n.sent[i] = n.sent[i].Add(-time.Minute)
+	}
+	n.Notify(Event{Kind: KindExecutorFailed, Side: "BUY", Symbol: "XYZUSDT", Error: "timeout"})
+	drain(n)
+	last := sender.sent[len(sender.sent)-1]
+	if !strings.HasPrefix(last, "Order failed: BUY XYZUSDT: timeout") || !strings.Contains(last, "3 earlier notifications suppressed") {
+		t.Fatalf("unexpected notification %q", last)
+	}
+}
+
+func TestNewRejectsUnknownEvents(t *testing.T) {
+	sender := &recordingSender{}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if _, err := New(config.NotifyConfig{Events: []string{"fills"}}, sender, logger); err == nil {
+		t.Fatalf("expected unknown event to be rejected")
+	}
+	if _, err := New(config.NotifyConfig{Templates: map[string]string{"order_submitted": "{{.Nope"}}, sender, logger); err == nil {
+		t.Fatalf("expected malformed template to be rejected")
+	}
+	if _, err := New(config.NotifyConfig{Templates: map[string]string{"order_submitted": "{{.Ticker}}"}}, sender, logger); err == nil {
+		t.Fatalf("expected template with unknown field to be rejected")
+	}
+}
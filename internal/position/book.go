@@ -0,0 +1,213 @@
+package position
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+// Status tracks the lifecycle of a position.
+type Status string
+
+const (
+	StatusOpen   Status = "open"
+	StatusClosed Status = "closed"
+)
+
+// Position is a holding opened by the bot from a signal.
+type Position struct {
+	ID              string          `json:"id"`
+	Symbol          string          `json:"symbol"`
+	Market          exchange.Market `json:"market,omitempty"` // empty for spot
+	OrderID         string          `json:"order_id,omitempty"`
+	Notional        float64         `json:"notional"`
+	Quantity        float64         `json:"quantity,omitempty"`
+	EntryPrice      float64         `json:"entry_price,omitempty"`
+	SourceChatID    int64           `json:"source_chat_id,omitempty"`
+	SourceChatType  string          `json:"source_chat_type,omitempty"`
+	SourceMessageID int64           `json:"source_message_id,omitempty"`
+	Adopted         bool            `json:"adopted,omitempty"`
+	OpenedAt        time.Time       `json:"opened_at"`
+	Status          Status          `json:"status"`
+	ClosedAt        time.Time       `json:"closed_at,omitempty"`
+	CloseReason     string          `json:"close_reason,omitempty"`
+	ExitPrice       float64         `json:"exit_price,omitempty"`
+	RealizedPnL     float64         `json:"realized_pnl,omitempty"`
+
+	// Channel-quoted exit levels, used when risk.use_signal_levels is set.
+	Target *signal.Level `json:"target,omitempty"`
+	Stop   *signal.Level `json:"stop,omitempty"`
+}
+
+// IsSpot reports whether the position is a spot holding.
+func (p Position) IsSpot() bool {
+	return p.Market == "" || p.Market == exchange.MarketSpot
+}
+
+// FromChat reports whether the position was opened from chat. Positions
+// recorded before the chat type was stored came from Telegram and match any
+// Telegram chat with their ID.
+func (p Position) FromChat(chat signal.ChatKey) bool {
+	if p.SourceChatID != chat.ID {
+		return false
+	}
+	if p.SourceChatType == "" && (chat.Type == "channel" || chat.Type == "chat" || chat.Type == "user") {
+		return true
+	}
+	return p.SourceChatType == chat.Type
+}
+
+// PnL returns the unrealised profit in quote currency at price, or zero when the
+// entry price is unknown.
+func (p Position) PnL(price float64) float64 {
+	if p.EntryPrice <= 0 {
+		return 0
+	}
+	qty := p.Quantity
+	if qty <= 0 {
+		qty = p.Notional / p.EntryPrice
+	}
+	return (price - p.EntryPrice) * qty
+}
+
+// Store persists positions across restarts.
+type Store interface {
+	SavePosition(p Position) error
+	LoadPositions() ([]Position, error)
+}
+
+var ErrNotFound = errors.New("position not found")
+
+// Book keeps the set of open positions, writing every change through to the store.
+type Book struct {
+	store Store
+
+	mu        sync.RWMutex
+	positions map[string]*Position
+}
+
+// NewBook restores open positions from store; a nil store keeps positions in memory only.
+func NewBook(store Store) (*Book, error) {
+	b := &Book{
+		store:     store,
+		positions: make(map[string]*Position),
+	}
+	if store == nil {
+		return b, nil
+	}
+
+	saved, err := store.LoadPositions()
+	if err != nil {
+		return nil, fmt.Errorf("load positions: %w", err)
+	}
+	for i := range saved {
+		if saved[i].Status != StatusOpen {
+			continue
+		}
+		p := saved[i]
+		b.positions[p.ID] = &p
+	}
+	return b, nil
+}
+
+// Open records a new open position. An empty ID is derived from the symbol and open time.
+func (b *Book) Open(p Position) (Position, error) {
+	if p.Symbol == "" {
+		return Position{}, errors.New("position symbol required")
+	}
+	if p.OpenedAt.IsZero() {
+		p.OpenedAt = time.Now()
+	}
+	if p.ID == "" {
+		p.ID = fmt.Sprintf("%s-%d", p.Symbol, p.OpenedAt.UnixNano())
+	}
+	p.Status = StatusOpen
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.save(p); err != nil {
+		return Position{}, err
+	}
+	b.positions[p.ID] = &p
+	return p, nil
+}
+
+// Update replaces the stored fields of an open position.
+func (b *Book) Update(p Position) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.positions[p.ID]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, p.ID)
+	}
+	p.Status = StatusOpen
+	if err := b.save(p); err != nil {
+		return err
+	}
+	b.positions[p.ID] = &p
+	return nil
+}
+
+// Close marks the position closed and drops it from the open set. A positive
+// exitPrice records the realised PnL against the entry price.
+func (b *Book) Close(id, reason string, exitPrice float64, at time.Time) (Position, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.positions[id]
+	if !ok {
+		return Position{}, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	closed := *p
+	closed.Status = StatusClosed
+	closed.ClosedAt = at
+	closed.CloseReason = reason
+	if exitPrice > 0 {
+		closed.ExitPrice = exitPrice
+		closed.RealizedPnL = closed.PnL(exitPrice)
+	}
+	if err := b.save(closed); err != nil {
+		return Position{}, err
+	}
+	delete(b.positions, id)
+	return closed, nil
+}
+
+// OpenPositions returns a snapshot of open positions ordered by open time.
+func (b *Book) OpenPositions() []Position {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	out := make([]Position, 0, len(b.positions))
+	for _, p := range b.positions {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].OpenedAt.Before(out[j].OpenedAt) })
+	return out
+}
+
+// BySymbol returns open positions for symbol, oldest first.
+func (b *Book) BySymbol(symbol string) []Position {
+	var out []Position
+	for _, p := range b.OpenPositions() {
+		if p.Symbol == symbol {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func (b *Book) save(p Position) error {
+	if b.store == nil {
+		return nil
+	}
+	if err := b.store.SavePosition(p); err != nil {
+		return fmt.Errorf("persist position %s: %w", p.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package position
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/notify"
+	"github.com/user/mexc-bot/internal/risk"
+)
+
+// Manager exits positions through the executor and keeps the book and risk
+// counters in step.
+type Manager struct {
+	logger   *slog.Logger
+	book     *Book
+	executor exchange.Executor
+	account  exchange.AccountReader
+	risk     risk.Manager
+	quote    string
+	notifier notify.Sink
+	lots     exchange.LotSizer
+
+	mu      sync.Mutex
+	failing map[string]int // position ID -> consecutive exit submission failures
+}
+
+// NewManager wires the exit path. account may be nil (e.g. dry-run), in which
+// case exit sizes come from the position alone.
+func NewManager(book *Book, executor exchange.Executor, account exchange.AccountReader, riskManager risk.Manager, quoteAsset string, logger *slog.Logger) (*Manager, error) {
+	if book == nil {
+		return nil, errors.New("position book must not be nil")
+	}
+	if executor == nil {
+		return nil, errors.New("executor must not be nil")
+	}
+	if riskManager == nil {
+		return nil, errors.New("risk manager must not be nil")
+	}
+	return &Manager{
+		logger:   logger,
+		book:     book,
+		executor: executor,
+		account:  account,
+		risk:     riskManager,
+		quote:    strings.ToUpper(quoteAsset),
+		failing:  make(map[string]int),
+	}, nil
+}
+
+// SetNotifier reports exits, entry fills and exit failures to sink.
+func (m *Manager) SetNotifier(sink notify.Sink) {
+	m.notifier = sink
+}
+
+// SetLotSizer rounds partial exit quantities to the exchange's step. Without
+// one, partial exits of spot positions sell by notional instead.
+func (m *Manager) SetLotSizer(lots exchange.LotSizer) {
+	m.lots = lots
+}
+
+// Book exposes the underlying position book.
+func (m *Manager) Book() *Book {
+	return m.book
+}
+
+// Exit sells the position at market and records it closed. price is the last
+// observed price and is used for PnL accounting only.
+func (m *Manager) Exit(ctx context.Context, p Position, reason string, price float64) (Position, error) {
+	qty, err := m.exitQuantity(ctx, p)
+	if err != nil {
+		return Position{}, err
+	}
+
+	req := exchange.OrderRequest{
+		Symbol:   p.Symbol,
+		Market:   p.Market,
+		Notional: p.Notional,
+		Quantity: qty,
+		Side:     exchange.OrderSideSell,
+		Type:     exchange.OrderTypeMarket,
+		Metadata: map[string]string{
+			"position_id": p.ID,
+			"exit_reason": reason,
+		},
+	}
+	ack, err := m.executor.Submit(ctx, req)
+	if err != nil {
+		m.notifyFailure(p, req, reason, err)
+		return Position{}, fmt.Errorf("submit exit for %s: %w", p.ID, err)
+	}
+	m.clearFailures(ctx, p)
+
+	closed, err := m.book.Close(p.ID, reason, price, time.Now())
+	if err != nil {
+		return Position{}, err
+	}
+	m.recordClose(ctx, p)
+
+	m.logger.InfoContext(ctx, "position exited", "position_id", p.ID, "symbol", p.Symbol, "reason", reason, "order_id", ack.OrderID, "quantity", qty, "exit_price", price, "pnl", closed.RealizedPnL)
+	m.notify(notify.Event{Kind: notify.KindPositionExited, Symbol: p.Symbol, Market: string(p.Market), Side: string(req.Side), OrderID: ack.OrderID, Notional: p.Notional, Quantity: qty, Price: price, PnL: closed.RealizedPnL, Fraction: 1, Reason: reason})
+	return closed, nil
+}
+
+// Reduce sells fraction of the position at market and keeps the rest open.
+// A fraction of 1 or more is a full Exit.
+func (m *Manager) Reduce(ctx context.Context, p Position, fraction float64, reason string, price float64) (Position, error) {
+	if fraction >= 1 {
+		return m.Exit(ctx, p, reason, price)
+	}
+	if fraction <= 0 {
+		return Position{}, fmt.Errorf("invalid close fraction %v", fraction)
+	}
+	qty, err := m.exitQuantity(ctx, p)
+	if err != nil {
+		return Position{}, err
+	}
+	qty, err = m.partialQuantity(ctx, p, qty*fraction)
+	if err != nil {
+		return Position{}, err
+	}
+
+	req := exchange.OrderRequest{
+		Symbol:   p.Symbol,
+		Market:   p.Market,
+		Notional: p.Notional * fraction,
+		Quantity: qty,
+		Side:     exchange.OrderSideSell,
+		Type:     exchange.OrderTypeMarket,
+		Metadata: map[string]string{
+			"position_id": p.ID,
+			"exit_reason": reason,
+		},
+	}
+	ack, err := m.executor.Submit(ctx, req)
+	if err != nil {
+		m.notifyFailure(p, req, reason, err)
+		return Position{}, fmt.Errorf("submit partial exit for %s: %w", p.ID, err)
+	}
+	m.clearFailures(ctx, p)
+
+	p.Notional -= req.Notional
+	if p.Quantity > 0 {
+		sold := req.Quantity
+		if sold <= 0 {
+			sold = p.Quantity * fraction
+		}
+		p.Quantity -= sold
+	}
+	if err := m.book.Update(p); err != nil {
+		return Position{}, err
+	}
+	m.logger.InfoContext(ctx, "position reduced", "position_id", p.ID, "symbol", p.Symbol, "reason", reason, "order_id", ack.OrderID, "fraction", fraction, "quantity", req.Quantity, "price", price)
+	m.notify(notify.Event{Kind: notify.KindPositionExited, Symbol: p.Symbol, Market: string(p.Market), Side: string(req.Side), OrderID: ack.OrderID, Notional: req.Notional, Quantity: req.Quantity, Price: price, Fraction: fraction, Reason: reason})
+	return p, nil
+}
+
+// Cancel withdraws the position's entry order while it still rests on the
+// book. An unfilled entry closes the position; a partly filled one keeps the
+// filled part open. It reports false when nothing was resting, leaving the
+// position to the exit rules.
+func (m *Manager) Cancel(ctx context.Context, p Position, reason string) (bool, error) {
+	canceller, ok := m.executor.(exchange.OrderCanceller)
+	if !ok || m.account == nil || p.OrderID == "" {
+		return false, nil
+	}
+	orders, err := m.account.OpenOrders(ctx, p.Symbol)
+	if err != nil {
+		return false, fmt.Errorf("read open orders for cancel: %w", err)
+	}
+	var entry *exchange.OpenOrder
+	for i := range orders {
+		if orders[i].OrderID == p.OrderID {
+			entry = &orders[i]
+			break
+		}
+	}
+	if entry == nil {
+		return false, nil
+	}
+	if err := canceller.CancelOrder(ctx, p.Market, p.Symbol, p.OrderID); err != nil {
+		return false, fmt.Errorf("cancel entry for %s: %w", p.ID, err)
+	}
+
+	if entry.ExecutedQty <= 0 {
+		_, err := m.MarkClosed(ctx, p, reason)
+		return true, err
+	}
+	if entry.OrigQty > 0 {
+		p.Notional *= entry.ExecutedQty / entry.OrigQty
+	}
+	p.Quantity = entry.ExecutedQty
+	if err := m.book.Update(p); err != nil {
+		return true, err
+	}
+	m.logger.InfoContext(ctx, "entry cancelled after partial fill", "position_id", p.ID, "symbol", p.Symbol, "reason", reason, "quantity", p.Quantity)
+	return true, nil
+}
+
+// MarkClosed records the position closed without trading, e.g. when the
+// holding has already left the account.
+func (m *Manager) MarkClosed(ctx context.Context, p Position, reason string) (Position, error) {
+	closed, err := m.book.Close(p.ID, reason, 0, time.Now())
+	if err != nil {
+		return Position{}, err
+	}
+	m.mu.Lock()
+	delete(m.failing, p.ID)
+	m.mu.Unlock()
+	m.recordClose(ctx, p)
+	m.logger.InfoContext(ctx, "position marked closed", "position_id", p.ID, "symbol", p.Symbol, "reason", reason)
+	return closed, nil
+}
+
+// recordClose frees the risk slot the position's entry claimed. Adopted
+// positions never claimed one.
+func (m *Manager) recordClose(ctx context.Context, p Position) {
+	if !p.Adopted {
+		m.risk.RecordClose(ctx, p.Symbol)
+	}
+}
+
+func (m *Manager) notify(ev notify.Event) {
+	if m.notifier != nil {
+		m.notifier.Notify(ev)
+	}
+}
+
+// notifyFailure reports the first of a run of failed exits for p; the monitor
+// retries every poll, and repeating the same error would flood the chat.
+func (m *Manager) notifyFailure(p Position, req exchange.OrderRequest, reason string, err error) {
+	m.mu.Lock()
+	m.failing[p.ID]++
+	first := m.failing[p.ID] == 1
+	m.mu.Unlock()
+	if !first {
+		return
+	}
+	m.notify(notify.Event{Kind: notify.KindExecutorFailed, Symbol: req.Symbol, Market: string(req.Market), Side: string(req.Side), Notional: req.Notional, Quantity: req.Quantity, Reason: reason, Error: err.Error()})
+}
+
+// clearFailures ends a run of failed exits once one goes through.
+func (m *Manager) clearFailures(ctx context.Context, p Position) {
+	m.mu.Lock()
+	failures := m.failing[p.ID]
+	delete(m.failing, p.ID)
+	m.mu.Unlock()
+	if failures > 0 {
+		m.logger.InfoContext(ctx, "exit succeeded after failures", "position_id", p.ID, "symbol", p.Symbol, "failures", failures)
+	}
+}
+
+// exitQuantity sizes the sell from the position, capped by the free balance when
+// the account is readable and the position is spot. Zero means "sell by notional".
+func (m *Manager) exitQuantity(ctx context.Context, p Position) (float64, error) {
+	qty := p.Quantity
+	if qty <= 0 && p.EntryPrice > 0 {
+		qty = p.Notional / p.EntryPrice
+	}
+	if m.account == nil || !p.IsSpot() {
+		return qty, nil
+	}
+
+	balances, err := m.account.Balances(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("read balance for exit: %w", err)
+	}
+	base := BaseAsset(p.Symbol, m.quote)
+	var free float64
+	for _, b := range balances {
+		if b.Asset == base {
+			free = b.Free
+			break
+		}
+	}
+	if free <= 0 {
+		return 0, fmt.Errorf("no free %s balance to exit %s", base, p.ID)
+	}
+	if qty <= 0 || qty > free {
+		qty = free
+	}
+	return qty, nil
+}
+
+// partialQuantity rounds a spot partial exit down to the symbol's quantity
+// step, since MEXC rejects quantities finer than that. When the step is
+// unknown it returns 0 so the order sells by notional instead.
+func (m *Manager) partialQuantity(ctx context.Context, p Position, qty float64) (float64, error) {
+	if !p.IsSpot() || qty <= 0 {
+		return qty, nil
+	}
+	if m.lots == nil {
+		return 0, nil
+	}
+	step, err := m.lots.QuantityStep(ctx, p.Symbol)
+	if err != nil {
+		m.logger.WarnContext(ctx, "quantity step unavailable; partial exit sells by notional", "symbol", p.Symbol, "error", err)
+		return 0, nil
+	}
+	rounded := exchange.FloorToStep(qty, step)
+	if rounded <= 0 {
+		return 0, fmt.Errorf("partial exit of %v %s is below the quantity step %v", qty, p.Symbol, step)
+	}
+	return rounded, nil
+}
+
+// BaseAsset strips the quote asset suffix from a canonical symbol, e.g. TWIFUSDT -> TWIF.
+func BaseAsset(symbol, quote string) string {
+	return strings.TrimSuffix(strings.ToUpper(symbol), strings.ToUpper(quote))
+}
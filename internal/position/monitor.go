@@ -0,0 +1,248 @@
+package position
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/notify"
+)
+
+// ExitRules are the thresholds the monitor applies to every open position.
+// Percentages are fractions, e.g. 0.25 for +25%.
+type ExitRules struct {
+	TakeProfitPct   float64
+	StopLossPct     float64
+	BreakevenArmPct float64
+	TrailingEnable  bool
+	TrailStartPct   float64
+	TrailStepPct    float64
+	MaxHold         time.Duration
+	// UseSignalLevels prefers a position's channel-quoted target and stop over
+	// TakeProfitPct and StopLossPct.
+	UseSignalLevels bool
+}
+
+// RulesFromConfig maps the [risk] and [pnl_exit] sections onto ExitRules.
+func RulesFromConfig(riskCfg config.RiskConfig, pnlCfg config.PnLExitConfig) ExitRules {
+	return ExitRules{
+		TakeProfitPct:   riskCfg.TakeProfitPct,
+		StopLossPct:     riskCfg.StopLossPct,
+		BreakevenArmPct: riskCfg.BreakevenArmPct,
+		TrailingEnable:  pnlCfg.TrailingEnable,
+		TrailStartPct:   pnlCfg.TrailStartPct,
+		TrailStepPct:    pnlCfg.TrailStepPct,
+		MaxHold:         time.Duration(riskCfg.MaxPositionHours) * time.Hour,
+		UseSignalLevels: riskCfg.UseSignalLevels,
+	}
+}
+
+// maxExitBackoff caps the wait between attempts to exit a position whose
+// exit keeps failing.
+const maxExitBackoff = 5 * time.Minute
+
+// Monitor polls prices for open positions and exits them when a rule triggers.
+type Monitor struct {
+	logger   *slog.Logger
+	manager  *Manager
+	prices   exchange.PriceFeed
+	rules    ExitRules
+	interval time.Duration
+	now      func() time.Time
+
+	mu    sync.Mutex
+	marks map[string]*mark
+}
+
+// mark is the per-position state the trailing and breakeven rules need, plus
+// the retry schedule of a failing exit.
+type mark struct {
+	peak      float64
+	breakeven bool
+	trailing  bool
+
+	failures int
+	retryAt  time.Time
+}
+
+func NewMonitor(manager *Manager, prices exchange.PriceFeed, rules ExitRules, interval time.Duration, logger *slog.Logger) (*Monitor, error) {
+	if manager == nil {
+		return nil, errors.New("position manager must not be nil")
+	}
+	if prices == nil {
+		return nil, errors.New("price feed must not be nil")
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Monitor{
+		logger:   logger,
+		manager:  manager,
+		prices:   prices,
+		rules:    rules,
+		interval: interval,
+		now:      time.Now,
+		marks:    make(map[string]*mark),
+	}, nil
+}
+
+// Run checks open positions every interval until ctx is cancelled. It only acts
+// on what is in the book, so start it after reconciliation has settled the book.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	m.logger.Info("exit monitor started", "open_positions", len(m.manager.Book().OpenPositions()), "interval", m.interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			m.check(ctx)
+		}
+	}
+}
+
+func (m *Monitor) check(ctx context.Context) {
+	open := m.manager.Book().OpenPositions()
+
+	m.mu.Lock()
+	live := make(map[string]struct{}, len(open))
+	for _, p := range open {
+		live[p.ID] = struct{}{}
+	}
+	for id := range m.marks {
+		if _, ok := live[id]; !ok {
+			delete(m.marks, id)
+		}
+	}
+	m.mu.Unlock()
+
+	prices := make(map[string]float64)
+	now := m.now()
+	for _, p := range open {
+		price, ok := prices[p.Symbol]
+		if !ok {
+			var err error
+			price, err = m.prices.LastPrice(ctx, p.Symbol)
+			if err != nil {
+				m.logger.WarnContext(ctx, "exit monitor price unavailable", "symbol", p.Symbol, "error", err)
+				continue
+			}
+			prices[p.Symbol] = price
+		}
+
+		if p.EntryPrice <= 0 {
+			// Entries submitted by notional carry no fill price; anchor on the first observation.
+			p.EntryPrice = price
+			if p.Quantity <= 0 {
+				p.Quantity = p.Notional / price
+			}
+			if err := m.manager.Book().Update(p); err != nil {
+				m.logger.ErrorContext(ctx, "anchor entry price", "position_id", p.ID, "error", err)
+			} else if !p.Adopted {
+				m.manager.notify(notify.Event{Kind: notify.KindOrderFilled, Symbol: p.Symbol, Market: string(p.Market), OrderID: p.OrderID, Notional: p.Notional, Quantity: p.Quantity, Price: price})
+			}
+		}
+
+		reason := m.evaluate(p, price, now)
+		if reason == "" || !m.due(p.ID, now) {
+			continue
+		}
+		if _, err := m.manager.Exit(ctx, p, reason, price); err != nil {
+			wait := m.backoff(p.ID, now)
+			m.logger.ErrorContext(ctx, "exit position", "position_id", p.ID, "symbol", p.Symbol, "reason", reason, "retry_in", wait, "error", err)
+		}
+	}
+}
+
+// due reports whether an exit of the position may be attempted at now.
+func (m *Monitor) due(id string, now time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mk, ok := m.marks[id]
+	return !ok || !now.Before(mk.retryAt)
+}
+
+// backoff schedules the next exit attempt after a failure, doubling the wait
+// from the poll interval up to maxExitBackoff.
+func (m *Monitor) backoff(id string, now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mk, ok := m.marks[id]
+	if !ok {
+		return 0
+	}
+	wait := maxExitBackoff
+	if mk.failures < 16 {
+		wait = min(m.interval<<mk.failures, maxExitBackoff)
+	}
+	mk.failures++
+	mk.retryAt = now.Add(wait)
+	return wait
+}
+
+// evaluate returns the exit reason for p at price, or "" to keep holding.
+func (m *Monitor) evaluate(p Position, price float64, now time.Time) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mk, ok := m.marks[p.ID]
+	if !ok {
+		mk = &mark{peak: price}
+		m.marks[p.ID] = mk
+	}
+	if price > mk.peak {
+		mk.peak = price
+	}
+
+	change := (price - p.EntryPrice) / p.EntryPrice
+	r := m.rules
+
+	if r.MaxHold > 0 && now.Sub(p.OpenedAt) >= r.MaxHold {
+		return "max_hold"
+	}
+	if r.UseSignalLevels && p.Stop != nil {
+		if price <= p.Stop.Resolve(p.EntryPrice) {
+			return "signal_stop"
+		}
+	} else if r.StopLossPct > 0 && change <= -r.StopLossPct {
+		return "stop_loss"
+	}
+	// A channel target replaces take_profit_pct and trailing: a +2000% call
+	// must not be sold at the global +25%.
+	signalTarget := r.UseSignalLevels && p.Target != nil
+	if signalTarget && price >= p.Target.Resolve(p.EntryPrice) {
+		return "signal_target"
+	}
+
+	if r.BreakevenArmPct > 0 && change >= r.BreakevenArmPct {
+		mk.breakeven = true
+	}
+	if mk.breakeven && price <= p.EntryPrice {
+		return "breakeven_stop"
+	}
+
+	if signalTarget {
+		return ""
+	}
+
+	if r.TrailingEnable {
+		if change >= r.TrailStartPct {
+			mk.trailing = true
+		}
+		if mk.trailing && price <= mk.peak*(1-r.TrailStepPct) {
+			return "trailing_stop"
+		}
+		return ""
+	}
+
+	if r.TakeProfitPct > 0 && change >= r.TakeProfitPct {
+		return "take_profit"
+	}
+	return ""
+}
@@ -0,0 +1,167 @@
+package position
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/notify"
+	"github.com/user/mexc-bot/internal/risk"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+func TestMonitorSignalTargetReplacesTakeProfit(t *testing.T) {
+	m := &Monitor{
+		rules: ExitRules{
+			TakeProfitPct:   0.25,
+			StopLossPct:     0.10,
+			TrailingEnable:  true,
+			TrailStartPct:   0.20,
+			TrailStepPct:    0.05,
+			UseSignalLevels: true,
+		},
+		marks: make(map[string]*mark),
+	}
+	now := time.Now()
+	withTarget := Position{ID: "p1", EntryPrice: 1, OpenedAt: now, Target: &signal.Level{Pct: 20}}
+
+	// +50% is past the global take-profit and trailing start but far below
+	// the +2000% channel target, so the position stays open.
+	if reason := m.evaluate(withTarget, 1.5, now); reason != "" {
+		t.Fatalf("expected to hold below the signal target, got %q", reason)
+	}
+	// A pullback that would trip the trailing stop is ignored as well.
+	if reason := m.evaluate(withTarget, 1.3, now); reason != "" {
+		t.Fatalf("expected trailing to be skipped with a signal target, got %q", reason)
+	}
+	if reason := m.evaluate(withTarget, 21, now); reason != "signal_target" {
+		t.Fatalf("expected signal_target, got %q", reason)
+	}
+
+	// Without a channel target the global rules still apply.
+	m.rules.TrailingEnable = false
+	plain := Position{ID: "p2", EntryPrice: 1, OpenedAt: now}
+	if reason := m.evaluate(plain, 1.5, now); reason != "take_profit" {
+		t.Fatalf("expected take_profit without a signal target, got %q", reason)
+	}
+}
+
+type failingExecutor struct {
+	err   error
+	calls int
+	last  exchange.OrderRequest
+}
+
+func (f *failingExecutor) Name() string { return "failing" }
+
+func (f *failingExecutor) Submit(ctx context.Context, req exchange.OrderRequest) (exchange.OrderAck, error) {
+	f.calls++
+	f.last = req
+	if f.err != nil {
+		return exchange.OrderAck{}, f.err
+	}
+	return exchange.OrderAck{OrderID: "exit", SubmittedAt: time.Now()}, nil
+}
+
+type recordingSink struct {
+	events []notify.Event
+}
+
+func (r *recordingSink) Notify(ev notify.Event) { r.events = append(r.events, ev) }
+
+func TestMonitorBacksOffFailingExits(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	book, err := NewBook(nil)
+	if err != nil {
+		t.Fatalf("new book: %v", err)
+	}
+	executor := &failingExecutor{err: errors.New("exchange unavailable")}
+	manager, err := NewManager(book, executor, nil, risk.NewSimpleManager(logger, config.RiskConfig{}), "USDT", logger)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	sink := &recordingSink{}
+	manager.SetNotifier(sink)
+	if _, err := book.Open(Position{Symbol: "AAAUSDT", Notional: 100, EntryPrice: 1, Adopted: true, OpenedAt: time.Now()}); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	monitor, err := NewMonitor(manager, fakePrices{"AAAUSDT": 0.5}, ExitRules{StopLossPct: 0.1}, time.Second, logger)
+	if err != nil {
+		t.Fatalf("new monitor: %v", err)
+	}
+
+	now := time.Now()
+	monitor.now = func() time.Time { return now }
+
+	// The stop keeps firing every poll while the exchange rejects the exit;
+	// retries wait 1s, then 2s, then 4s.
+	for _, step := range []time.Duration{0, 500 * time.Millisecond, 500 * time.Millisecond, time.Second, time.Second, time.Second} {
+		now = now.Add(step)
+		monitor.check(ctx)
+	}
+	if executor.calls != 3 {
+		t.Fatalf("expected 3 exit attempts within the backoff, got %d", executor.calls)
+	}
+	executor.err = nil
+	now = now.Add(4 * time.Second)
+	monitor.check(ctx)
+
+	var kinds []notify.Kind
+	for _, ev := range sink.events {
+		kinds = append(kinds, ev.Kind)
+	}
+	if len(kinds) != 2 || kinds[0] != notify.KindExecutorFailed || kinds[1] != notify.KindPositionExited {
+		t.Fatalf("expected one failure then the exit, got %v", kinds)
+	}
+}
+
+type fixedStep float64
+
+func (s fixedStep) QuantityStep(ctx context.Context, symbol string) (float64, error) {
+	return float64(s), nil
+}
+
+func TestManagerReduceRoundsToQuantityStep(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	book, err := NewBook(nil)
+	if err != nil {
+		t.Fatalf("new book: %v", err)
+	}
+	executor := &failingExecutor{}
+	manager, err := NewManager(book, executor, nil, risk.NewSimpleManager(logger, config.RiskConfig{}), "USDT", logger)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	p, err := book.Open(Position{Symbol: "AAAUSDT", Notional: 100, Quantity: 123.456789, EntryPrice: 0.81, OpenedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	// Without a step the partial exit sells by notional.
+	if p, err = manager.Reduce(ctx, p, 0.5, "partial", 1); err != nil {
+		t.Fatalf("reduce: %v", err)
+	}
+	if executor.last.Quantity != 0 || executor.last.Notional != 50 {
+		t.Fatalf("expected a notional sell, got %+v", executor.last)
+	}
+
+	manager.SetLotSizer(fixedStep(0.01))
+	if _, err = manager.Reduce(ctx, p, 0.5, "partial", 1); err != nil {
+		t.Fatalf("reduce: %v", err)
+	}
+	if got := executor.last.Quantity; got != 30.86 {
+		t.Fatalf("expected 30.86 rounded down to the step, got %v", got)
+	}
+
+	manager.SetLotSizer(fixedStep(1000))
+	if _, err = manager.Reduce(ctx, p, 0.5, "partial", 1); err == nil {
+		t.Fatalf("expected a partial exit below the step to fail")
+	}
+}
@@ -0,0 +1,205 @@
+package position
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/exchange"
+)
+
+// Holding is an exchange balance with no matching local position.
+type Holding struct {
+	Asset    string
+	Symbol   string
+	Quantity float64
+	Price    float64
+}
+
+// Report summarises one reconciliation pass.
+type Report struct {
+	Matched  []Position
+	Vanished []Position
+	Unknown  []Holding
+	Adopted  []Position
+	Pending  []exchange.OpenOrder
+}
+
+// Reconciler compares the book with the exchange account, closing positions
+// whose holdings are gone and flagging or adopting holdings the book does not know.
+type Reconciler struct {
+	logger      *slog.Logger
+	manager     *Manager
+	account     exchange.AccountReader
+	prices      exchange.PriceFeed
+	adopt       bool
+	minNotional float64
+
+	mu sync.Mutex
+}
+
+func NewReconciler(manager *Manager, account exchange.AccountReader, prices exchange.PriceFeed, cfg config.ReconcileConfig, logger *slog.Logger) (*Reconciler, error) {
+	if manager == nil {
+		return nil, errors.New("position manager must not be nil")
+	}
+	if account == nil {
+		return nil, errors.New("account reader must not be nil")
+	}
+	if prices == nil {
+		return nil, errors.New("price feed must not be nil")
+	}
+	return &Reconciler{
+		logger:      logger,
+		manager:     manager,
+		account:     account,
+		prices:      prices,
+		adopt:       cfg.UnknownHoldings == "adopt",
+		minNotional: cfg.MinNotional,
+	}, nil
+}
+
+// Reconcile runs one pass. Concurrent calls are serialised.
+func (r *Reconciler) Reconcile(ctx context.Context) (Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	balances, err := r.account.Balances(ctx)
+	if err != nil {
+		return Report{}, fmt.Errorf("reconcile balances: %w", err)
+	}
+	holdings := make(map[string]float64, len(balances))
+	for _, b := range balances {
+		if b.Asset == r.manager.quote {
+			continue
+		}
+		holdings[b.Asset] = b.Total()
+	}
+
+	var report Report
+	bySymbol := make(map[string][]Position)
+	for _, p := range r.manager.Book().OpenPositions() {
+		if !p.IsSpot() {
+			// Futures contracts do not show up as spot balances.
+			continue
+		}
+		bySymbol[p.Symbol] = append(bySymbol[p.Symbol], p)
+	}
+	symbols := make([]string, 0, len(bySymbol))
+	for symbol := range bySymbol {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		positions := bySymbol[symbol]
+		base := BaseAsset(symbol, r.manager.quote)
+		qty := holdings[base]
+		delete(holdings, base)
+
+		orders, err := r.account.OpenOrders(ctx, symbol)
+		if err != nil {
+			return Report{}, fmt.Errorf("reconcile open orders: %w", err)
+		}
+		report.Pending = append(report.Pending, orders...)
+
+		if r.isDust(ctx, symbol, qty) && !hasSide(orders, exchange.OrderSideBuy) {
+			for _, p := range positions {
+				closed, err := r.manager.MarkClosed(ctx, p, "reconcile_vanished")
+				if err != nil {
+					return Report{}, err
+				}
+				report.Vanished = append(report.Vanished, closed)
+			}
+			continue
+		}
+
+		if len(positions) == 1 && qty > 0 && positions[0].Quantity != qty {
+			positions[0].Quantity = qty
+			if err := r.manager.Book().Update(positions[0]); err != nil {
+				return Report{}, err
+			}
+		}
+		report.Matched = append(report.Matched, positions...)
+	}
+
+	assets := make([]string, 0, len(holdings))
+	for asset := range holdings {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	for _, asset := range assets {
+		qty := holdings[asset]
+		symbol := asset + r.manager.quote
+		price, err := r.prices.LastPrice(ctx, symbol)
+		if err != nil {
+			// Not tradeable against the quote asset (or delisted); nothing we could exit anyway.
+			r.logger.DebugContext(ctx, "reconcile skip holding without price", "asset", asset, "error", err)
+			continue
+		}
+		if qty*price < r.minNotional {
+			continue
+		}
+
+		holding := Holding{Asset: asset, Symbol: symbol, Quantity: qty, Price: price}
+		report.Unknown = append(report.Unknown, holding)
+		if !r.adopt {
+			r.logger.WarnContext(ctx, "unknown holding on exchange", "symbol", symbol, "quantity", qty, "price", price)
+			continue
+		}
+
+		adopted, err := r.manager.Book().Open(Position{
+			Symbol:     symbol,
+			Notional:   qty * price,
+			Quantity:   qty,
+			EntryPrice: price,
+			Adopted:    true,
+			OpenedAt:   time.Now(),
+		})
+		if err != nil {
+			return Report{}, err
+		}
+		r.logger.InfoContext(ctx, "adopted unknown holding", "position_id", adopted.ID, "symbol", symbol, "quantity", qty, "price", price)
+		report.Adopted = append(report.Adopted, adopted)
+	}
+
+	r.logger.InfoContext(ctx, "reconciliation complete",
+		"matched", len(report.Matched),
+		"vanished", len(report.Vanished),
+		"unknown", len(report.Unknown),
+		"adopted", len(report.Adopted),
+		"pending_orders", len(report.Pending),
+	)
+	return report, nil
+}
+
+// isDust reports whether qty is too small to be the position we opened.
+func (r *Reconciler) isDust(ctx context.Context, symbol string, qty float64) bool {
+	if qty <= 0 {
+		return true
+	}
+	if r.minNotional <= 0 {
+		return false
+	}
+	price, err := r.prices.LastPrice(ctx, symbol)
+	if err != nil {
+		// Without a price, keep the position rather than guess it away.
+		return false
+	}
+	return qty*price < r.minNotional
+}
+
+func hasSide(orders []exchange.OpenOrder, side exchange.OrderSide) bool {
+	for _, o := range orders {
+		if strings.EqualFold(string(o.Side), string(side)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package position
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/risk"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+type fakeAccount struct {
+	balances []exchange.Balance
+	orders   map[string][]exchange.OpenOrder
+}
+
+func (f *fakeAccount) Balances(ctx context.Context) ([]exchange.Balance, error) {
+	return f.balances, nil
+}
+
+func (f *fakeAccount) OpenOrders(ctx context.Context, symbol string) ([]exchange.OpenOrder, error) {
+	return f.orders[symbol], nil
+}
+
+type fakePrices map[string]float64
+
+func (f fakePrices) LastPrice(ctx context.Context, symbol string) (float64, error) {
+	price, ok := f[symbol]
+	if !ok {
+		return 0, fmt.Errorf("unknown symbol %s", symbol)
+	}
+	return price, nil
+}
+
+func newTestManager(t *testing.T, account exchange.AccountReader) *Manager {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	book, err := NewBook(nil)
+	if err != nil {
+		t.Fatalf("new book: %v", err)
+	}
+	manager, err := NewManager(book, exchange.NewDryRunExecutor(logger), account, risk.NewSimpleManager(logger, config.RiskConfig{}), "USDT", logger)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	return manager
+}
+
+func TestReconcileClosesVanishedAndAdoptsUnknown(t *testing.T) {
+	ctx := context.Background()
+	account := &fakeAccount{
+		balances: []exchange.Balance{
+			{Asset: "USDT", Free: 1000},
+			{Asset: "KEEP", Free: 50},
+			{Asset: "NEW", Free: 10},
+			{Asset: "DUST", Free: 0.001},
+		},
+		orders: map[string][]exchange.OpenOrder{
+			"WAITUSDT": {{Symbol: "WAITUSDT", Side: exchange.OrderSideBuy}},
+		},
+	}
+	prices := fakePrices{"KEEPUSDT": 2, "GONEUSDT": 1, "NEWUSDT": 5, "DUSTUSDT": 1, "WAITUSDT": 1}
+	manager := newTestManager(t, account)
+	book := manager.Book()
+
+	keep, _ := book.Open(Position{Symbol: "KEEPUSDT", Notional: 100})
+	gone, _ := book.Open(Position{Symbol: "GONEUSDT", Notional: 100})
+	wait, _ := book.Open(Position{Symbol: "WAITUSDT", Notional: 100})
+
+	reconciler, err := NewReconciler(manager, account, prices, config.ReconcileConfig{UnknownHoldings: "adopt", MinNotional: 1}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new reconciler: %v", err)
+	}
+	report, err := reconciler.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	if len(report.Vanished) != 1 || report.Vanished[0].ID != gone.ID {
+		t.Fatalf("expected %s vanished, got %+v", gone.ID, report.Vanished)
+	}
+	if len(report.Matched) != 2 {
+		t.Fatalf("expected keep and pending-buy positions matched, got %+v", report.Matched)
+	}
+	if len(report.Adopted) != 1 || report.Adopted[0].Symbol != "NEWUSDT" || report.Adopted[0].Quantity != 10 {
+		t.Fatalf("expected NEWUSDT adopted, got %+v", report.Adopted)
+	}
+
+	open := map[string]Position{}
+	for _, p := range book.OpenPositions() {
+		open[p.ID] = p
+	}
+	if _, ok := open[gone.ID]; ok {
+		t.Fatalf("vanished position still open")
+	}
+	if _, ok := open[wait.ID]; !ok {
+		t.Fatalf("position with pending buy order should stay open")
+	}
+	if got := open[keep.ID].Quantity; got != 50 {
+		t.Fatalf("expected matched quantity synced to 50, got %v", got)
+	}
+}
+
+func TestMonitorEvaluate(t *testing.T) {
+	manager := newTestManager(t, nil)
+	rules := ExitRules{TakeProfitPct: 0.25, StopLossPct: 0.05, BreakevenArmPct: 0.10, MaxHold: time.Hour}
+	monitor, err := NewMonitor(manager, fakePrices{}, rules, time.Second, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new monitor: %v", err)
+	}
+	now := time.Now()
+
+	cases := []struct {
+		name   string
+		prices []float64
+		opened time.Time
+		want   string
+	}{
+		{"hold", []float64{1.05}, now, ""},
+		{"take profit", []float64{1.30}, now, "take_profit"},
+		{"stop loss", []float64{0.94}, now, "stop_loss"},
+		{"breakeven", []float64{1.12, 1.0}, now, "breakeven_stop"},
+		{"max hold", []float64{1.0}, now.Add(-2 * time.Hour), "max_hold"},
+	}
+	for i, tc := range cases {
+		p := Position{ID: fmt.Sprintf("p%d", i), EntryPrice: 1, OpenedAt: tc.opened}
+		var got string
+		for _, price := range tc.prices {
+			got = monitor.evaluate(p, price, now)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %q, got %q", tc.name, tc.want, got)
+		}
+	}
+
+	monitor.rules.TrailingEnable = true
+	monitor.rules.TrailStartPct = 0.15
+	monitor.rules.TrailStepPct = 0.02
+	p := Position{ID: "trail", EntryPrice: 1, OpenedAt: now}
+	for _, price := range []float64{1.2, 1.5} {
+		if got := monitor.evaluate(p, price, now); got != "" {
+			t.Fatalf("trailing: unexpected early exit %q at %v", got, price)
+		}
+	}
+	if got := monitor.evaluate(p, 1.46, now); got != "trailing_stop" {
+		t.Fatalf("trailing: expected trailing_stop, got %q", got)
+	}
+
+	monitor.rules.TrailingEnable = false
+	monitor.rules.UseSignalLevels = true
+	levels := Position{ID: "levels", EntryPrice: 1, OpenedAt: now, Target: &signal.Level{Pct: 2}, Stop: &signal.Level{Price: 0.8}}
+	if got := monitor.evaluate(levels, 0.9, now); got != "" {
+		t.Fatalf("signal levels: global stop_loss should not apply, got %q", got)
+	}
+	if got := monitor.evaluate(levels, 0.8, now); got != "signal_stop" {
+		t.Fatalf("signal levels: expected signal_stop, got %q", got)
+	}
+	if got := monitor.evaluate(levels, 3.0, now); got != "signal_target" {
+		t.Fatalf("signal levels: expected signal_target, got %q", got)
+	}
+}
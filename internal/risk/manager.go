@@ -2,6 +2,7 @@ package risk
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"sync"
 	"time"
@@ -21,12 +22,30 @@ type Decision struct {
 type Manager interface {
 	Evaluate(ctx context.Context, sig signal.Signal, desiredNotional float64) (Decision, error)
 	RecordExecution(ctx context.Context, sig signal.Signal, executedNotional float64)
+	// RecordFailure releases anything Evaluate reserved for an order that never reached the exchange.
+	RecordFailure(ctx context.Context, sig signal.Signal)
+	// RecordClose marks a previously executed position on symbol as closed.
+	RecordClose(ctx context.Context, symbol string)
+}
+
+// State is the persisted subset of SimpleManager counters.
+type State struct {
+	DayAnchor   time.Time            `json:"day_anchor"`
+	DailyTrades int                  `json:"daily_trades"`
+	LastTrade   map[string]time.Time `json:"last_trade"`
+}
+
+// StateStore persists SimpleManager counters so restarts do not reset limits.
+type StateStore interface {
+	LoadRiskState() (State, bool, error)
+	SaveRiskState(State) error
 }
 
 // SimpleManager implements baseline risk checks; extend with stateful limits later.
 type SimpleManager struct {
 	logger *slog.Logger
 	cfg    config.RiskConfig
+	store  StateStore
 
 	mu          sync.Mutex
 	lastTrade   map[string]time.Time
@@ -42,6 +61,27 @@ func NewSimpleManager(logger *slog.Logger, cfg config.RiskConfig) *SimpleManager
 	}
 }
 
+// NewPersistentManager restores counters from store and writes every change back to it.
+func NewPersistentManager(logger *slog.Logger, cfg config.RiskConfig, store StateStore) (*SimpleManager, error) {
+	m := NewSimpleManager(logger, cfg)
+	m.store = store
+
+	state, ok, err := store.LoadRiskState()
+	if err != nil {
+		return nil, fmt.Errorf("load risk state: %w", err)
+	}
+	if ok {
+		m.dayAnchor = state.DayAnchor
+		m.dailyTrades = state.DailyTrades
+		for symbol, at := range state.LastTrade {
+			m.lastTrade[symbol] = at
+		}
+		m.resetDay(time.Now())
+		logger.Info("risk state restored", "daily_trades", m.dailyTrades, "cooldowns", len(m.lastTrade), "day", m.dayAnchor.Format("2006-01-02"))
+	}
+	return m, nil
+}
+
 func (m *SimpleManager) Evaluate(ctx context.Context, sig signal.Signal, desiredNotional float64) (Decision, error) {
 	if desiredNotional <= 0 {
 		return Decision{Allow: false, Reason: "non-positive notional"}, nil
@@ -80,10 +120,17 @@ func (m *SimpleManager) RecordExecution(ctx context.Context, sig signal.Signal,
 	m.resetDay(now)
 	m.lastTrade[sig.Symbol] = now
 	m.dailyTrades++
+	m.persist(ctx)
 
 	m.logger.DebugContext(ctx, "risk recorded execution", "symbol", sig.Symbol, "notional", executedNotional, "daily_trades", m.dailyTrades)
 }
 
+// RecordFailure is a no-op: SimpleManager only records state on successful executions.
+func (m *SimpleManager) RecordFailure(ctx context.Context, sig signal.Signal) {}
+
+// RecordClose is a no-op: SimpleManager does not track open positions.
+func (m *SimpleManager) RecordClose(ctx context.Context, symbol string) {}
+
 func (m *SimpleManager) resetDay(now time.Time) {
 	// Reset daily counters if we crossed UTC day boundary.
 	if m.dayAnchor.IsZero() {
@@ -98,3 +145,22 @@ func (m *SimpleManager) resetDay(now time.Time) {
 		m.lastTrade = make(map[string]time.Time)
 	}
 }
+
+// persist writes the counters through to the store; callers must hold m.mu.
+func (m *SimpleManager) persist(ctx context.Context) {
+	if m.store == nil {
+		return
+	}
+	lastTrade := make(map[string]time.Time, len(m.lastTrade))
+	for symbol, at := range m.lastTrade {
+		lastTrade[symbol] = at
+	}
+	state := State{
+		DayAnchor:   m.dayAnchor,
+		DailyTrades: m.dailyTrades,
+		LastTrade:   lastTrade,
+	}
+	if err := m.store.SaveRiskState(state); err != nil {
+		m.logger.ErrorContext(ctx, "persist risk state", "error", err)
+	}
+}
@@ -0,0 +1,356 @@
+package risk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+// Open positions are counted per owner in one hash (field "<owner>|<symbol>"),
+// and every owner holds a lease in a second hash that its heartbeat renews.
+// Counts of an owner whose lease lapsed are reaped on the next reservation, so
+// a crashed instance cannot pin slots forever, and both hashes expire once no
+// instance is left to renew them.
+
+// leaseScript is shared by reserve and heartbeat: it renews the caller's lease
+// and drops the counts of owners whose lease has lapsed. KEYS[3] open-positions
+// hash, KEYS[4] lease hash; ARGV[6] owner token, ARGV[7] now ms, ARGV[8] lease ms.
+const leaseScript = `
+local now = tonumber(ARGV[7])
+local lease = tonumber(ARGV[8])
+redis.call('HSET', KEYS[4], ARGV[6], now + lease)
+redis.call('PEXPIRE', KEYS[4], lease)
+local leases = redis.call('HGETALL', KEYS[4])
+for i = 1, #leases, 2 do
+	if tonumber(leases[i + 1]) < now then
+		redis.call('HDEL', KEYS[4], leases[i])
+	end
+end
+local fields = redis.call('HGETALL', KEYS[3])
+for i = 1, #fields, 2 do
+	local sep = string.find(fields[i], '|', 1, true)
+	if not sep or redis.call('HEXISTS', KEYS[4], string.sub(fields[i], 1, sep - 1)) == 0 then
+		redis.call('HDEL', KEYS[3], fields[i])
+	end
+end
+`
+
+// reserveScript atomically checks cooldown, daily and open-position limits and,
+// when all pass, claims a slot in each so concurrent bot instances cannot race.
+//
+// KEYS[1] cooldown key, KEYS[2] daily counter key, KEYS[3] open-positions hash,
+// KEYS[4] owner lease hash. ARGV[1] cooldown ms, ARGV[2] max daily trades,
+// ARGV[3] max open positions, ARGV[4] daily key ttl seconds, ARGV[5] symbol,
+// ARGV[6] owner token, ARGV[7] now ms, ARGV[8] lease ms.
+var reserveScript = redis.NewScript(leaseScript + `
+local cooldown = tonumber(ARGV[1])
+local maxDaily = tonumber(ARGV[2])
+local maxOpen = tonumber(ARGV[3])
+
+if cooldown > 0 and redis.call('EXISTS', KEYS[1]) == 1 then
+	return 'cooldown_active'
+end
+
+local daily = tonumber(redis.call('GET', KEYS[2]) or '0')
+if maxDaily > 0 and daily >= maxDaily then
+	return 'daily_trade_limit'
+end
+
+local open = 0
+for _, v in ipairs(redis.call('HVALS', KEYS[3])) do
+	open = open + tonumber(v)
+end
+if maxOpen > 0 and open >= maxOpen then
+	return 'max_open_positions'
+end
+
+if cooldown > 0 then
+	redis.call('SET', KEYS[1], ARGV[6], 'PX', cooldown)
+end
+redis.call('INCR', KEYS[2])
+redis.call('EXPIRE', KEYS[2], tonumber(ARGV[4]))
+redis.call('HINCRBY', KEYS[3], ARGV[6] .. '|' .. ARGV[5], 1)
+redis.call('PEXPIRE', KEYS[3], lease)
+return 'ok'
+`)
+
+// heartbeatScript renews the owner's lease and reaps lapsed owners.
+//
+// KEYS as reserveScript; ARGV[6] owner token, ARGV[7] now ms, ARGV[8] lease ms
+// (ARGV[1..5] are unused).
+var heartbeatScript = redis.NewScript(leaseScript + `
+if redis.call('EXISTS', KEYS[3]) == 1 then
+	redis.call('PEXPIRE', KEYS[3], lease)
+end
+return 1
+`)
+
+// releaseScript undoes a reservation whose order never reached the exchange.
+//
+// KEYS[1] cooldown key, KEYS[2] the reservation's daily counter key, KEYS[3]
+// open-positions hash; ARGV[1] symbol, ARGV[2] owner token.
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[2] then
+	redis.call('DEL', KEYS[1])
+end
+if tonumber(redis.call('GET', KEYS[2]) or '0') > 0 then
+	redis.call('DECR', KEYS[2])
+end
+local field = ARGV[2] .. '|' .. ARGV[1]
+if tonumber(redis.call('HGET', KEYS[3], field) or '0') > 0 then
+	if redis.call('HINCRBY', KEYS[3], field, -1) <= 0 then
+		redis.call('HDEL', KEYS[3], field)
+	end
+end
+return 1
+`)
+
+// closeScript decrements this owner's open-position count for a symbol; it
+// never touches counts held by other instances.
+//
+// KEYS[1] open-positions hash; ARGV[1] symbol, ARGV[2] owner token.
+var closeScript = redis.NewScript(`
+local field = ARGV[2] .. '|' .. ARGV[1]
+if tonumber(redis.call('HGET', KEYS[1], field) or '0') > 0 then
+	if redis.call('HINCRBY', KEYS[1], field, -1) <= 0 then
+		redis.call('HDEL', KEYS[1], field)
+	end
+end
+return 1
+`)
+
+// restoreScript replaces this owner's open-position counts with the ones
+// given and takes out its lease, so positions restored from the book after a
+// restart hold their slots again.
+//
+// KEYS[1] open-positions hash, KEYS[2] owner lease hash; ARGV[1] owner token,
+// ARGV[2] now ms, ARGV[3] lease ms, then symbol/count pairs.
+var restoreScript = redis.NewScript(`
+local lease = tonumber(ARGV[3])
+redis.call('HSET', KEYS[2], ARGV[1], tonumber(ARGV[2]) + lease)
+redis.call('PEXPIRE', KEYS[2], lease)
+local prefix = ARGV[1] .. '|'
+local fields = redis.call('HKEYS', KEYS[1])
+for _, f in ipairs(fields) do
+	if string.sub(f, 1, #prefix) == prefix then
+		redis.call('HDEL', KEYS[1], f)
+	end
+end
+for i = 4, #ARGV, 2 do
+	redis.call('HSET', KEYS[1], prefix .. ARGV[i], ARGV[i + 1])
+end
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('PEXPIRE', KEYS[1], lease)
+end
+return 1
+`)
+
+// ownerLease is how long an instance's open-position counts survive without a
+// heartbeat; Run renews it well inside that window.
+const ownerLease = 2 * time.Minute
+
+// RedisManager shares cooldowns, daily counters and open-position counts across
+// bot instances through Redis. Evaluate reserves capacity atomically, so only one
+// instance can act on a given symbol within the cooldown window.
+//
+// Open-position counts belong to the instance that reserved them and are held
+// under a lease renewed by Run; an instance that stops renewing loses its
+// slots after ownerLease. After a restart, Restore claims slots for the
+// positions restored from the book; until the previous run's lease lapses
+// those positions are counted twice, which errs on the side of the limit.
+type RedisManager struct {
+	logger  *slog.Logger
+	cfg     config.RiskConfig
+	maxOpen int
+	client  *redis.Client
+	prefix  string
+	owner   string
+	now     func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]string // reservation -> daily counter key it was charged to
+}
+
+// NewRedisManager connects to the Redis instance configured in infra.redis_url.
+func NewRedisManager(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*RedisManager, error) {
+	if cfg == nil {
+		return nil, errors.New("config must not be nil")
+	}
+	opts, err := redis.ParseURL(cfg.Infra.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis_url: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("ping redis: %w", err)
+	}
+	return newRedisManager(client, cfg, logger), nil
+}
+
+func newRedisManager(client *redis.Client, cfg *config.Config, logger *slog.Logger) *RedisManager {
+	prefix := strings.TrimSuffix(cfg.Infra.RedisKeyPrefix, ":")
+	if prefix == "" {
+		prefix = "mexc-bot"
+	}
+	return &RedisManager{
+		logger:  logger,
+		cfg:     cfg.Risk,
+		maxOpen: cfg.Trading.MaxOpenPositions,
+		client:  client,
+		prefix:  prefix,
+		owner:   newOwnerToken(),
+		now:     time.Now,
+		pending: make(map[string]string),
+	}
+}
+
+func (m *RedisManager) Evaluate(ctx context.Context, sig signal.Signal, desiredNotional float64) (Decision, error) {
+	if desiredNotional <= 0 {
+		return Decision{Allow: false, Reason: "non-positive notional"}, nil
+	}
+
+	now := m.now()
+	keys := m.keys(sig.Symbol, now)
+	cooldown := time.Duration(m.cfg.CooldownSeconds) * time.Second
+	res, err := reserveScript.Run(ctx, m.client, keys,
+		cooldown.Milliseconds(),
+		m.cfg.MaxDailyTrades,
+		m.maxOpen,
+		int((48 * time.Hour).Seconds()),
+		sig.Symbol,
+		m.owner,
+		now.UnixMilli(),
+		ownerLease.Milliseconds(),
+	).Text()
+	if err != nil {
+		return Decision{}, fmt.Errorf("redis reserve: %w", err)
+	}
+	if res != "ok" {
+		return Decision{Allow: false, Reason: res}, nil
+	}
+
+	m.mu.Lock()
+	m.pending[reservation(sig)] = keys[1]
+	m.mu.Unlock()
+	return Decision{
+		Allow:    true,
+		Notional: desiredNotional,
+	}, nil
+}
+
+func (m *RedisManager) RecordExecution(ctx context.Context, sig signal.Signal, executedNotional float64) {
+	// Counters were claimed in Evaluate; the reservation is now a position.
+	m.mu.Lock()
+	delete(m.pending, reservation(sig))
+	m.mu.Unlock()
+	m.logger.DebugContext(ctx, "risk recorded execution", "symbol", sig.Symbol, "notional", executedNotional, "backend", "redis")
+}
+
+// RecordFailure releases the reservation Evaluate made for sig, charging the
+// daily counter back to the day the reservation was taken on.
+func (m *RedisManager) RecordFailure(ctx context.Context, sig signal.Signal) {
+	m.mu.Lock()
+	dailyKey, ok := m.pending[reservation(sig)]
+	delete(m.pending, reservation(sig))
+	m.mu.Unlock()
+	if !ok {
+		m.logger.WarnContext(ctx, "redis release: no reservation held", "symbol", sig.Symbol)
+		return
+	}
+
+	keys := m.keys(sig.Symbol, m.now())
+	keys[1] = dailyKey
+	if err := releaseScript.Run(ctx, m.client, keys[:3], sig.Symbol, m.owner).Err(); err != nil {
+		m.logger.ErrorContext(ctx, "redis release reservation", "symbol", sig.Symbol, "error", err)
+	}
+}
+
+// RecordClose frees one of this instance's open-position slots for symbol.
+func (m *RedisManager) RecordClose(ctx context.Context, symbol string) {
+	if err := closeScript.Run(ctx, m.client, []string{m.openKey()}, symbol, m.owner).Err(); err != nil {
+		m.logger.ErrorContext(ctx, "redis record close", "symbol", symbol, "error", err)
+	}
+}
+
+// Restore sets this instance's open-position counts to open (symbol ->
+// positions). Call it at boot, before any signal is evaluated.
+func (m *RedisManager) Restore(ctx context.Context, open map[string]int) error {
+	now := m.now()
+	args := []any{m.owner, now.UnixMilli(), ownerLease.Milliseconds()}
+	for symbol, n := range open {
+		if n > 0 {
+			args = append(args, symbol, n)
+		}
+	}
+	keys := m.keys("", now)
+	if err := restoreScript.Run(ctx, m.client, []string{keys[2], keys[3]}, args...).Err(); err != nil {
+		return fmt.Errorf("redis restore open positions: %w", err)
+	}
+	return nil
+}
+
+// Run renews this instance's lease on its open-position counts until ctx is
+// cancelled.
+func (m *RedisManager) Run(ctx context.Context) error {
+	ticker := time.NewTicker(ownerLease / 4)
+	defer ticker.Stop()
+	for {
+		if err := m.heartbeat(ctx, m.now()); err != nil && ctx.Err() == nil {
+			m.logger.WarnContext(ctx, "redis lease renewal failed", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *RedisManager) heartbeat(ctx context.Context, now time.Time) error {
+	return heartbeatScript.Run(ctx, m.client, m.keys("", now),
+		0, 0, 0, 0, "", m.owner, now.UnixMilli(), ownerLease.Milliseconds()).Err()
+}
+
+// Close releases the underlying Redis connection pool.
+func (m *RedisManager) Close() error {
+	return m.client.Close()
+}
+
+func (m *RedisManager) keys(symbol string, now time.Time) []string {
+	return []string{
+		m.prefix + ":risk:cooldown:" + symbol,
+		m.prefix + ":risk:daily:" + now.UTC().Format("20060102"),
+		m.openKey(),
+		m.prefix + ":risk:owners",
+	}
+}
+
+func (m *RedisManager) openKey() string {
+	return m.prefix + ":risk:open"
+}
+
+// reservation identifies the reservation made for a signal.
+func reservation(sig signal.Signal) string {
+	return fmt.Sprintf("%s/%s/%d", sig.Symbol, sig.RawMessage.Chat(), sig.RawMessage.ID)
+}
+
+// newOwnerToken identifies this process so a release only clears its own cooldown claim.
+func newOwnerToken() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%s:%d:%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
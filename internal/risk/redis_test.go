@@ -0,0 +1,214 @@
+package risk
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+func redisTestConfig() *config.Config {
+	return &config.Config{
+		Trading: config.TradingConfig{MaxOpenPositions: 2},
+		Risk: config.RiskConfig{
+			Backend:         "redis",
+			CooldownSeconds: 45,
+			MaxDailyTrades:  3,
+		},
+		Infra: config.InfraConfig{RedisKeyPrefix: "test"},
+	}
+}
+
+func newTestRedisManagers(t *testing.T, cfg *config.Config, n int) []*RedisManager {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	managers := make([]*RedisManager, 0, n)
+	for i := 0; i < n; i++ {
+		client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+		t.Cleanup(func() { _ = client.Close() })
+		managers = append(managers, newRedisManager(client, cfg, logger))
+	}
+	return managers
+}
+
+func TestRedisManagerSharedCooldown(t *testing.T) {
+	ctx := context.Background()
+	managers := newTestRedisManagers(t, redisTestConfig(), 2)
+	sig := signal.Signal{Symbol: "TWIFUSDT"}
+
+	first, err := managers[0].Evaluate(ctx, sig, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !first.Allow {
+		t.Fatalf("expected first instance to be allowed, got %q", first.Reason)
+	}
+
+	second, err := managers[1].Evaluate(ctx, sig, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if second.Allow || second.Reason != "cooldown_active" {
+		t.Fatalf("expected second instance to hit cooldown, got %+v", second)
+	}
+}
+
+func TestRedisManagerSharedLimits(t *testing.T) {
+	ctx := context.Background()
+	cfg := redisTestConfig()
+	cfg.Risk.CooldownSeconds = 0
+	managers := newTestRedisManagers(t, cfg, 2)
+
+	for i, symbol := range []string{"AUSDT", "BUSDT"} {
+		d, err := managers[i%2].Evaluate(ctx, signal.Signal{Symbol: symbol}, 100)
+		if err != nil || !d.Allow {
+			t.Fatalf("expected %s allowed, got %+v (err=%v)", symbol, d, err)
+		}
+	}
+
+	d, err := managers[0].Evaluate(ctx, signal.Signal{Symbol: "CUSDT"}, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Allow || d.Reason != "max_open_positions" {
+		t.Fatalf("expected open position limit, got %+v", d)
+	}
+
+	// A close only frees a slot held by the closing instance.
+	managers[1].RecordClose(ctx, "AUSDT")
+	if d, _ := managers[0].Evaluate(ctx, signal.Signal{Symbol: "CUSDT"}, 100); d.Allow {
+		t.Fatalf("expected another instance's close to leave the slot taken")
+	}
+	managers[0].RecordClose(ctx, "AUSDT")
+	d, err = managers[0].Evaluate(ctx, signal.Signal{Symbol: "CUSDT"}, 100)
+	if err != nil || !d.Allow {
+		t.Fatalf("expected slot after close, got %+v (err=%v)", d, err)
+	}
+
+	managers[1].RecordClose(ctx, "BUSDT")
+	d, err = managers[1].Evaluate(ctx, signal.Signal{Symbol: "DUSDT"}, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d.Allow || d.Reason != "daily_trade_limit" {
+		t.Fatalf("expected daily trade limit, got %+v", d)
+	}
+}
+
+func TestRedisManagerReleaseOnFailure(t *testing.T) {
+	ctx := context.Background()
+	managers := newTestRedisManagers(t, redisTestConfig(), 2)
+	sig := signal.Signal{Symbol: "TWIFUSDT"}
+
+	if d, err := managers[0].Evaluate(ctx, sig, 100); err != nil || !d.Allow {
+		t.Fatalf("expected allowed, got %+v (err=%v)", d, err)
+	}
+	managers[0].RecordFailure(ctx, sig)
+
+	d, err := managers[1].Evaluate(ctx, sig, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !d.Allow {
+		t.Fatalf("expected reservation released after failure, got %q", d.Reason)
+	}
+}
+
+func TestRedisManagerReleaseChargesReservationDay(t *testing.T) {
+	ctx := context.Background()
+	srv := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	m := newRedisManager(client, redisTestConfig(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	now := time.Date(2024, 3, 1, 23, 59, 59, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	sig := signal.Signal{Symbol: "TWIFUSDT", RawMessage: signal.Message{ChatID: 1, ID: 9}}
+	if d, err := m.Evaluate(ctx, sig, 100); err != nil || !d.Allow {
+		t.Fatalf("expected allowed, got %+v (err=%v)", d, err)
+	}
+
+	// The order fails after midnight; the reservation day is refunded.
+	now = now.Add(2 * time.Second)
+	m.RecordFailure(ctx, sig)
+	if got, _ := srv.Get("test:risk:daily:20240301"); got != "0" {
+		t.Fatalf("expected reservation day refunded, got %q", got)
+	}
+	if srv.Exists("test:risk:daily:20240302") {
+		t.Fatalf("expected the next day untouched")
+	}
+}
+
+func TestRedisManagerReapsLapsedOwners(t *testing.T) {
+	ctx := context.Background()
+	cfg := redisTestConfig()
+	cfg.Risk.CooldownSeconds = 0
+	cfg.Risk.MaxDailyTrades = 0
+	managers := newTestRedisManagers(t, cfg, 2)
+
+	now := time.Now()
+	for _, m := range managers {
+		m.now = func() time.Time { return now }
+	}
+	for i, symbol := range []string{"AUSDT", "BUSDT"} {
+		if d, err := managers[0].Evaluate(ctx, signal.Signal{Symbol: symbol, RawMessage: signal.Message{ID: int64(i)}}, 100); err != nil || !d.Allow {
+			t.Fatalf("expected %s allowed, got %+v (err=%v)", symbol, d, err)
+		}
+	}
+	if d, _ := managers[1].Evaluate(ctx, signal.Signal{Symbol: "CUSDT"}, 100); d.Allow {
+		t.Fatalf("expected open position limit while the owner is alive")
+	}
+
+	// The first instance stops renewing; its slots are reclaimed after the lease.
+	now = now.Add(ownerLease + time.Second)
+	if err := managers[1].heartbeat(ctx, now); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	if d, err := managers[1].Evaluate(ctx, signal.Signal{Symbol: "CUSDT"}, 100); err != nil || !d.Allow {
+		t.Fatalf("expected lapsed owner's slots reclaimed, got %+v (err=%v)", d, err)
+	}
+}
+
+func TestRedisManagerRestoreAfterRestart(t *testing.T) {
+	ctx := context.Background()
+	cfg := redisTestConfig()
+	cfg.Risk.CooldownSeconds = 0
+	cfg.Risk.MaxDailyTrades = 0
+	managers := newTestRedisManagers(t, cfg, 2)
+	before, after := managers[0], managers[1]
+
+	now := time.Now()
+	for _, m := range managers {
+		m.now = func() time.Time { return now }
+	}
+	for i, symbol := range []string{"AUSDT", "BUSDT"} {
+		if d, err := before.Evaluate(ctx, signal.Signal{Symbol: symbol, RawMessage: signal.Message{ID: int64(i)}}, 100); err != nil || !d.Allow {
+			t.Fatalf("expected %s allowed, got %+v (err=%v)", symbol, d, err)
+		}
+	}
+
+	// The process crashes and restarts with both positions in its book. The
+	// old lease lapses, but the restored counts keep the limit in force.
+	now = now.Add(ownerLease + time.Second)
+	if err := after.Restore(ctx, map[string]int{"AUSDT": 1, "BUSDT": 1}); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if d, err := after.Evaluate(ctx, signal.Signal{Symbol: "CUSDT"}, 100); err != nil || d.Allow || d.Reason != "max_open_positions" {
+		t.Fatalf("expected restored positions to hold the limit, got %+v (err=%v)", d, err)
+	}
+
+	// Closing a restored position frees its slot under the new owner.
+	after.RecordClose(ctx, "AUSDT")
+	if d, err := after.Evaluate(ctx, signal.Signal{Symbol: "CUSDT"}, 100); err != nil || !d.Allow {
+		t.Fatalf("expected slot freed by closing a restored position, got %+v (err=%v)", d, err)
+	}
+}
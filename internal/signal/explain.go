@@ -0,0 +1,113 @@
+package signal
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/user/mexc-bot/internal/exchange"
+)
+
+// Explanation is a step-by-step account of how Parse treated a message, for
+// tuning templates offline. Signal and Err are exactly what Parse returned.
+type Explanation struct {
+	Normalized string // text after Normalize; equal to the input when nothing changed
+	Templates  []TemplateTrace
+	Links      []LinkTrace
+	Signal     *Signal
+	Err        error
+}
+
+// TemplateTrace records each template rule and symbol source for one template.
+type TemplateTrace struct {
+	Name     string
+	Kind     Kind
+	Tokens   []TokenTrace
+	Pattern  string // empty when the template has none
+	Captures map[string]string
+	Sources  []SourceTrace
+	Err      error // nil when the template matched
+}
+
+// TokenTrace is one required, optional or forbidden token check.
+type TokenTrace struct {
+	Rule  string // required, optional or forbidden
+	Token string
+	Found bool
+}
+
+// SourceTrace is the outcome of one symbol source.
+type SourceTrace struct {
+	Source   string
+	PairCode string
+	Market   exchange.Market
+	Err      error
+}
+
+// LinkTrace is the outcome of one link candidate from the text or hidden links.
+type LinkTrace struct {
+	URL      string
+	PairCode string
+	Market   exchange.Market
+	Err      error
+}
+
+// Explain parses msg and records why each template and link did or did not match.
+func (p *Parser) Explain(ctx context.Context, msg Message) Explanation {
+	sig, err := p.Parse(ctx, msg)
+	ex := Explanation{
+		Normalized: Normalize(msg.Text),
+		Signal:     sig,
+		Err:        err,
+	}
+	text := strings.TrimSpace(ex.Normalized)
+	folded := Fold(text)
+
+	for _, tpl := range p.templates {
+		tr := TemplateTrace{Name: tpl.name, Kind: tpl.kind}
+		for _, rule := range []struct {
+			name   string
+			tokens []token
+		}{{"required", tpl.required}, {"optional", tpl.optional}, {"forbidden", tpl.forbidden}} {
+			for _, tok := range rule.tokens {
+				tr.Tokens = append(tr.Tokens, TokenTrace{Rule: rule.name, Token: tok.raw, Found: strings.Contains(folded, tok.fold)})
+			}
+		}
+		if tpl.pattern != nil {
+			tr.Pattern = tpl.pattern.String()
+		}
+		captures, matchErr := tpl.match(text, folded)
+		tr.Captures = captures
+		tr.Err = matchErr
+		if matchErr == nil {
+			for _, source := range tpl.symbolFrom {
+				res, err := p.resolveSymbol(ctx, source, msg, text, captures)
+				tr.Sources = append(tr.Sources, SourceTrace{Source: source, PairCode: res.pairCode, Market: res.market, Err: err})
+			}
+			if _, err := p.parseTemplate(ctx, tpl, msg, text, folded); err != nil {
+				tr.Err = err
+			}
+		}
+		ex.Templates = append(ex.Templates, tr)
+	}
+
+	candidates := strings.Fields(text)
+	for _, l := range msg.Links {
+		candidates = append(candidates, l.URL)
+	}
+	for _, candidate := range candidates {
+		if !strings.Contains(candidate, "://") {
+			continue
+		}
+		raw := strings.Trim(candidate, " \t\n\r,.;!")
+		lt := LinkTrace{URL: raw}
+		u, err := url.Parse(raw)
+		if err != nil {
+			lt.Err = err
+		} else {
+			lt.PairCode, lt.Market, lt.Err = p.matchLink(ctx, u, true)
+		}
+		ex.Links = append(ex.Links, lt)
+	}
+	return ex
+}
@@ -0,0 +1,149 @@
+package signal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Level is a price level quoted by a channel, either as an absolute price or as
+// a fractional move from entry (Pct 0.25 is +25%, -0.05 is -5%).
+type Level struct {
+	Price float64 `json:"price,omitempty"`
+	Pct   float64 `json:"pct,omitempty"`
+}
+
+// Resolve returns the absolute price of the level for the given entry price.
+func (l Level) Resolve(entry float64) float64 {
+	if l.Price > 0 {
+		return l.Price
+	}
+	return entry * (1 + l.Pct)
+}
+
+func (l Level) String() string {
+	if l.Price > 0 {
+		return strconv.FormatFloat(l.Price, 'f', -1, 64)
+	}
+	return fmt.Sprintf("%+g%%", l.Pct*100)
+}
+
+// EntryRange is the buy zone quoted by a channel. Low equals High for a single price.
+type EntryRange struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+func (r EntryRange) String() string {
+	if r.Low == r.High {
+		return strconv.FormatFloat(r.Low, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(r.Low, 'f', -1, 64) + "-" + strconv.FormatFloat(r.High, 'f', -1, 64)
+}
+
+var (
+	targetsPattern = regexp.MustCompile(`(?i)\b(?:targets?|tp\d*|take[\s-]*profits?)\s*[:=]\s*([^\n]+)`)
+	entryPattern   = regexp.MustCompile(`(?i)\b(?:entry(?:\s+(?:zone|price|range))?|buy\s+(?:zone|range|price))\s*[:=]\s*([^\n]+)`)
+	stopPattern    = regexp.MustCompile(`(?i)\b(?:stop[\s-]*loss|stop|sl)\s*[:=]\s*([^\n]+)`)
+	numberPattern  = regexp.MustCompile(`(\d+(?:[.,]\d+)*)\s*(%)?`)
+)
+
+// levels holds the optional trade levels found in a message.
+type levels struct {
+	targets []Level
+	entry   *EntryRange
+	stop    *Level
+}
+
+// extractLevels reads "Targets:", "Entry:" and "Stop loss:" style lines.
+// Percentages are relative to entry; stops are always taken as downside moves.
+func extractLevels(text string) levels {
+	var out levels
+
+	for _, m := range targetsPattern.FindAllStringSubmatch(text, -1) {
+		for _, n := range parseNumbers(m[1]) {
+			if n.pct {
+				out.targets = append(out.targets, Level{Pct: n.value / 100})
+			} else {
+				out.targets = append(out.targets, Level{Price: n.value})
+			}
+		}
+	}
+
+	if m := entryPattern.FindStringSubmatch(text); m != nil {
+		var prices []float64
+		for _, n := range parseNumbers(m[1]) {
+			if !n.pct {
+				prices = append(prices, n.value)
+			}
+		}
+		if len(prices) > 0 {
+			if len(prices) > 2 {
+				prices = prices[:2]
+			}
+			sort.Float64s(prices)
+			out.entry = &EntryRange{Low: prices[0], High: prices[len(prices)-1]}
+		}
+	}
+
+	if m := stopPattern.FindStringSubmatch(text); m != nil {
+		if nums := parseNumbers(m[1]); len(nums) > 0 {
+			n := nums[0]
+			if n.pct {
+				out.stop = &Level{Pct: -n.value / 100}
+			} else {
+				out.stop = &Level{Price: n.value}
+			}
+		}
+	}
+
+	return out
+}
+
+type number struct {
+	value float64
+	pct   bool
+}
+
+func parseNumbers(s string) []number {
+	var out []number
+	for _, m := range numberPattern.FindAllStringSubmatch(s, -1) {
+		for _, raw := range splitNumber(m[1]) {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil || v <= 0 {
+				continue
+			}
+			out = append(out, number{value: v, pct: m[2] != ""})
+		}
+	}
+	return out
+}
+
+// splitNumber reads the commas in a run of digits: thousands separators when
+// each is followed by exactly three digits ("42,000", "1,250.5"), a decimal
+// comma when there is one and no point ("0,0015"), and otherwise a list
+// written without spaces ("0.002,0.0025").
+func splitNumber(s string) []string {
+	parts := strings.Split(s, ",")
+	if len(parts) == 1 {
+		return parts
+	}
+	thousands := parts[0] != "0" && !strings.Contains(parts[0], ".")
+	for i, part := range parts[1:] {
+		digits, frac, hasFrac := strings.Cut(part, ".")
+		if len(digits) != 3 || (hasFrac && (i < len(parts)-2 || frac == "")) {
+			thousands = false
+			break
+		}
+	}
+	switch {
+	case thousands:
+		return []string{strings.Join(parts, "")}
+	case len(parts) == 2 && !strings.Contains(s, "."):
+		return []string{parts[0] + "." + parts[1]}
+	default:
+		return parts
+	}
+}
@@ -0,0 +1,301 @@
+package signal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/exchange"
+	"github.com/user/mexc-bot/internal/metrics"
+)
+
+// pairPath matches a BASE_QUOTE (or BASE-QUOTE) path segment.
+const pairPath = `(?P<pair>[A-Za-z0-9]+(?:[_-]|%5F)[A-Za-z0-9]+)/?$`
+
+// localePrefix matches optional locale segments such as /en-US or /ru.
+const localePrefix = `^(?:/[a-z]{2}(?:-[A-Za-z]{2,4})?)?`
+
+// builtinLinks are the MEXC URL forms recognised after link_host/link_path_prefix
+// and any [[parser.links]] rules.
+var builtinLinks = []config.LinkRuleConfig{
+	{Name: "mexc-spot", Hosts: []string{"www.mexc.com", "mexc.com"}, PathPattern: localePrefix + `/exchange/` + pairPath, Market: string(exchange.MarketSpot)},
+	{Name: "mexc-futures", Hosts: []string{"futures.mexc.com"}, PathPattern: localePrefix + `/exchange/` + pairPath, Market: string(exchange.MarketFutures)},
+	{Name: "mexc-futures-path", Hosts: []string{"www.mexc.com", "mexc.com"}, PathPattern: localePrefix + `/futures/` + pairPath, Market: string(exchange.MarketFutures)},
+	{Name: "mexc-query", Hosts: []string{"www.mexc.com", "mexc.com"}, QueryParam: "symbol", Market: string(exchange.MarketSpot)},
+}
+
+// linkRule maps one URL form onto a pair code and market.
+type linkRule struct {
+	name   string
+	hosts  []string
+	path   *regexp.Regexp
+	query  string
+	market exchange.Market
+	short  bool
+}
+
+func compileLinkRule(rc config.LinkRuleConfig) (*linkRule, error) {
+	rule := &linkRule{
+		name:   rc.Name,
+		hosts:  rc.Hosts,
+		query:  rc.QueryParam,
+		market: exchange.Market(rc.Market),
+		short:  rc.Short,
+	}
+	if rule.market == "" {
+		rule.market = exchange.MarketSpot
+	}
+	if rc.PathPattern != "" {
+		re, err := regexp.Compile(rc.PathPattern)
+		if err != nil {
+			return nil, fmt.Errorf("link rule %s: %w", rc.Name, err)
+		}
+		rule.path = re
+	}
+	return rule, nil
+}
+
+// compileLinkRules builds the resolver registry: the legacy link_host +
+// link_path_prefix rule first, then configured rules, then the built-in MEXC forms.
+// Rules that fail to compile are skipped; config validation reports them.
+func compileLinkRules(cfg config.ParserConfig) []*linkRule {
+	all := make([]config.LinkRuleConfig, 0, 1+len(cfg.Links)+len(builtinLinks))
+	if cfg.LinkHost != "" {
+		all = append(all, config.LinkRuleConfig{
+			Name:        "default",
+			Hosts:       []string{cfg.LinkHost},
+			PathPattern: "^" + regexp.QuoteMeta(cfg.LinkPathPrefix) + `(?P<pair>.*)$`,
+		})
+	}
+	all = append(all, cfg.Links...)
+	all = append(all, builtinLinks...)
+
+	rules := make([]*linkRule, 0, len(all))
+	for _, rc := range all {
+		if rule, err := compileLinkRule(rc); err == nil {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func (r *linkRule) matchesHost(host string) bool {
+	for _, h := range r.hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// rawPair extracts the pair component of u; ok is false when the rule does not apply.
+func (r *linkRule) rawPair(u *url.URL) (pair string, ok bool) {
+	if r.path != nil {
+		m := r.path.FindStringSubmatch(u.EscapedPath())
+		if m == nil {
+			return "", false
+		}
+		if r.query == "" {
+			if i := r.path.SubexpIndex("pair"); i > 0 {
+				return m[i], true
+			}
+			return "", true
+		}
+	}
+	if r.query != "" {
+		v := u.Query().Get(r.query)
+		return v, v != ""
+	}
+	return "", false
+}
+
+var linkExpandSeconds = metrics.Default.Histogram("mexc_bot_link_expand_seconds",
+	"Time spent following short links over the network, by result (ok, error).",
+	[]float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5}, "result")
+
+// LinkExpander follows short links to the URL they redirect to.
+type LinkExpander interface {
+	Expand(ctx context.Context, u *url.URL) (*url.URL, error)
+}
+
+const (
+	expandCacheTTL     = time.Hour
+	expandFailureTTL   = time.Minute
+	expandCacheEntries = 1024
+)
+
+// RedirectExpander resolves short links by following HTTP redirects. Results
+// are cached per URL, so the same link in a burst of copies, or re-examined by
+// Explain, costs one request.
+type RedirectExpander struct {
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]expansion
+}
+
+type expansion struct {
+	target  *url.URL
+	err     error
+	expires time.Time
+}
+
+// NewRedirectExpander returns an expander that gives up after timeout.
+func NewRedirectExpander(timeout time.Duration) *RedirectExpander {
+	return &RedirectExpander{
+		client: &http.Client{Timeout: timeout},
+		now:    time.Now,
+		cache:  make(map[string]expansion),
+	}
+}
+
+// Expand issues a HEAD request and returns the final URL after redirects.
+// Failures are remembered for a minute, successes for an hour.
+func (e *RedirectExpander) Expand(ctx context.Context, u *url.URL) (*url.URL, error) {
+	key := u.String()
+	now := e.now()
+	e.mu.Lock()
+	cached, ok := e.cache[key]
+	e.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.target, cached.err
+	}
+
+	target, err := e.head(ctx, key)
+	if err != nil && ctx.Err() != nil {
+		// Cancelled by the caller, not a verdict on the link.
+		return nil, err
+	}
+	ttl := expandCacheTTL
+	if err != nil {
+		ttl = expandFailureTTL
+	}
+	e.mu.Lock()
+	if len(e.cache) >= expandCacheEntries {
+		for k, v := range e.cache {
+			if !now.Before(v.expires) {
+				delete(e.cache, k)
+			}
+		}
+		if len(e.cache) >= expandCacheEntries {
+			clear(e.cache)
+		}
+	}
+	e.cache[key] = expansion{target: target, err: err, expires: now.Add(ttl)}
+	e.mu.Unlock()
+	return target, err
+}
+
+func (e *RedirectExpander) head(ctx context.Context, rawURL string) (*url.URL, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		linkExpandSeconds.Observe(time.Since(start).Seconds(), "error")
+		return nil, err
+	}
+	resp.Body.Close()
+	linkExpandSeconds.Observe(time.Since(start).Seconds(), "ok")
+	return resp.Request.URL, nil
+}
+
+var errShortLink = errors.New("short link not expanded")
+
+// resolveLink returns the pair code and market of the first exchange link in
+// text, falling back to links hidden behind text_url entities and inline
+// buttons ("Click here to trade").
+func (p *Parser) resolveLink(ctx context.Context, text string, links []Link) (string, exchange.Market, error) {
+	candidates := strings.Fields(text)
+	for _, l := range links {
+		candidates = append(candidates, l.URL)
+	}
+
+	var lastErr error = errMissingLink
+	for _, candidate := range candidates {
+		if !strings.Contains(candidate, "://") {
+			continue
+		}
+		u, err := url.Parse(strings.Trim(candidate, " \t\n\r,.;!"))
+		if err != nil {
+			continue
+		}
+		pair, market, err := p.matchLink(ctx, u, true)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return pair, market, nil
+	}
+	return "", "", lastErr
+}
+
+func (p *Parser) matchLink(ctx context.Context, u *url.URL, expand bool) (string, exchange.Market, error) {
+	var lastErr error = errMissingLink
+	for _, rule := range p.links {
+		if !rule.matchesHost(u.Host) {
+			continue
+		}
+		if rule.short {
+			if !expand || p.expander == nil {
+				lastErr = fmt.Errorf("%w: %s", errShortLink, u.Host)
+				continue
+			}
+			target, err := p.expander.Expand(ctx, u)
+			if err != nil {
+				lastErr = fmt.Errorf("expand %s: %w", u.Host, err)
+				continue
+			}
+			return p.matchLink(ctx, target, false)
+		}
+		raw, ok := rule.rawPair(u)
+		if !ok {
+			lastErr = errUnsupportedLink
+			continue
+		}
+		pair, err := p.normalizePair(raw)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return pair, rule.market, nil
+	}
+	return "", "", lastErr
+}
+
+// normalizePair turns a raw URL pair such as twif-usdt, TWIF%5FUSDT or TWIFUSDT
+// into the canonical TWIF_USDT form.
+func (p *Parser) normalizePair(raw string) (string, error) {
+	raw = strings.Trim(raw, "/")
+	if raw == "" {
+		return "", errMissingSymbol
+	}
+	sep := p.cfg.PairSeparator
+	if sep == "" {
+		return "", errInvalidSeparator
+	}
+
+	upper := strings.ToUpper(raw)
+	// Some links include hyphen or encoded characters; normalize them to the separator.
+	upper = strings.ReplaceAll(upper, "%5F", sep) // URL encoded underscore
+	upper = strings.ReplaceAll(upper, "-", sep)
+	if !strings.Contains(upper, strings.ToUpper(sep)) {
+		quote := strings.ToUpper(p.cfg.QuoteAsset)
+		base, found := strings.CutSuffix(upper, quote)
+		if !found || base == "" {
+			return "", fmt.Errorf("pair does not contain separator %q: %s", sep, upper)
+		}
+		upper = base + sep + quote
+	}
+	return upper, nil
+}
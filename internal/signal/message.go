@@ -0,0 +1,70 @@
+package signal
+
+import (
+	"strconv"
+	"time"
+)
+
+// Message represents the subset of Telegram data the parser cares about.
+type Message struct {
+	Account      string // ingestion session that delivered the message
+	ID           int64
+	ChatID       int64  // source chat; message IDs are only unique within a chat
+	ChatType     string // channel, chat (basic group) or user; IDs only unique per type
+	ChatTitle    string // channel or group title, when known
+	ChatUsername string // public @username of the source chat, without the @
+	SenderID     int64  // author user ID; zero for anonymous channel posts
+	SenderName   string // author username or channel post signature
+	Text         string
+	Entities     []Entity  // formatting entities as delivered by Telegram
+	Links        []Link    // URLs not visible in Text: text_url entities and inline buttons
+	Timestamp    time.Time // server-side post time
+	EditedAt     time.Time // server-side edit time, for edits that report one
+	ReceivedAt   time.Time // local time the update reached the bot
+	Edited       bool      // true for edits of a previously posted message
+	Recovered    bool      // fetched by update gap recovery rather than delivered live
+}
+
+// ChatKey identifies a source chat. IDs are only unique within a chat type,
+// and sources other than Telegram (webhook, file) use their own types.
+type ChatKey struct {
+	Type string
+	ID   int64
+}
+
+func (k ChatKey) String() string {
+	return k.Type + ":" + strconv.FormatInt(k.ID, 10)
+}
+
+// Chat returns the key of the source chat.
+func (m Message) Chat() ChatKey {
+	return ChatKey{Type: m.ChatType, ID: m.ChatID}
+}
+
+// Entity is a raw message entity. Offset and Length are in UTF-16 code units,
+// as Telegram reports them.
+type Entity struct {
+	Type   string // e.g. url, text_url, hashtag, cashtag, bold
+	Offset int
+	Length int
+	URL    string // target of text_url entities
+}
+
+// Link is a URL carried outside the message text.
+type Link struct {
+	URL   string
+	Kind  string // text_url or button
+	Label string // anchor text or button caption
+}
+
+// Source returns a human-readable label for the originating chat.
+func (m Message) Source() string {
+	switch {
+	case m.ChatUsername != "":
+		return "@" + m.ChatUsername
+	case m.ChatTitle != "":
+		return m.ChatTitle
+	default:
+		return strconv.FormatInt(m.ChatID, 10)
+	}
+}
@@ -0,0 +1,86 @@
+package signal
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// confusables maps look-alike letters from other scripts onto the Latin letter
+// they imitate. It covers the Cyrillic and Greek capitals and lowercase letters
+// pump channels use to dodge keyword scrapers, not the full Unicode confusables set.
+var confusables = map[rune]rune{
+	// Cyrillic capitals.
+	'А': 'A', 'В': 'B', 'Е': 'E', 'К': 'K', 'М': 'M', 'Н': 'H', 'О': 'O', 'Р': 'P',
+	'С': 'C', 'Т': 'T', 'Х': 'X', 'У': 'Y', 'І': 'I', 'Ј': 'J', 'Ѕ': 'S', 'Ԁ': 'D',
+	'Ԛ': 'Q', 'Ԝ': 'W', 'Ү': 'Y', 'Ғ': 'F',
+	// Cyrillic lowercase.
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p',
+	'с': 'c', 'т': 't', 'х': 'x', 'у': 'y', 'і': 'i', 'ј': 'j', 'ѕ': 's', 'ԁ': 'd',
+	'ԛ': 'q', 'ԝ': 'w', 'ү': 'y', 'һ': 'h',
+	// Greek capitals.
+	'Α': 'A', 'Β': 'B', 'Ε': 'E', 'Ζ': 'Z', 'Η': 'H', 'Ι': 'I', 'Κ': 'K', 'Μ': 'M',
+	'Ν': 'N', 'Ο': 'O', 'Ρ': 'P', 'Τ': 'T', 'Υ': 'Y', 'Χ': 'X',
+	// Greek lowercase.
+	'α': 'a', 'ο': 'o', 'ρ': 'p', 'ν': 'v', 'τ': 't', 'ι': 'i', 'κ': 'k', 'χ': 'x',
+	// Latin small capitals and other frequent stand-ins.
+	'ᴀ': 'A', 'ʙ': 'B', 'ᴄ': 'C', 'ᴅ': 'D', 'ᴇ': 'E', 'ɢ': 'G', 'ʜ': 'H', 'ɪ': 'I',
+	'ᴊ': 'J', 'ᴋ': 'K', 'ʟ': 'L', 'ᴍ': 'M', 'ɴ': 'N', 'ᴏ': 'O', 'ᴘ': 'P', 'ʀ': 'R',
+	'ꜱ': 'S', 'ᴛ': 'T', 'ᴜ': 'U', 'ᴠ': 'V', 'ᴡ': 'W', 'ʏ': 'Y', 'ᴢ': 'Z',
+	// Latin script g, which NFKC leaves alone.
+	'Ɡ': 'G', 'ɡ': 'g',
+}
+
+// Normalize returns text in the form the parser extracts from: invisible
+// format characters removed, NFKC applied (so 𝐌𝐄𝐆𝐀 and fullwidth letters
+// become ASCII) and homoglyphs folded to Latin. Case is preserved because
+// links, template patterns and tickers are case-sensitive.
+func Normalize(text string) string {
+	text = strings.Map(func(r rune) rune {
+		if isInvisible(r) {
+			return -1
+		}
+		return r
+	}, text)
+	text = norm.NFKC.String(text)
+	return strings.Map(func(r rune) rune {
+		if c, ok := confusables[r]; ok {
+			return c
+		}
+		return r
+	}, text)
+}
+
+// Fold reduces text to the form template tokens are compared in: Normalize,
+// then case folding, with emoji and symbols treated as spaces, combining marks
+// dropped and whitespace runs collapsed to one space, so that "MEGA🚀PUMP" and
+// "mega  pump" both match the token "MEGA PUMP" while word boundaries survive.
+func Fold(text string) string {
+	text = cases.Fold().String(Normalize(text))
+	var b strings.Builder
+	b.Grow(len(text))
+	space := false
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me):
+			continue
+		case unicode.IsSpace(r) || unicode.In(r, unicode.So, unicode.Sk):
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isInvisible reports zero-width and other format characters (ZWSP, ZWJ,
+// soft hyphen, BOM, bidi marks) and variation selectors.
+func isInvisible(r rune) bool {
+	return unicode.Is(unicode.Cf, r) || unicode.Is(unicode.Variation_Selector, r)
+}
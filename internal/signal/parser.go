@@ -1,36 +1,98 @@
 package signal
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/exchange"
 )
 
-// Message represents the subset of Telegram data the parser cares about.
-type Message struct {
-	ID        int64
-	Text      string
-	Timestamp time.Time
-}
+// Kind is what a signal asks for.
+type Kind string
+
+const (
+	KindOpen         Kind = "open"
+	KindClose        Kind = "close"
+	KindPartialClose Kind = "partial_close"
+	KindCancel       Kind = "cancel"
+)
+
+// defaultCloseFraction sizes partial closes that state no percentage.
+const defaultCloseFraction = 0.5
 
 // Signal is the normalized trading instruction extracted from a Telegram message.
 type Signal struct {
 	RawMessage Message
-	Symbol     string // canonical exchange symbol, e.g. TWIFUSDT
-	PairCode   string // raw pair component from the URL, e.g. TWIF_USDT
+	Kind       Kind
+	Symbol     string          // canonical exchange symbol, e.g. TWIFUSDT
+	PairCode   string          // raw pair component from the URL, e.g. TWIF_USDT
+	Market     exchange.Market // spot unless a futures link was matched
+	Template   string          // name of the template that matched
+	SymbolFrom string          // symbol source that resolved Symbol: link, capture, header, hashtag or tags
+	Confidence float64         // 1 for explicit sources; the tag score when SymbolFrom is tags
+
+	// CloseFraction is the share of the position a partial_close exits.
+	CloseFraction float64
+	// ChatWide marks an exit without a symbol that applies to every position
+	// from the chat; only templates with chat_wide = true produce one.
+	ChatWide bool
+
+	// Channel-quoted levels; empty when the message does not state them.
+	Targets []Level
+	Entry   *EntryRange
+	Stop    *Level
 }
 
-// Parser enforces the message template and extracts actionable data.
+// Parser enforces the message templates and extracts actionable data.
 type Parser struct {
-	cfg config.ParserConfig
+	cfg       config.ParserConfig
+	templates []*template
+	links     []*linkRule
+	symbols   SymbolDirectory
+	expander  LinkExpander
 }
 
+// NewParser compiles the configured templates. Without [[parser.templates]] the
+// legacy required_tokens + link rules act as a single template named "default".
 func NewParser(cfg config.ParserConfig) *Parser {
-	return &Parser{cfg: cfg}
+	if cfg.QuoteAsset == "" {
+		cfg.QuoteAsset = "USDT"
+	}
+	tpls := cfg.Templates
+	if len(tpls) == 0 {
+		tpls = []config.TemplateConfig{{
+			Name:           "default",
+			RequiredTokens: cfg.RequiredTokens,
+		}}
+	}
+
+	p := &Parser{cfg: cfg, links: compileLinkRules(cfg)}
+	for _, tc := range tpls {
+		tpl := compileTemplate(tc)
+		if cfg.TagFallback && !slices.Contains(tpl.symbolFrom, symbolFromTags) {
+			tpl.symbolFrom = append(tpl.symbolFrom, symbolFromTags)
+		}
+		p.templates = append(p.templates, tpl)
+	}
+	return p
+}
+
+// SetSymbols makes the tags symbol source reject tickers that are not listed
+// against the quote asset. Without a directory tag candidates are scored on the
+// message text alone.
+func (p *Parser) SetSymbols(dir SymbolDirectory) {
+	p.symbols = dir
+}
+
+// SetLinkExpander enables short-link rules; without an expander they never match.
+func (p *Parser) SetLinkExpander(expander LinkExpander) {
+	p.expander = expander
 }
 
 var (
@@ -39,90 +101,146 @@ var (
 	errMissingSymbol    = errors.New("unable to resolve symbol from link")
 	errMissingToken     = errors.New("required template token missing")
 	errInvalidSeparator = errors.New("invalid pair separator")
+	errForbiddenToken   = errors.New("forbidden template token present")
+	errPatternMismatch  = errors.New("template pattern did not match")
+	errOptionalTokens   = errors.New("too few optional template tokens")
+	errNoTemplate       = errors.New("no template matched")
 )
 
-// Parse validates the message against the template and returns a trading signal.
-func (p *Parser) Parse(msg Message) (*Signal, error) {
-	text := strings.TrimSpace(msg.Text)
+// Parse validates the message against each template in order and returns the
+// signal from the first that matches. Text is normalised first (see Normalize
+// and Fold) so obfuscated posts match the same templates as plain ones.
+func (p *Parser) Parse(ctx context.Context, msg Message) (*Signal, error) {
+	text := strings.TrimSpace(Normalize(msg.Text))
 	if text == "" {
 		return nil, fmt.Errorf("empty message body")
 	}
-
-	if err := p.requireTokens(text); err != nil {
-		return nil, err
+	folded := Fold(text)
+
+	var failures []string
+	var lastErr error
+	for _, tpl := range p.templates {
+		sig, err := p.parseTemplate(ctx, tpl, msg, text, folded)
+		if err == nil {
+			return sig, nil
+		}
+		lastErr = err
+		failures = append(failures, fmt.Sprintf("%s: %v", tpl.name, err))
 	}
-
-	link, err := p.extractLink(text)
-	if err != nil {
-		return nil, err
+	if len(p.templates) == 1 {
+		return nil, lastErr
 	}
+	return nil, fmt.Errorf("%w (%s)", errNoTemplate, strings.Join(failures, "; "))
+}
 
-	pairCode, err := p.resolvePair(link)
+func (p *Parser) parseTemplate(ctx context.Context, tpl *template, msg Message, text, folded string) (*Signal, error) {
+	captures, err := tpl.match(text, folded)
 	if err != nil {
 		return nil, err
 	}
 
-	symbol := strings.ToUpper(strings.ReplaceAll(pairCode, p.cfg.PairSeparator, ""))
-	if symbol == "" {
-		return nil, errMissingSymbol
+	if tpl.kind != KindOpen {
+		return p.exitSignal(ctx, tpl, msg, text, captures)
 	}
 
-	return &Signal{
-		RawMessage: msg,
-		Symbol:     symbol,
-		PairCode:   strings.ToUpper(pairCode),
-	}, nil
-}
-
-func (p *Parser) requireTokens(text string) error {
-	for _, token := range p.cfg.RequiredTokens {
-		if !strings.Contains(text, token) {
-			return fmt.Errorf("%w: %s", errMissingToken, token)
+	var lastErr error = errMissingSymbol
+	for _, source := range tpl.symbolFrom {
+		res, err := p.resolveSymbol(ctx, source, msg, text, captures)
+		if err != nil {
+			lastErr = err
+			continue
 		}
+		lv := extractLevels(text)
+		return &Signal{
+			RawMessage: msg,
+			Kind:       KindOpen,
+			Symbol:     res.symbol,
+			PairCode:   res.pairCode,
+			Market:     res.market,
+			Template:   tpl.name,
+			SymbolFrom: source,
+			Confidence: res.confidence,
+			Targets:    lv.targets,
+			Entry:      lv.entry,
+			Stop:       lv.stop,
+		}, nil
 	}
-	return nil
+	return nil, lastErr
 }
 
-func (p *Parser) extractLink(text string) (*url.URL, error) {
-	segments := strings.Fields(text)
-	for _, segment := range segments {
-		if !strings.Contains(segment, "://") {
-			continue
-		}
-
-		u, err := url.Parse(strings.Trim(segment, " \t\n\r,.;!"))
+// exitSignal builds a close, partial_close or cancel signal. A post without
+// a resolvable symbol is rejected unless the template is chat_wide, in which
+// case it applies to every position from the chat.
+func (p *Parser) exitSignal(ctx context.Context, tpl *template, msg Message, text string, captures map[string]string) (*Signal, error) {
+	sig := &Signal{RawMessage: msg, Kind: tpl.kind, Template: tpl.name, Market: exchange.MarketSpot}
+	var lastErr error = errMissingSymbol
+	for _, source := range tpl.symbolFrom {
+		res, err := p.resolveSymbol(ctx, source, msg, text, captures)
 		if err != nil {
+			lastErr = err
 			continue
 		}
-		if !strings.EqualFold(u.Host, p.cfg.LinkHost) {
-			continue
+		sig.Symbol, sig.PairCode, sig.Market, sig.SymbolFrom, sig.Confidence = res.symbol, res.pairCode, res.market, source, res.confidence
+		break
+	}
+	if sig.Symbol == "" {
+		if !tpl.chatWide {
+			return nil, fmt.Errorf("%s signal names no symbol: %w", tpl.kind, lastErr)
 		}
-		if !strings.HasPrefix(u.Path, p.cfg.LinkPathPrefix) {
-			continue
+		sig.ChatWide = true
+	}
+	if tpl.kind == KindPartialClose {
+		sig.CloseFraction = tpl.fraction
+		if m := percentPattern.FindStringSubmatch(text); m != nil {
+			if v, err := strconv.ParseFloat(m[1], 64); err == nil && v > 0 && v <= 100 {
+				sig.CloseFraction = v / 100
+			}
+		}
+		if sig.CloseFraction <= 0 {
+			sig.CloseFraction = defaultCloseFraction
 		}
-		return u, nil
 	}
-	return nil, errMissingLink
+	return sig, nil
 }
 
-func (p *Parser) resolvePair(link *url.URL) (string, error) {
-	raw := strings.TrimPrefix(link.Path, p.cfg.LinkPathPrefix)
-	if raw == "" {
-		return "", errMissingSymbol
+// percentPattern finds the share in "close 50%" style partial exits. It is
+// anchored to an exit keyword, optionally followed by filler words, so the
+// gain in "up 300%, close 50%" is not taken for the share.
+var percentPattern = regexp.MustCompile(`(?i)\b(?:close|sell|exit|secure|book|take|tp)(?:[\s:\-]+|\s*\b(?:partial|profits?|now|off|out)\b)*\s*(\d+(?:\.\d+)?)\s*%`)
+
+// resolution is what a symbol source produced.
+type resolution struct {
+	pairCode   string
+	symbol     string
+	market     exchange.Market
+	confidence float64
+}
+
+func (p *Parser) resolveSymbol(ctx context.Context, source string, msg Message, text string, captures map[string]string) (resolution, error) {
+	res := resolution{market: exchange.MarketSpot, confidence: 1}
+	var err error
+	switch source {
+	case symbolFromLink:
+		res.pairCode, res.market, err = p.resolveLink(ctx, text, msg.Links)
+	case symbolFromCapture:
+		res.pairCode, err = p.pairFromCaptures(captures)
+	case symbolFromHeader:
+		res.pairCode, err = p.pairFromHeader(text)
+	case symbolFromHashtag:
+		res.pairCode, err = p.pairFromHashtag(text)
+	case symbolFromTags:
+		res.pairCode, res.confidence, err = p.pairFromTags(text)
+	default:
+		err = fmt.Errorf("unknown symbol source %q", source)
 	}
-	raw = strings.Trim(raw, "/")
-	sep := p.cfg.PairSeparator
-	if sep == "" {
-		return "", errInvalidSeparator
+	if err != nil {
+		return resolution{}, err
 	}
 
-	upper := strings.ToUpper(raw)
-	if !strings.Contains(upper, strings.ToUpper(sep)) {
-		return "", fmt.Errorf("pair does not contain separator %q: %s", sep, upper)
+	res.pairCode = strings.ToUpper(res.pairCode)
+	res.symbol = strings.ReplaceAll(res.pairCode, strings.ToUpper(p.cfg.PairSeparator), "")
+	if res.symbol == "" {
+		return resolution{}, errMissingSymbol
 	}
-	// Some links include hyphen or encoded characters; normalize them to the separator.
-	upper = strings.ReplaceAll(upper, "-", sep)
-	upper = strings.ReplaceAll(upper, "%5F", sep) // URL encoded underscore
-
-	return upper, nil
+	return res, nil
 }
@@ -1,10 +1,21 @@
 package signal
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/exchange"
 )
 
 func baseConfig() config.ParserConfig {
@@ -32,7 +43,7 @@ Buy and hold for massive profits🚀 Targets: 2000%-5000%`,
 		Timestamp: time.Unix(1710000000, 0),
 	}
 
-	signal, err := parser.Parse(msg)
+	signal, err := parser.Parse(context.Background(), msg)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -43,6 +54,63 @@ Buy and hold for massive profits🚀 Targets: 2000%-5000%`,
 	if signal.PairCode != "TWIF_USDT" {
 		t.Fatalf("expected pair code TWIF_USDT, got %s", signal.PairCode)
 	}
+	if len(signal.Targets) != 2 || signal.Targets[0].Pct != 20 || signal.Targets[1].Pct != 50 {
+		t.Fatalf("expected targets +2000%%/+5000%%, got %+v", signal.Targets)
+	}
+}
+
+func TestParserLevels(t *testing.T) {
+	parser := NewParser(baseConfig())
+	msg := Message{Text: `MEGA PUMP SIGNAL https://www.mexc.com/exchange/ABC_USDT
+Entry zone: 0.0015 - 0.0012
+Targets: 0.002, 0.0025
+Stop loss: -10%`}
+
+	sig, err := parser.Parse(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sig.Entry == nil || sig.Entry.Low != 0.0012 || sig.Entry.High != 0.0015 {
+		t.Fatalf("unexpected entry range: %+v", sig.Entry)
+	}
+	if len(sig.Targets) != 2 || sig.Targets[0].Price != 0.002 || sig.Targets[1].Price != 0.0025 {
+		t.Fatalf("unexpected targets: %+v", sig.Targets)
+	}
+	if sig.Stop == nil || sig.Stop.Pct != -0.1 {
+		t.Fatalf("unexpected stop: %+v", sig.Stop)
+	}
+	if got := sig.Stop.Resolve(0.002); math.Abs(got-0.0018) > 1e-12 {
+		t.Fatalf("expected stop at 0.0018, got %v", got)
+	}
+}
+
+func TestParserLevelSeparators(t *testing.T) {
+	cases := []struct {
+		text    string
+		targets []float64
+		stop    float64
+	}{
+		// Thousands separators.
+		{"TP: 1,250, 1,300.5\nSL: 42,000", []float64{1250, 1300.5}, 42000},
+		{"Targets: 1,250,000\nStop: 900,000", []float64{1250000}, 900000},
+		// Decimal commas.
+		{"TP: 0,0025 1,5\nSL: 0,125", []float64{0.0025, 1.5}, 0.125},
+		// A list written without spaces.
+		{"Targets: 0.002,0.0025\nSL: 0.0018", []float64{0.002, 0.0025}, 0.0018},
+	}
+	for _, tc := range cases {
+		lv := extractLevels(tc.text)
+		var got []float64
+		for _, l := range lv.targets {
+			got = append(got, l.Price)
+		}
+		if !slices.Equal(got, tc.targets) {
+			t.Fatalf("%q: expected targets %v, got %v", tc.text, tc.targets, got)
+		}
+		if lv.stop == nil || lv.stop.Price != tc.stop {
+			t.Fatalf("%q: expected stop %v, got %+v", tc.text, tc.stop, lv.stop)
+		}
+	}
 }
 
 func TestParserMissingTokens(t *testing.T) {
@@ -53,7 +121,7 @@ func TestParserMissingTokens(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	if _, err := parser.Parse(msg); err == nil {
+	if _, err := parser.Parse(context.Background(), msg); err == nil {
 		t.Fatalf("expected error when required tokens missing")
 	}
 }
@@ -66,7 +134,7 @@ func TestParserInvalidLink(t *testing.T) {
 		Timestamp: time.Now(),
 	}
 
-	if _, err := parser.Parse(msg); err == nil {
+	if _, err := parser.Parse(context.Background(), msg); err == nil {
 		t.Fatalf("expected error when link host is invalid")
 	}
 }
@@ -78,7 +146,333 @@ func TestParserMissingPair(t *testing.T) {
 		Text: "MEGA PUMP SIGNAL ... Targets ... https://www.mexc.com/exchange/",
 	}
 
-	if _, err := parser.Parse(msg); err == nil {
+	if _, err := parser.Parse(context.Background(), msg); err == nil {
 		t.Fatalf("expected error when pair missing")
 	}
 }
+
+func TestParserTemplatesInOrder(t *testing.T) {
+	cfg := baseConfig()
+	cfg.QuoteAsset = "USDT"
+	cfg.Templates = []config.TemplateConfig{
+		{
+			Name:            "results",
+			RequiredTokens:  []string{"MEGA PUMP SIGNAL"},
+			ForbiddenTokens: []string{"RESULTS"},
+			SymbolFrom:      []string{"link"},
+		},
+		{
+			Name:       "header",
+			Pattern:    `(?i)NEW LISTING`,
+			SymbolFrom: []string{"link", "header", "hashtag"},
+		},
+		{
+			Name:           "capture",
+			RequiredTokens: []string{"COIN:"},
+			OptionalTokens: []string{"Exchange: MEXC", "Buy now"},
+			MinOptional:    1,
+			Pattern:        `COIN:\s*\$(?P<base>[A-Z0-9]+)`,
+			SymbolFrom:     []string{"capture"},
+		},
+	}
+	parser := NewParser(cfg)
+
+	cases := []struct {
+		name     string
+		text     string
+		template string
+		source   string
+		symbol   string
+		wantErr  bool
+	}{
+		{"link template", "MEGA PUMP SIGNAL https://www.mexc.com/exchange/TWIF_USDT", "results", "link", "TWIFUSDT", false},
+		{"forbidden falls through", "MEGA PUMP SIGNAL RESULTS https://www.mexc.com/exchange/TWIF_USDT", "", "", "", true},
+		{"header symbol", "NEW LISTING\nBUYING #TWIF/USDT now", "header", "header", "TWIFUSDT", false},
+		{"hashtag symbol", "new listing soon #USDT #PEPE2 🚀", "header", "hashtag", "PEPE2USDT", false},
+		{"capture symbol", "COIN: $ABC\nExchange: MEXC", "capture", "capture", "ABCUSDT", false},
+		{"optional tokens required", "COIN: $ABC", "", "", "", true},
+	}
+	for _, tc := range cases {
+		sig, err := parser.Parse(context.Background(), Message{ID: 1, Text: tc.text})
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected error, got %+v", tc.name, sig)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if sig.Template != tc.template || sig.SymbolFrom != tc.source || sig.Symbol != tc.symbol {
+			t.Fatalf("%s: got template=%s source=%s symbol=%s", tc.name, sig.Template, sig.SymbolFrom, sig.Symbol)
+		}
+	}
+}
+
+func TestParserExitSignals(t *testing.T) {
+	cfg := baseConfig()
+	cfg.QuoteAsset = "USDT"
+	cfg.Templates = []config.TemplateConfig{
+		{Name: "partial", RequiredTokens: []string{"close"}, SymbolFrom: []string{"hashtag"}, Kind: "partial_close"},
+		{Name: "panic", RequiredTokens: []string{"EXIT ALL"}, Kind: "close", ChatWide: true},
+		{Name: "cancel", RequiredTokens: []string{"cancel"}, SymbolFrom: []string{"hashtag"}, Kind: "cancel"},
+	}
+	parser := NewParser(cfg)
+
+	sig, err := parser.Parse(context.Background(), Message{ID: 1, Text: "#ABC up 300%, close 50% now"})
+	if err != nil {
+		t.Fatalf("partial: unexpected error: %v", err)
+	}
+	if sig.Kind != KindPartialClose || sig.Symbol != "ABCUSDT" || sig.CloseFraction != 0.5 {
+		t.Fatalf("partial: got kind=%s symbol=%s fraction=%v", sig.Kind, sig.Symbol, sig.CloseFraction)
+	}
+
+	sig, err = parser.Parse(context.Background(), Message{ID: 2, Text: "EXIT ALL positions"})
+	if err != nil {
+		t.Fatalf("chat-wide: unexpected error: %v", err)
+	}
+	if sig.Symbol != "" || !sig.ChatWide {
+		t.Fatalf("chat-wide: got symbol=%s chat_wide=%v", sig.Symbol, sig.ChatWide)
+	}
+
+	if sig, err := parser.Parse(context.Background(), Message{ID: 3, Text: "cancel that call"}); err == nil {
+		t.Fatalf("cancel without symbol: expected error, got %+v", sig)
+	}
+}
+
+func TestParserTagFallback(t *testing.T) {
+	cfg := baseConfig()
+	cfg.QuoteAsset = "USDT"
+	cfg.TagFallback = true
+	parser := NewParser(cfg)
+	parser.SetSymbols(NewSymbolSet([]string{"TWIFUSDT", "PEPEUSDT", "DOGEUSDT"}))
+
+	cases := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr error
+	}{
+		{"header and hashtags", "BUYING #TWIF/USDT\nMEGA PUMP SIGNAL: #TWIF\nTargets: 2000%", "TWIFUSDT", nil},
+		{"cashtag", "MEGA PUMP SIGNAL $PEPE Targets: 300%", "PEPEUSDT", nil},
+		{"unlisted ticker", "MEGA PUMP SIGNAL #NOPE #NOPE Targets: 300%", "", errMissingSymbol},
+		{"ambiguous", "MEGA PUMP SIGNAL #PEPE #DOGE Targets: 300%", "", errAmbiguousSymbol},
+		{"single hashtag", "MEGA PUMP SIGNAL #DOGE Targets: 300%", "", errLowConfidence},
+		{"other quote", "BUYING #DOGE/BTC MEGA PUMP SIGNAL Targets: 300%", "", errLowConfidence},
+	}
+	for _, tc := range cases {
+		sig, err := parser.Parse(context.Background(), Message{Text: tc.text})
+		if tc.wantErr != nil {
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("%s: expected %v, got %v", tc.name, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		if sig.Symbol != tc.want || sig.SymbolFrom != symbolFromTags || sig.Confidence < cfg.TagConfidence() {
+			t.Fatalf("%s: unexpected signal %+v", tc.name, sig)
+		}
+	}
+
+	// An explicit zero floor is honoured rather than replaced by the default.
+	zero := 0.0
+	cfg.MinTagConfidence = &zero
+	parser = NewParser(cfg)
+	parser.SetSymbols(NewSymbolSet([]string{"TWIFUSDT", "PEPEUSDT", "DOGEUSDT"}))
+	sig, err := parser.Parse(context.Background(), Message{Text: "MEGA PUMP SIGNAL #DOGE Targets: 300%"})
+	if err != nil || sig.Symbol != "DOGEUSDT" {
+		t.Fatalf("expected DOGEUSDT with min_tag_confidence = 0, got %+v, %v", sig, err)
+	}
+}
+
+func TestParserHiddenLinks(t *testing.T) {
+	parser := NewParser(baseConfig())
+	msg := Message{
+		Text: "MEGA PUMP SIGNAL: Click here to trade! Targets: 500%",
+		Links: []Link{
+			{URL: "https://t.me/somechannel", Kind: "text_url", Label: "channel"},
+			{URL: "https://www.mexc.com/exchange/TWIF_USDT", Kind: "button", Label: "Trade on MEXC"},
+		},
+	}
+
+	sig, err := parser.Parse(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sig.Symbol != "TWIFUSDT" || sig.SymbolFrom != symbolFromLink {
+		t.Fatalf("expected TWIFUSDT from button link, got %+v", sig)
+	}
+}
+
+// TestParserObfuscatedCorpus runs testdata/obfuscated.jsonl, one message per
+// line; an empty symbol means the message must be rejected.
+func TestParserObfuscatedCorpus(t *testing.T) {
+	data, err := os.ReadFile("testdata/obfuscated.jsonl")
+	if err != nil {
+		t.Fatalf("read corpus: %v", err)
+	}
+	parser := NewParser(baseConfig())
+	for i, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var tc struct {
+			Name   string `json:"name"`
+			Text   string `json:"text"`
+			Symbol string `json:"symbol"`
+		}
+		if err := json.Unmarshal([]byte(line), &tc); err != nil {
+			t.Fatalf("corpus line %d: %v", i+1, err)
+		}
+		sig, err := parser.Parse(context.Background(), Message{Text: tc.Text})
+		if tc.Symbol == "" {
+			if err == nil {
+				t.Fatalf("%s: expected rejection, got %s", tc.Name, sig.Symbol)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.Name, err)
+		}
+		if sig.Symbol != tc.Symbol {
+			t.Fatalf("%s: expected %s, got %s", tc.Name, tc.Symbol, sig.Symbol)
+		}
+	}
+}
+
+func TestParserLinkForms(t *testing.T) {
+	cfg := baseConfig()
+	cfg.QuoteAsset = "USDT"
+	cfg.Links = []config.LinkRuleConfig{
+		{Name: "aggregator", Hosts: []string{"coins.example"}, PathPattern: `^/t/(?P<pair>[A-Za-z]+)$`, Market: "spot"},
+	}
+	parser := NewParser(cfg)
+
+	cases := []struct {
+		link   string
+		symbol string
+		market exchange.Market
+	}{
+		{"https://www.mexc.com/exchange/TWIF_USDT", "TWIFUSDT", exchange.MarketSpot},
+		{"https://www.mexc.com/en-US/exchange/twif-usdt", "TWIFUSDT", exchange.MarketSpot},
+		{"https://futures.mexc.com/exchange/TWIF_USDT?type=linear_swap", "TWIFUSDT", exchange.MarketFutures},
+		{"https://www.mexc.com/ru-RU/futures/TWIF_USDT", "TWIFUSDT", exchange.MarketFutures},
+		{"https://www.mexc.com/trade?symbol=TWIF_USDT", "TWIFUSDT", exchange.MarketSpot},
+		{"https://coins.example/t/TWIFUSDT", "TWIFUSDT", exchange.MarketSpot},
+	}
+	for _, tc := range cases {
+		sig, err := parser.Parse(context.Background(), Message{Text: "MEGA PUMP SIGNAL Targets: 500% " + tc.link})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.link, err)
+		}
+		if sig.Symbol != tc.symbol || sig.Market != tc.market {
+			t.Fatalf("%s: expected %s on %s, got %s on %s", tc.link, tc.symbol, tc.market, sig.Symbol, sig.Market)
+		}
+	}
+
+	if _, err := parser.Parse(context.Background(), Message{Text: "MEGA PUMP SIGNAL Targets: 500% https://www.mexc.com/markets"}); err == nil {
+		t.Fatalf("expected error for a MEXC page without a pair")
+	}
+}
+
+func TestParserExpandsShortLinksOnce(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/s/") {
+			hits.Add(1)
+			http.Redirect(w, r, "/exchange/TWIF_USDT", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	cfg := baseConfig()
+	cfg.Links = []config.LinkRuleConfig{
+		{Name: "shortener", Hosts: []string{host}, Short: true},
+		{Name: "target", Hosts: []string{host}, PathPattern: `^/exchange/(?P<pair>[A-Za-z]+_[A-Za-z]+)$`, Market: "spot"},
+	}
+	parser := NewParser(cfg)
+	parser.SetLinkExpander(NewRedirectExpander(2 * time.Second))
+
+	msg := Message{Text: "MEGA PUMP SIGNAL Targets: 500% " + srv.URL + "/s/abc"}
+	for i := 0; i < 2; i++ {
+		sig, err := parser.Parse(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		if sig.Symbol != "TWIFUSDT" {
+			t.Fatalf("expected TWIFUSDT, got %s", sig.Symbol)
+		}
+		if ex := parser.Explain(context.Background(), msg); ex.Err != nil {
+			t.Fatalf("explain: %v", ex.Err)
+		}
+	}
+	if got := hits.Load(); got != 1 {
+		t.Fatalf("expected the short link fetched once, got %d", got)
+	}
+
+	// A cancelled caller fails without leaving a cached failure behind.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	other := Message{Text: "MEGA PUMP SIGNAL Targets: 500% " + srv.URL + "/s/other"}
+	if _, err := parser.Parse(ctx, other); err == nil {
+		t.Fatalf("expected cancelled expansion to fail")
+	}
+	if _, err := parser.Parse(context.Background(), other); err != nil {
+		t.Fatalf("expected expansion after cancellation to succeed: %v", err)
+	}
+}
+
+func TestParserExplain(t *testing.T) {
+	parser := NewParser(baseConfig())
+	ex := parser.Explain(context.Background(), Message{Text: "MEGA PUMP SIGNAL https://www.other.com/exchange/TWIF_USDT"})
+	if ex.Err == nil || ex.Signal != nil {
+		t.Fatalf("expected rejection, got %+v", ex.Signal)
+	}
+	if len(ex.Templates) != 1 {
+		t.Fatalf("expected one template trace, got %+v", ex.Templates)
+	}
+	tokens := ex.Templates[0].Tokens
+	if len(tokens) != 2 || !tokens[0].Found || tokens[1].Found {
+		t.Fatalf("expected MEGA PUMP SIGNAL found and Targets absent, got %+v", tokens)
+	}
+	if len(ex.Links) != 1 || !errors.Is(ex.Links[0].Err, errMissingLink) {
+		t.Fatalf("expected the foreign link to be reported unmatched, got %+v", ex.Links)
+	}
+}
+
+func TestFoldCollapsesWhitespace(t *testing.T) {
+	cases := map[string]string{
+		"MEGA🚀PUMP":             "mega pump",
+		"  mega \t\n pump  ":    "mega pump",
+		"ᴍᴇɢᴀ ᴘᴜᴍᴘ":             "mega pump",
+		"Ɡo ɡo":                 "go go",
+		"mega\u200bpump":        "megapump",
+		"no pump here 🚀🚀 today": "no pump here today",
+	}
+	for in, want := range cases {
+		if got := Fold(in); got != want {
+			t.Fatalf("Fold(%q) = %q, want %q", in, got, want)
+		}
+	}
+	// Words stay apart: a token must not match across a word boundary.
+	if strings.Contains(Fold("MEGA PUMP"), Fold("MEGAPUMP")) {
+		t.Fatalf("expected spaced words not to match a joined token")
+	}
+}
+
+func TestParserRejectsEmojiOnlyTokens(t *testing.T) {
+	msg := Message{Text: "MEGA PUMP SIGNAL 🚀 Targets: 500% https://www.mexc.com/exchange/TWIF_USDT"}
+	for name, tc := range map[string]config.TemplateConfig{
+		// Would match every message once folded to "".
+		"required": {Name: "rocket", RequiredTokens: []string{"MEGA PUMP", "🚀"}},
+		// Would reject every message.
+		"forbidden": {Name: "no-entry", RequiredTokens: []string{"MEGA PUMP"}, ForbiddenTokens: []string{"🚫"}},
+	} {
+		cfg := baseConfig()
+		cfg.Templates = []config.TemplateConfig{tc}
+		_, err := NewParser(cfg).Parse(context.Background(), msg)
+		if err == nil || !strings.Contains(err.Error(), "empty once folded") {
+			t.Fatalf("%s: expected an empty-token error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package signal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// symbolFromTags scores hashtag, cashtag and header candidates against the
+// exchange symbol list; it is the fallback when a post carries no usable link.
+const symbolFromTags = "tags"
+
+// cashtagPattern matches "$TWIF" style tickers.
+var cashtagPattern = regexp.MustCompile(`\$([A-Za-z][A-Za-z0-9]{1,14})\b`)
+
+var (
+	errAmbiguousSymbol = errors.New("ambiguous ticker candidates")
+	errLowConfidence   = errors.New("ticker confidence below threshold")
+)
+
+// Score contributions for tag candidates. A candidate needs several independent
+// hints (a BUYING header, repeated mentions, an exchange listing) to clear the
+// default 0.6 threshold; a single unlisted hashtag never does.
+const (
+	scoreHeader     = 0.6
+	scoreMention    = 0.2
+	maxMentionScore = 0.4
+	scoreCashtag    = 0.1
+	scoreListed     = 0.3
+	ambiguityMargin = 0.2
+)
+
+// SymbolDirectory reports whether a symbol such as TWIFUSDT trades on the exchange.
+type SymbolDirectory interface {
+	Listed(symbol string) bool
+}
+
+// SymbolSet is a concurrency-safe SymbolDirectory that can be refreshed in place.
+type SymbolSet struct {
+	mu      sync.RWMutex
+	symbols map[string]struct{}
+}
+
+// NewSymbolSet returns a set holding symbols.
+func NewSymbolSet(symbols []string) *SymbolSet {
+	s := &SymbolSet{}
+	s.Replace(symbols)
+	return s
+}
+
+// Replace swaps the set contents for symbols.
+func (s *SymbolSet) Replace(symbols []string) {
+	next := make(map[string]struct{}, len(symbols))
+	for _, sym := range symbols {
+		next[strings.ToUpper(sym)] = struct{}{}
+	}
+	s.mu.Lock()
+	s.symbols = next
+	s.mu.Unlock()
+}
+
+// Listed implements SymbolDirectory.
+func (s *SymbolSet) Listed(symbol string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.symbols[strings.ToUpper(symbol)]
+	return ok
+}
+
+// Len returns the number of symbols in the set.
+func (s *SymbolSet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.symbols)
+}
+
+type tagCandidate struct {
+	base     string
+	header   bool
+	mentions int
+	cashtag  bool
+	score    float64
+}
+
+// pairFromTags picks the best-scoring base asset among header, hashtag and
+// cashtag mentions and returns its pair code with the confidence reached.
+func (p *Parser) pairFromTags(text string) (string, float64, error) {
+	quote := strings.ToUpper(p.cfg.QuoteAsset)
+	candidates := make(map[string]*tagCandidate)
+	get := func(base string) *tagCandidate {
+		base = strings.ToUpper(base)
+		c, ok := candidates[base]
+		if !ok {
+			c = &tagCandidate{base: base}
+			candidates[base] = c
+		}
+		return c
+	}
+
+	for _, m := range headerPattern.FindAllStringSubmatch(text, -1) {
+		if strings.ToUpper(m[2]) != quote {
+			// The header names another quote asset; trading it against ours would be a different market.
+			continue
+		}
+		get(m[1]).header = true
+	}
+	for _, m := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		if strings.ToUpper(m[1]) != quote {
+			get(m[1]).mentions++
+		}
+	}
+	for _, m := range cashtagPattern.FindAllStringSubmatch(text, -1) {
+		if strings.ToUpper(m[1]) != quote {
+			c := get(m[1])
+			c.mentions++
+			c.cashtag = true
+		}
+	}
+
+	ranked := make([]*tagCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if p.symbols != nil {
+			if !p.symbols.Listed(c.base + quote) {
+				continue
+			}
+			c.score += scoreListed
+		}
+		if c.header {
+			c.score += scoreHeader
+		}
+		c.score += min(float64(c.mentions)*scoreMention, maxMentionScore)
+		if c.cashtag {
+			c.score += scoreCashtag
+		}
+		c.score = min(c.score, 1)
+		ranked = append(ranked, c)
+	}
+	if len(ranked) == 0 {
+		return "", 0, fmt.Errorf("%w: no listed hashtag or cashtag", errMissingSymbol)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].base < ranked[j].base
+	})
+
+	best := ranked[0]
+	if len(ranked) > 1 && best.score-ranked[1].score < ambiguityMargin {
+		return "", best.score, fmt.Errorf("%w: %s (%.2f) vs %s (%.2f)", errAmbiguousSymbol, best.base, best.score, ranked[1].base, ranked[1].score)
+	}
+	minConfidence := p.cfg.TagConfidence()
+	if best.score < minConfidence {
+		return "", best.score, fmt.Errorf("%w: %s scored %.2f < %.2f", errLowConfidence, best.base, best.score, minConfidence)
+	}
+	return best.base + p.cfg.PairSeparator + quote, best.score, nil
+}
@@ -0,0 +1,190 @@
+package signal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/user/mexc-bot/internal/config"
+)
+
+const (
+	symbolFromLink    = "link"
+	symbolFromCapture = "capture"
+	symbolFromHeader  = "header"
+	symbolFromHashtag = "hashtag"
+)
+
+var (
+	// headerPattern matches "BUYING #TWIF/USDT" style headers.
+	headerPattern = regexp.MustCompile(`(?i)\bBUY(?:ING)?\s*:?\s+[#$]?([A-Z0-9]{2,15})\s*[/_-]\s*([A-Z0-9]{2,10})\b`)
+	// hashtagPattern matches "#TWIF" style tags.
+	hashtagPattern = regexp.MustCompile(`#([A-Za-z0-9]{2,15})\b`)
+)
+
+// token is a template token with its folded form precomputed.
+type token struct {
+	raw  string
+	fold string
+}
+
+// compileTokens folds each token. A token that folds to nothing (emoji or
+// symbols only) would match every message, so it is an error.
+func compileTokens(raw []string) ([]token, error) {
+	out := make([]token, 0, len(raw))
+	for _, r := range raw {
+		fold := Fold(r)
+		if fold == "" {
+			return nil, fmt.Errorf("token %q is empty once folded", r)
+		}
+		out = append(out, token{raw: r, fold: fold})
+	}
+	return out, nil
+}
+
+// template is a compiled [[parser.templates]] entry.
+type template struct {
+	name        string
+	required    []token
+	optional    []token
+	minOptional int
+	forbidden   []token
+	pattern     *regexp.Regexp
+	patternErr  error
+	tokenErr    error
+	symbolFrom  []string
+	kind        Kind
+	fraction    float64
+	chatWide    bool
+}
+
+func compileTemplate(tc config.TemplateConfig) *template {
+	tpl := &template{
+		name:        tc.Name,
+		minOptional: tc.MinOptional,
+		symbolFrom:  tc.SymbolFrom,
+		kind:        Kind(tc.Kind),
+		fraction:    tc.CloseFraction,
+		chatWide:    tc.ChatWide,
+	}
+	// Config validation rejects empty tokens; keep the error so direct
+	// callers see it per template, as with the pattern.
+	for _, set := range []struct {
+		dst *[]token
+		raw []string
+	}{{&tpl.required, tc.RequiredTokens}, {&tpl.optional, tc.OptionalTokens}, {&tpl.forbidden, tc.ForbiddenTokens}} {
+		tokens, err := compileTokens(set.raw)
+		if err != nil && tpl.tokenErr == nil {
+			tpl.tokenErr = err
+		}
+		*set.dst = tokens
+	}
+	if tpl.kind == "" {
+		tpl.kind = KindOpen
+	}
+	if len(tpl.symbolFrom) == 0 {
+		tpl.symbolFrom = []string{symbolFromLink}
+	}
+	if tc.Pattern != "" {
+		// Config validation rejects bad patterns; keep the error so direct callers see it per template.
+		tpl.pattern, tpl.patternErr = regexp.Compile(tc.Pattern)
+	}
+	return tpl
+}
+
+// match applies the token rules to folded and the pattern to text, and returns
+// named captures.
+func (t *template) match(text, folded string) (map[string]string, error) {
+	if t.patternErr != nil {
+		return nil, fmt.Errorf("invalid template pattern: %w", t.patternErr)
+	}
+	if t.tokenErr != nil {
+		return nil, fmt.Errorf("invalid template token: %w", t.tokenErr)
+	}
+	for _, tok := range t.forbidden {
+		if strings.Contains(folded, tok.fold) {
+			return nil, fmt.Errorf("%w: %s", errForbiddenToken, tok.raw)
+		}
+	}
+	for _, tok := range t.required {
+		if !strings.Contains(folded, tok.fold) {
+			return nil, fmt.Errorf("%w: %s", errMissingToken, tok.raw)
+		}
+	}
+	if t.minOptional > 0 {
+		found := 0
+		for _, tok := range t.optional {
+			if strings.Contains(folded, tok.fold) {
+				found++
+			}
+		}
+		if found < t.minOptional {
+			return nil, fmt.Errorf("%w: %d of %d", errOptionalTokens, found, t.minOptional)
+		}
+	}
+
+	captures := make(map[string]string)
+	if t.pattern == nil {
+		return captures, nil
+	}
+	m := t.pattern.FindStringSubmatch(text)
+	if m == nil {
+		return nil, errPatternMismatch
+	}
+	for i, name := range t.pattern.SubexpNames() {
+		if name != "" && m[i] != "" {
+			captures[name] = m[i]
+		}
+	}
+	return captures, nil
+}
+
+// pairFromCaptures builds a pair code from the symbol, pair, or base/quote groups.
+func (p *Parser) pairFromCaptures(captures map[string]string) (string, error) {
+	sep := p.cfg.PairSeparator
+	if base := captures["base"]; base != "" {
+		quote := captures["quote"]
+		if quote == "" {
+			quote = p.cfg.QuoteAsset
+		}
+		return strings.ToUpper(base + sep + quote), nil
+	}
+	if pair := captures["pair"]; pair != "" {
+		pair = strings.ToUpper(pair)
+		for _, alt := range []string{"/", "-", "_"} {
+			pair = strings.ReplaceAll(pair, alt, sep)
+		}
+		return pair, nil
+	}
+	if symbol := captures["symbol"]; symbol != "" {
+		symbol = strings.ToUpper(symbol)
+		quote := strings.ToUpper(p.cfg.QuoteAsset)
+		if !strings.HasSuffix(symbol, quote) {
+			symbol += quote
+		}
+		return strings.TrimSuffix(symbol, quote) + sep + quote, nil
+	}
+	return "", fmt.Errorf("%w: pattern captured no symbol, pair or base group", errMissingSymbol)
+}
+
+// pairFromHeader reads a "BUYING #BASE/QUOTE" header.
+func (p *Parser) pairFromHeader(text string) (string, error) {
+	m := headerPattern.FindStringSubmatch(text)
+	if m == nil {
+		return "", fmt.Errorf("%w: no BUYING #BASE/QUOTE header", errMissingSymbol)
+	}
+	return strings.ToUpper(m[1] + p.cfg.PairSeparator + m[2]), nil
+}
+
+// pairFromHashtag takes the first hashtag that is not the quote asset itself.
+func (p *Parser) pairFromHashtag(text string) (string, error) {
+	quote := strings.ToUpper(p.cfg.QuoteAsset)
+	for _, m := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag := strings.ToUpper(m[1])
+		if tag == quote {
+			continue
+		}
+		return tag + p.cfg.PairSeparator + quote, nil
+	}
+	return "", fmt.Errorf("%w: no hashtag", errMissingSymbol)
+}
@@ -0,0 +1,226 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+// recordSeparator ends a message in text format; a multi-line signal can
+// contain blank lines, so they do not separate records.
+const recordSeparator = "---"
+
+// File reads messages from stdin or a file, either once or tailing it.
+type File struct {
+	logger *slog.Logger
+	path   string
+	follow bool
+	jsonl  bool
+	chatID int64
+	stdin  io.Reader
+	poll   time.Duration
+	nextID int64
+}
+
+// NewFile prepares a file source; path "-" reads stdin.
+func NewFile(cfg config.FileSourceConfig, logger *slog.Logger) (*File, error) {
+	path := strings.TrimSpace(cfg.Path)
+	if path == "" {
+		return nil, errors.New("file source path must be provided")
+	}
+	if cfg.Follow && path == "-" {
+		return nil, errors.New("file source cannot follow stdin")
+	}
+	return &File{
+		logger: logger,
+		path:   path,
+		follow: cfg.Follow,
+		jsonl:  cfg.Format == "jsonl",
+		chatID: cfg.ChatID,
+		stdin:  os.Stdin,
+		poll:   250 * time.Millisecond,
+	}, nil
+}
+
+// Name implements Source.
+func (f *File) Name() string {
+	if f.path == "-" {
+		return "stdin"
+	}
+	return "file"
+}
+
+// Run emits every record until the input ends or, when following, until ctx
+// is cancelled. Following starts at the current end of the file.
+func (f *File) Run(ctx context.Context, out chan<- signalpkg.Message) error {
+	r, file := f.stdin, (*os.File)(nil)
+	if f.path != "-" {
+		var err error
+		if file, err = os.Open(f.path); err != nil {
+			return fmt.Errorf("open %s: %w", f.path, err)
+		}
+		defer file.Close()
+		if f.follow {
+			if _, err := file.Seek(0, io.SeekEnd); err != nil {
+				return fmt.Errorf("seek %s: %w", f.path, err)
+			}
+		}
+		r = file
+	}
+	f.logger.Info("file source reading", "path", f.path, "follow", f.follow)
+
+	// Reads from stdin cannot be interrupted, so lines are scanned in the
+	// background and Run returns on cancellation without waiting for them.
+	lines := make(chan string)
+	scanErr := make(chan error, 1)
+	go func() { scanErr <- f.scan(ctx, r, file, lines) }()
+
+	var record []string
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case line, ok := <-lines:
+			if !ok {
+				if err := f.emitText(ctx, out, record); err != nil {
+					return err
+				}
+				return <-scanErr
+			}
+			if f.jsonl {
+				if err := f.emitJSON(ctx, out, line); err != nil {
+					return err
+				}
+				continue
+			}
+			if strings.TrimSpace(line) != recordSeparator {
+				record = append(record, line)
+				continue
+			}
+			if err := f.emitText(ctx, out, record); err != nil {
+				return err
+			}
+			record = record[:0]
+		}
+	}
+}
+
+// scan sends each line of r to lines and closes it at the end of input. When
+// following, it waits for more data instead and restarts from the top if the
+// file is truncated in place.
+func (f *File) scan(ctx context.Context, r io.Reader, file *os.File, lines chan<- string) error {
+	defer close(lines)
+	br := bufio.NewReader(r)
+	var (
+		partial strings.Builder
+		offset  int64
+	)
+	send := func() error {
+		line := strings.TrimRight(partial.String(), "\r\n")
+		partial.Reset()
+		select {
+		case lines <- line:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	for {
+		chunk, err := br.ReadString('\n')
+		offset += int64(len(chunk))
+		partial.WriteString(chunk)
+		if err == nil {
+			if err := send(); err != nil {
+				return err
+			}
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return fmt.Errorf("read %s: %w", f.path, err)
+		}
+		if !f.follow {
+			if partial.Len() > 0 {
+				return send()
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(f.poll):
+		}
+		if info, err := file.Stat(); err == nil && info.Size() < offset {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return fmt.Errorf("seek %s: %w", f.path, err)
+			}
+			br.Reset(file)
+			partial.Reset()
+			offset = 0
+		}
+	}
+}
+
+func (f *File) emitText(ctx context.Context, out chan<- signalpkg.Message, record []string) error {
+	text := strings.TrimSpace(strings.Join(record, "\n"))
+	if text == "" {
+		return nil
+	}
+	f.nextID++
+	now := time.Now()
+	return f.send(ctx, out, signalpkg.Message{
+		ID:         f.nextID,
+		ChatID:     f.chatID,
+		Text:       text,
+		Timestamp:  now,
+		ReceivedAt: now,
+	})
+}
+
+func (f *File) emitJSON(ctx context.Context, out chan<- signalpkg.Message, line string) error {
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+	var p Payload
+	err := json.Unmarshal([]byte(line), &p)
+	var msg signalpkg.Message
+	if err == nil {
+		msg, err = p.message("", time.Now())
+	}
+	if err != nil {
+		// A typo in a hand-written test file should not stop the bot.
+		f.logger.Warn("file source skipped invalid line", "path", f.path, "error", err)
+		return nil
+	}
+	if msg.ChatID == 0 {
+		msg.ChatID = f.chatID
+	}
+	return f.send(ctx, out, msg)
+}
+
+func (f *File) send(ctx context.Context, out chan<- signalpkg.Message, msg signalpkg.Message) error {
+	msg.Account = f.Name()
+	msg.ChatType = "file"
+	if msg.ChatTitle == "" {
+		msg.ChatTitle = f.path
+		if f.path == "-" {
+			msg.ChatTitle = "stdin"
+		}
+	}
+	select {
+	case out <- msg:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
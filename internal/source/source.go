@@ -0,0 +1,53 @@
+// Package source defines the ingestion sources that feed the engine and the
+// non-Telegram implementations: a signed HTTP webhook and a stdin/file reader.
+package source
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+// Source produces messages for the engine. Run delivers messages to out until
+// ctx is cancelled or the source fails; it must not close out.
+type Source interface {
+	Name() string
+	Run(ctx context.Context, out chan<- signalpkg.Message) error
+}
+
+// RunAll runs every source into out and closes out once all have returned.
+// A source that fails cancels the others and its error is returned; one that
+// finishes cleanly, such as a fully read file, leaves the rest running.
+func RunAll(ctx context.Context, out chan<- signalpkg.Message, logger *slog.Logger, sources ...Source) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg    sync.WaitGroup
+		once  sync.Once
+		first error
+	)
+	for _, src := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := src.Run(ctx, out)
+			switch {
+			case ctx.Err() != nil:
+			case err == nil:
+				logger.Info("ingestion source finished", "source", src.Name())
+			default:
+				once.Do(func() {
+					first = fmt.Errorf("%s source: %w", src.Name(), err)
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	close(out)
+	return first
+}
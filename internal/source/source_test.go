@@ -0,0 +1,143 @@
+package source
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func signedRequest(secret, body string, at time.Time) *http.Request {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, "/signals", strings.NewReader(body))
+	req.Header.Set(TimestampHeader, ts)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(Sign([]byte(secret), ts, []byte(body))))
+	return req
+}
+
+func TestWebhookVerifiesSignatures(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	w, err := NewWebhook(config.WebhookSourceConfig{MaxSkewSeconds: 60}, []byte("s3cret"), discard)
+	if err != nil {
+		t.Fatalf("new webhook: %v", err)
+	}
+	w.now = func() time.Time { return now }
+	out := make(chan signalpkg.Message, 4)
+	h := w.handler(out)
+
+	body := `{"id":7,"chat_id":-42,"chat":"discord #calls","text":"BUY $ABC","links":[{"url":"https://www.mexc.com/exchange/ABC_USDT","label":"Trade"}]}`
+	cases := []struct {
+		name   string
+		req    *http.Request
+		status int
+	}{
+		{"valid", signedRequest("s3cret", body, now), http.StatusAccepted},
+		{"wrong secret", signedRequest("other", body, now), http.StatusUnauthorized},
+		{"stale timestamp", signedRequest("s3cret", body, now.Add(-2*time.Minute)), http.StatusUnauthorized},
+		{"empty text", signedRequest("s3cret", `{"id":8}`, now), http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, tc.req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.status, rec.Code)
+		}
+	}
+
+	tampered := signedRequest("s3cret", body, now)
+	tampered.Body = io.NopCloser(strings.NewReader(strings.Replace(body, "ABC", "XYZ", 1)))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, tampered)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("tampered body: expected 401, got %d", rec.Code)
+	}
+
+	if len(out) != 1 {
+		t.Fatalf("expected exactly one accepted message, got %d", len(out))
+	}
+	msg := <-out
+	if msg.ID != 7 || msg.ChatID != -42 || msg.ChatType != "webhook" || msg.Account != "webhook" || msg.Text != "BUY $ABC" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if !msg.Timestamp.Equal(now) {
+		t.Fatalf("expected receipt time as timestamp, got %v", msg.Timestamp)
+	}
+	if len(msg.Links) != 1 || msg.Links[0].Label != "Trade" {
+		t.Fatalf("unexpected links %+v", msg.Links)
+	}
+}
+
+func TestFileSplitsTextRecords(t *testing.T) {
+	f, err := NewFile(config.FileSourceConfig{Path: "-", ChatID: 99}, discard)
+	if err != nil {
+		t.Fatalf("new file source: %v", err)
+	}
+	f.stdin = strings.NewReader("NEW LISTING\n\nCOIN: $ABC\n---\n\n---\nCOIN: $XYZ")
+
+	out := make(chan signalpkg.Message, 4)
+	if err := f.Run(context.Background(), out); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	close(out)
+	var got []signalpkg.Message
+	for msg := range out {
+		got = append(got, msg)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records, got %d: %+v", len(got), got)
+	}
+	if got[0].Text != "NEW LISTING\n\nCOIN: $ABC" || got[1].Text != "COIN: $XYZ" {
+		t.Fatalf("unexpected texts %q, %q", got[0].Text, got[1].Text)
+	}
+	if got[0].ID == got[1].ID || got[0].ChatID != 99 || got[0].Account != "stdin" {
+		t.Fatalf("unexpected metadata %+v", got[0])
+	}
+}
+
+type stubSource struct {
+	name string
+	err  error
+}
+
+func (s stubSource) Name() string { return s.name }
+
+func (s stubSource) Run(ctx context.Context, out chan<- signalpkg.Message) error {
+	if s.err != nil {
+		return s.err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRunAllStopsOnFailure(t *testing.T) {
+	out := make(chan signalpkg.Message)
+	boom := errors.New("boom")
+	done := make(chan error, 1)
+	go func() {
+		done <- RunAll(context.Background(), out, discard, stubSource{name: "healthy"}, stubSource{name: "broken", err: boom})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, boom) || !strings.Contains(err.Error(), "broken") {
+			t.Fatalf("expected broken source error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("RunAll did not stop after a source failed")
+	}
+	if _, ok := <-out; ok {
+		t.Fatalf("expected out to be closed")
+	}
+}
@@ -0,0 +1,229 @@
+package source
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/metrics"
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex>" of HMAC-SHA256(secret, timestamp + "." + body).
+	SignatureHeader = "X-Signature"
+	// TimestampHeader carries the Unix time in seconds the sender signed at.
+	TimestampHeader = "X-Signature-Timestamp"
+
+	defaultWebhookListen = "127.0.0.1:8089"
+	defaultWebhookPath   = "/signals"
+	defaultMaxSkew       = 5 * time.Minute
+	maxWebhookBody       = 1 << 20
+)
+
+var webhookRejected = metrics.Default.Counter("mexc_bot_webhook_rejected_total",
+	"Webhook requests rejected before reaching the engine, by reason.", "reason")
+
+// Payload is the JSON body accepted by the webhook and by jsonl files.
+type Payload struct {
+	ID        int64         `json:"id"`      // unique per chat_id; derived from the text when zero
+	ChatID    int64         `json:"chat_id"` // matches [channels."webhook:<id>"] profiles
+	Chat      string        `json:"chat"`    // human-readable source label
+	Sender    string        `json:"sender"`
+	Text      string        `json:"text"`
+	Timestamp time.Time     `json:"timestamp"` // RFC 3339 post time; receipt time when omitted
+	Edited    bool          `json:"edited"`
+	Links     []PayloadLink `json:"links"`
+}
+
+// PayloadLink is a URL not visible in the text, such as a Discord button.
+type PayloadLink struct {
+	URL   string `json:"url"`
+	Label string `json:"label"`
+}
+
+// message converts p into a signal message of the given chat type.
+func (p Payload) message(chatType string, receivedAt time.Time) (signalpkg.Message, error) {
+	if strings.TrimSpace(p.Text) == "" {
+		return signalpkg.Message{}, errors.New("text is empty")
+	}
+	msg := signalpkg.Message{
+		ID:         p.ID,
+		ChatID:     p.ChatID,
+		ChatType:   chatType,
+		ChatTitle:  p.Chat,
+		SenderName: p.Sender,
+		Text:       p.Text,
+		Timestamp:  p.Timestamp,
+		ReceivedAt: receivedAt,
+		Edited:     p.Edited,
+	}
+	if msg.ID == 0 {
+		msg.ID = textID(p.Text)
+	}
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = receivedAt
+	}
+	for _, l := range p.Links {
+		if l.URL != "" {
+			msg.Links = append(msg.Links, signalpkg.Link{URL: l.URL, Kind: "button", Label: l.Label})
+		}
+	}
+	return msg, nil
+}
+
+// textID derives a stable message ID from the text, so a sender without IDs
+// still gets repeated deliveries deduplicated.
+func textID(text string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(text))
+	return int64(h.Sum64() >> 1)
+}
+
+// Webhook accepts signed JSON messages over HTTP.
+type Webhook struct {
+	logger  *slog.Logger
+	listen  string
+	path    string
+	secret  []byte
+	maxSkew time.Duration
+	now     func() time.Time
+}
+
+// NewWebhook prepares a webhook source verifying requests with secret.
+func NewWebhook(cfg config.WebhookSourceConfig, secret []byte, logger *slog.Logger) (*Webhook, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("webhook secret must be provided")
+	}
+	w := &Webhook{
+		logger:  logger,
+		listen:  cfg.Listen,
+		path:    cfg.Path,
+		secret:  secret,
+		maxSkew: defaultMaxSkew,
+		now:     time.Now,
+	}
+	if w.listen == "" {
+		w.listen = defaultWebhookListen
+	}
+	if w.path == "" {
+		w.path = defaultWebhookPath
+	}
+	if cfg.MaxSkewSeconds > 0 {
+		w.maxSkew = time.Duration(cfg.MaxSkewSeconds) * time.Second
+	}
+	return w, nil
+}
+
+// Name implements Source.
+func (w *Webhook) Name() string { return "webhook" }
+
+// Run serves the webhook until ctx is cancelled.
+func (w *Webhook) Run(ctx context.Context, out chan<- signalpkg.Message) error {
+	ln, err := net.Listen("tcp", w.listen)
+	if err != nil {
+		return fmt.Errorf("listen on %s: %w", w.listen, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(w.path, w.handler(out))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(ln) }()
+	w.logger.Info("webhook source listening", "addr", ln.Addr().String(), "path", w.path)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(shutdownCtx)
+	return ctx.Err()
+}
+
+func (w *Webhook) handler(out chan<- signalpkg.Message) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		receivedAt := w.now()
+		if r.Method != http.MethodPost {
+			rw.Header().Set("Allow", http.MethodPost)
+			http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxWebhookBody))
+		if err != nil {
+			w.reject(rw, "body", http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		if err := w.verify(r.Header, body, receivedAt); err != nil {
+			w.reject(rw, "signature", http.StatusUnauthorized, err)
+			return
+		}
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			w.reject(rw, "payload", http.StatusBadRequest, err)
+			return
+		}
+		msg, err := p.message("webhook", receivedAt)
+		if err != nil {
+			w.reject(rw, "payload", http.StatusBadRequest, err)
+			return
+		}
+		msg.Account = w.Name()
+
+		select {
+		case out <- msg:
+			rw.WriteHeader(http.StatusAccepted)
+		case <-r.Context().Done():
+			http.Error(rw, "shutting down", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+// verify checks the HMAC signature and that the signed timestamp is recent,
+// which bounds how long a captured request can be replayed.
+func (w *Webhook) verify(h http.Header, body []byte, now time.Time) error {
+	ts := h.Get(TimestampHeader)
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", TimestampHeader, ts)
+	}
+	if skew := now.Sub(time.Unix(unix, 0)).Abs(); skew > w.maxSkew {
+		return fmt.Errorf("signature timestamp off by %s", skew.Round(time.Second))
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(h.Get(SignatureHeader), "sha256="))
+	if err != nil || !hmac.Equal(got, Sign(w.secret, ts, body)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (w *Webhook) reject(rw http.ResponseWriter, reason string, status int, err error) {
+	webhookRejected.Inc(reason)
+	w.logger.Warn("webhook request rejected", "reason", reason, "error", err)
+	http.Error(rw, http.StatusText(status), status)
+}
+
+// Sign returns HMAC-SHA256(secret, timestamp + "." + body), the value senders
+// put hex-encoded in SignatureHeader.
+func Sign(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,157 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/user/mexc-bot/internal/position"
+	"github.com/user/mexc-bot/internal/risk"
+)
+
+var (
+	bucketRisk      = []byte("risk")
+	bucketPositions = []byte("positions")
+
+	keyRiskState = []byte("state")
+)
+
+// Store is an embedded bbolt database holding risk counters and positions for
+// single-host deployments. It implements risk.StateStore and position.Store.
+type Store struct {
+	db *bolt.DB
+}
+
+var (
+	_ risk.StateStore = (*Store)(nil)
+	_ position.Store  = (*Store)(nil)
+)
+
+// Open creates or opens the database at path. Only one process may hold it at a time.
+func Open(path string) (*Store, error) {
+	path = filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("prepare state directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open state db %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketRisk, bucketPositions} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialise state buckets: %w", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close flushes and releases the database file lock.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) LoadRiskState() (risk.State, bool, error) {
+	var (
+		st    risk.State
+		found bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bucketRisk).Get(keyRiskState)
+		if raw == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(raw, &st)
+	})
+	if err != nil {
+		return risk.State{}, false, fmt.Errorf("read risk state: %w", err)
+	}
+	return st, found, nil
+}
+
+func (s *Store) SaveRiskState(st risk.State) error {
+	return s.put(bucketRisk, keyRiskState, st)
+}
+
+func (s *Store) SavePosition(p position.Position) error {
+	if p.ID == "" {
+		return errors.New("position id required")
+	}
+	return s.put(bucketPositions, []byte(p.ID), p)
+}
+
+func (s *Store) LoadPositions() ([]position.Position, error) {
+	var out []position.Position
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPositions).ForEach(func(_, raw []byte) error {
+			var p position.Position
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return err
+			}
+			out = append(out, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read positions: %w", err)
+	}
+	return out, nil
+}
+
+// PruneClosed deletes closed positions that closed before cutoff and reports
+// how many were removed. Open positions are never touched.
+func (s *Store) PruneClosed(cutoff time.Time) (int, error) {
+	var stale [][]byte
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketPositions)
+		err := bucket.ForEach(func(k, raw []byte) error {
+			var p position.Position
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return fmt.Errorf("decode position %s: %w", k, err)
+			}
+			if p.Status == position.StatusClosed && p.ClosedAt.Before(cutoff) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// Deleting during ForEach is undefined in bbolt; delete afterwards.
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("prune closed positions: %w", err)
+	}
+	return len(stale), nil
+}
+
+func (s *Store) put(bucket, key []byte, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encode %s/%s: %w", bucket, key, err)
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(key, raw)
+	})
+}
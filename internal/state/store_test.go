@@ -0,0 +1,143 @@
+package state
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/position"
+	"github.com/user/mexc-bot/internal/risk"
+	"github.com/user/mexc-bot/internal/signal"
+)
+
+func TestRiskStateSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bot.db")
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg := config.RiskConfig{CooldownSeconds: 60, MaxDailyTrades: 1}
+	sig := signal.Signal{Symbol: "TWIFUSDT"}
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	manager, err := risk.NewPersistentManager(logger, cfg, store)
+	if err != nil {
+		t.Fatalf("new manager: %v", err)
+	}
+	manager.RecordExecution(ctx, sig, 100)
+	if err := store.Close(); err != nil {
+		t.Fatalf("close store: %v", err)
+	}
+
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer store.Close()
+	restored, err := risk.NewPersistentManager(logger, cfg, store)
+	if err != nil {
+		t.Fatalf("restore manager: %v", err)
+	}
+
+	decision, err := restored.Evaluate(ctx, sig, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Allow || decision.Reason != "cooldown_active" {
+		t.Fatalf("expected restored cooldown, got %+v", decision)
+	}
+
+	decision, err = restored.Evaluate(ctx, signal.Signal{Symbol: "OTHERUSDT"}, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decision.Allow || decision.Reason != "daily_trade_limit" {
+		t.Fatalf("expected restored daily limit, got %+v", decision)
+	}
+}
+
+func TestPositionsSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bot.db")
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	book, err := position.NewBook(store)
+	if err != nil {
+		t.Fatalf("new book: %v", err)
+	}
+	kept, err := book.Open(position.Position{Symbol: "TWIFUSDT", Notional: 200})
+	if err != nil {
+		t.Fatalf("open position: %v", err)
+	}
+	closed, err := book.Open(position.Position{Symbol: "OTHERUSDT", Notional: 100})
+	if err != nil {
+		t.Fatalf("open position: %v", err)
+	}
+	if _, err := book.Close(closed.ID, "take_profit", 0, time.Now()); err != nil {
+		t.Fatalf("close position: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close store: %v", err)
+	}
+
+	store, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen store: %v", err)
+	}
+	defer store.Close()
+	restored, err := position.NewBook(store)
+	if err != nil {
+		t.Fatalf("restore book: %v", err)
+	}
+
+	open := restored.OpenPositions()
+	if len(open) != 1 || open[0].ID != kept.ID {
+		t.Fatalf("expected only %s open after restart, got %+v", kept.ID, open)
+	}
+}
+
+func TestPruneClosedPositions(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	for _, p := range []position.Position{
+		{ID: "old", Symbol: "AAAUSDT", Status: position.StatusClosed, ClosedAt: now.Add(-40 * 24 * time.Hour)},
+		{ID: "recent", Symbol: "BBBUSDT", Status: position.StatusClosed, ClosedAt: now.Add(-time.Hour)},
+		{ID: "open", Symbol: "CCCUSDT", Status: position.StatusOpen, OpenedAt: now.Add(-60 * 24 * time.Hour)},
+		{ID: "older", Symbol: "DDDUSDT", Status: position.StatusClosed, ClosedAt: now.Add(-90 * 24 * time.Hour)},
+	} {
+		if err := store.SavePosition(p); err != nil {
+			t.Fatalf("save %s: %v", p.ID, err)
+		}
+	}
+
+	pruned, err := store.PruneClosed(now.Add(-30 * 24 * time.Hour))
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if pruned != 2 {
+		t.Fatalf("expected 2 positions pruned, got %d", pruned)
+	}
+	left, err := store.LoadPositions()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, p := range left {
+		ids[p.ID] = true
+	}
+	if len(left) != 2 || !ids["recent"] || !ids["open"] {
+		t.Fatalf("expected recent and open positions kept, got %+v", left)
+	}
+}
@@ -0,0 +1,373 @@
+// Package botapi ingests channel posts through the Telegram Bot API, for
+// channels that let the bot join as an administrator. Unlike the MTProto
+// listener it does not put a user account at risk.
+package botapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/user/mexc-bot/internal/config"
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+	"github.com/user/mexc-bot/internal/source"
+)
+
+const (
+	defaultAPIURL      = "https://api.telegram.org"
+	defaultPollTimeout = 30 * time.Second
+	maxRetryDelay      = 30 * time.Second
+
+	// allowedUpdates limits getUpdates to the update kinds the poller converts.
+	allowedUpdates = `["channel_post","edited_channel_post"]`
+)
+
+var _ source.Source = (*Poller)(nil)
+
+// Poller long-polls getUpdates and emits channel posts as signal messages.
+type Poller struct {
+	logger      *slog.Logger
+	client      *http.Client
+	baseURL     string // <api_url>/bot<token>
+	pollTimeout time.Duration
+	allowed     map[string]struct{}
+	offset      int64
+	offsetPath  string
+	account     string
+	now         func() time.Time
+}
+
+// NewPoller prepares a Bot API source for the given bot token.
+func NewPoller(cfg config.TelegramBotConfig, token string, logger *slog.Logger) (*Poller, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, errors.New("telegram bot token must be provided")
+	}
+	apiURL := strings.TrimRight(cfg.APIURL, "/")
+	if apiURL == "" {
+		apiURL = defaultAPIURL
+	}
+	timeout := defaultPollTimeout
+	if cfg.PollTimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.PollTimeoutSeconds) * time.Second
+	}
+
+	allowed := make(map[string]struct{}, len(cfg.AllowedChats))
+	for _, entry := range cfg.AllowedChats {
+		allowed[normalizeChat(entry)] = struct{}{}
+	}
+
+	return &Poller{
+		logger: logger,
+		// The HTTP timeout must outlast the long poll.
+		client:      &http.Client{Timeout: timeout + 10*time.Second},
+		baseURL:     apiURL + "/bot" + token,
+		pollTimeout: timeout,
+		allowed:     allowed,
+		offsetPath:  strings.TrimSpace(cfg.OffsetPath),
+		account:     "bot",
+		now:         time.Now,
+	}, nil
+}
+
+// Name implements source.Source.
+func (p *Poller) Name() string {
+	return "telegram-bot"
+}
+
+// Run checks the token, then polls until ctx is cancelled. Transient errors
+// are retried with backoff; an invalid token or a competing consumer (another
+// poller or a registered webhook) stops the poller.
+//
+// The Bot API queues updates for 24 hours, so the first polls after a restart
+// return posts made while the bot was down. Those are marked Recovered, as
+// MTProto gap recovery does, and ingest.recovered_policy decides their fate.
+func (p *Poller) Run(ctx context.Context, out chan<- signalpkg.Message) error {
+	if err := p.loadOffset(); err != nil {
+		return err
+	}
+	// Telegram dates have one-second resolution; a post in the startup
+	// second counts as live.
+	startedAt := p.now().Truncate(time.Second)
+
+	var me botUser
+	if err := p.call(ctx, "getMe", nil, &me); err != nil {
+		return fmt.Errorf("telegram bot getMe: %w", err)
+	}
+	if me.Username != "" {
+		p.account = "bot:" + me.Username
+	}
+	p.logger.Info("telegram bot source ready", "bot", me.Username, "id", me.ID)
+
+	delay := time.Second
+	for {
+		updates, err := p.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				switch {
+				case apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusConflict:
+					return fmt.Errorf("telegram bot getUpdates: %w", err)
+				case apiErr.RetryAfter > 0:
+					delay = time.Duration(apiErr.RetryAfter) * time.Second
+				}
+			}
+			p.logger.Warn("telegram bot getUpdates failed", "error", err, "retry_in", delay)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxRetryDelay)
+			continue
+		}
+		delay = time.Second
+
+		receivedAt := p.now()
+		for _, u := range updates {
+			p.offset = u.UpdateID + 1
+			msg, ok := p.convert(u, receivedAt)
+			if !ok {
+				continue
+			}
+			posted := msg.Timestamp
+			if msg.Edited && !msg.EditedAt.IsZero() {
+				posted = msg.EditedAt
+			}
+			msg.Recovered = posted.Before(startedAt)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case out <- msg:
+			}
+		}
+		if len(updates) > 0 {
+			if err := p.saveOffset(); err != nil {
+				p.logger.Warn("telegram bot offset not saved", "path", p.offsetPath, "error", err)
+			}
+		}
+	}
+}
+
+// loadOffset restores the offset saved by a previous run, if any.
+func (p *Poller) loadOffset() error {
+	if p.offsetPath == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p.offsetPath), 0o700); err != nil {
+		return fmt.Errorf("create telegram bot offset directory: %w", err)
+	}
+	data, err := os.ReadFile(p.offsetPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read telegram bot offset: %w", err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse telegram bot offset %s: %w", p.offsetPath, err)
+	}
+	p.offset = offset
+	return nil
+}
+
+// saveOffset replaces the offset file atomically with the next offset to poll.
+func (p *Poller) saveOffset() error {
+	if p.offsetPath == "" {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.offsetPath), filepath.Base(p.offsetPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strconv.FormatInt(p.offset, 10) + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.offsetPath)
+}
+
+func (p *Poller) poll(ctx context.Context) ([]update, error) {
+	params := url.Values{
+		"timeout":         {strconv.Itoa(int(p.pollTimeout / time.Second))},
+		"allowed_updates": {allowedUpdates},
+	}
+	if p.offset != 0 {
+		params.Set("offset", strconv.FormatInt(p.offset, 10))
+	}
+	var updates []update
+	err := p.call(ctx, "getUpdates", params, &updates)
+	return updates, err
+}
+
+// call invokes a Bot API method and decodes its result into dst.
+func (p *Poller) call(ctx context.Context, method string, params url.Values, dst any) error {
+	endpoint := p.baseURL + "/" + method
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		// Keep the token out of logs: url.Error embeds the request URL.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("%s: %w", method, urlErr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return fmt.Errorf("read %s response: %w", method, err)
+	}
+	var envelope response
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("decode %s response (status %d): %w", method, resp.StatusCode, err)
+	}
+	if !envelope.OK {
+		apiErr := &apiError{Code: envelope.ErrorCode, Description: envelope.Description}
+		if envelope.Parameters != nil {
+			apiErr.RetryAfter = envelope.Parameters.RetryAfter
+		}
+		if apiErr.Code == 0 {
+			apiErr.Code = resp.StatusCode
+		}
+		return apiErr
+	}
+	return json.Unmarshal(envelope.Result, dst)
+}
+
+// convert maps a channel post onto signal.Message. Chat IDs are converted from
+// the Bot API's marked form to the bare IDs the MTProto listener reports, so
+// [channels.<id>] profiles and dedupe keys match across sources.
+func (p *Poller) convert(u update, receivedAt time.Time) (signalpkg.Message, bool) {
+	post, edited := u.ChannelPost, false
+	if post == nil {
+		post, edited = u.EditedChannelPost, true
+	}
+	if post == nil {
+		return signalpkg.Message{}, false
+	}
+	if !p.allows(post.Chat) {
+		return signalpkg.Message{}, false
+	}
+
+	text, entities := post.Text, post.Entities
+	if text == "" {
+		text, entities = post.Caption, post.CaptionEntities
+	}
+
+	chatID, chatType := bareChatID(post.Chat)
+	msg := signalpkg.Message{
+		Account:      p.account,
+		ID:           post.MessageID,
+		ChatID:       chatID,
+		ChatType:     chatType,
+		ChatTitle:    post.Chat.Title,
+		ChatUsername: post.Chat.Username,
+		SenderName:   post.AuthorSignature,
+		Text:         text,
+		Timestamp:    time.Unix(post.Date, 0),
+		ReceivedAt:   receivedAt,
+		Edited:       edited,
+	}
+	if edited && post.EditDate > 0 {
+		msg.EditedAt = time.Unix(post.EditDate, 0)
+	}
+	if post.From != nil {
+		msg.SenderID = post.From.ID
+		if msg.SenderName == "" {
+			msg.SenderName = post.From.Username
+		}
+	}
+
+	units := utf16.Encode([]rune(text))
+	for _, e := range entities {
+		ent := signalpkg.Entity{Type: e.Type, Offset: e.Offset, Length: e.Length}
+		if e.Type == "text_link" {
+			ent.Type, ent.URL = "text_url", e.URL
+			label := ""
+			if e.Offset >= 0 && e.Length >= 0 && e.Offset+e.Length <= len(units) {
+				label = string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+			}
+			msg.Links = append(msg.Links, signalpkg.Link{URL: e.URL, Kind: "text_url", Label: label})
+		}
+		msg.Entities = append(msg.Entities, ent)
+	}
+	if post.ReplyMarkup != nil {
+		for _, row := range post.ReplyMarkup.InlineKeyboard {
+			for _, b := range row {
+				if b.URL != "" {
+					msg.Links = append(msg.Links, signalpkg.Link{URL: b.URL, Kind: "button", Label: b.Text})
+				}
+			}
+		}
+	}
+	// A post may carry its signal only in a button.
+	if strings.TrimSpace(text) == "" && len(msg.Links) == 0 {
+		return signalpkg.Message{}, false
+	}
+	return msg, true
+}
+
+func (p *Poller) allows(c chat) bool {
+	if len(p.allowed) == 0 {
+		return true
+	}
+	if _, ok := p.allowed[strconv.FormatInt(c.ID, 10)]; ok {
+		return true
+	}
+	if c.Username == "" {
+		return false
+	}
+	_, ok := p.allowed[strings.ToLower(c.Username)]
+	return ok
+}
+
+// normalizeChat folds an allowed_chats entry to a chat ID or lower-case username.
+func normalizeChat(entry string) string {
+	entry = strings.TrimSpace(entry)
+	if _, err := strconv.ParseInt(entry, 10, 64); err == nil {
+		return entry
+	}
+	entry = strings.TrimPrefix(strings.TrimPrefix(entry, "https://"), "http://")
+	entry = strings.TrimPrefix(entry, "t.me/")
+	return strings.ToLower(strings.TrimPrefix(entry, "@"))
+}
+
+// bareChatID strips the Bot API chat ID marking: -100<id> for channels and
+// supergroups, -<id> for basic groups.
+func bareChatID(c chat) (int64, string) {
+	switch c.Type {
+	case "channel", "supergroup":
+		return -c.ID - 1000000000000, "channel"
+	case "group":
+		return -c.ID, "chat"
+	default:
+		return c.ID, "user"
+	}
+}
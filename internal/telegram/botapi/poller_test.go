@@ -0,0 +1,251 @@
+package botapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/user/mexc-bot/internal/config"
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+const testToken = "123:secret"
+
+// fakeBotAPI serves getMe and scripted getUpdates responses, recording the
+// offset of each poll.
+type fakeBotAPI struct {
+	mu      sync.Mutex
+	batches []string
+	offsets []string
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/bot" + testToken + "/getMe":
+		io.WriteString(w, `{"ok":true,"result":{"id":42,"is_bot":true,"username":"signals_bot"}}`)
+	case "/bot" + testToken + "/getUpdates":
+		f.mu.Lock()
+		f.offsets = append(f.offsets, r.URL.Query().Get("offset"))
+		var batch string
+		if len(f.batches) > 0 {
+			batch, f.batches = f.batches[0], f.batches[1:]
+		}
+		f.mu.Unlock()
+		if batch == "" {
+			// Empty long poll.
+			select {
+			case <-r.Context().Done():
+			case <-time.After(50 * time.Millisecond):
+			}
+			batch = `{"ok":true,"result":[]}`
+		}
+		io.WriteString(w, batch)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"ok":false,"error_code":404,"description":"Not Found"}`)
+	}
+}
+
+func newTestPoller(t *testing.T, url string, allowed ...string) *Poller {
+	t.Helper()
+	p, err := NewPoller(config.TelegramBotConfig{APIURL: url, PollTimeoutSeconds: 1, AllowedChats: allowed}, testToken, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("new poller: %v", err)
+	}
+	return p
+}
+
+func TestPollerEmitsChannelPosts(t *testing.T) {
+	api := &fakeBotAPI{batches: []string{`{"ok":true,"result":[
+		{"update_id":100,"channel_post":{"message_id":7,"date":1710000000,"author_signature":"Admin",
+			"chat":{"id":-1001234567890,"type":"channel","title":"Pumps","username":"PumpSignals"},
+			"text":"MEGA PUMP SIGNAL 🚀 Trade here",
+			"entities":[{"type":"bold","offset":0,"length":16},{"type":"text_link","offset":20,"length":10,"url":"https://www.mexc.com/exchange/AAA_USDT"}],
+			"reply_markup":{"inline_keyboard":[[{"text":"Buy","url":"https://www.mexc.com/exchange/AAA_USDT"}]]}}},
+		{"update_id":101,"channel_post":{"message_id":3,"date":1710000001,
+			"chat":{"id":-1009999,"type":"channel","title":"Other"},"text":"not allowed"}},
+		{"update_id":102,"edited_channel_post":{"message_id":7,"date":1710000000,"edit_date":1710000005,
+			"chat":{"id":-1001234567890,"type":"channel","title":"Pumps","username":"PumpSignals"},
+			"photo":[],"caption":"MEGA PUMP SIGNAL $AAA"}}
+	]}`}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	p := newTestPoller(t, server.URL, "@pumpsignals")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan signalpkg.Message, 4)
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx, out) }()
+
+	var got []signalpkg.Message
+	for len(got) < 2 {
+		select {
+		case msg := <-out:
+			got = append(got, msg)
+		case err := <-done:
+			t.Fatalf("poller stopped early: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for posts, got %d", len(got))
+		}
+	}
+	// Let the poller acknowledge the batch with its next request.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		api.mu.Lock()
+		n := len(api.offsets)
+		api.mu.Unlock()
+		if n >= 2 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	post := got[0]
+	if post.Account != "bot:signals_bot" || post.ChatID != 1234567890 || post.ChatType != "channel" || post.ChatUsername != "PumpSignals" || post.ID != 7 || post.SenderName != "Admin" {
+		t.Fatalf("unexpected post metadata %+v", post)
+	}
+	if post.Timestamp.Unix() != 1710000000 || post.Edited || len(post.Entities) != 2 || post.Entities[1].Type != "text_url" {
+		t.Fatalf("unexpected post content %+v", post)
+	}
+	if len(post.Links) != 2 || post.Links[0].Label != "Trade here" || post.Links[1].Kind != "button" {
+		t.Fatalf("unexpected links %+v", post.Links)
+	}
+
+	edit := got[1]
+	if !edit.Edited || edit.Text != "MEGA PUMP SIGNAL $AAA" || edit.ChatID != 1234567890 {
+		t.Fatalf("unexpected edit %+v", edit)
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.offsets[0] != "" || api.offsets[1] != "103" {
+		t.Fatalf("expected updates acknowledged with offset 103, got %v", api.offsets)
+	}
+}
+
+func TestPollerStopsOnConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/getMe") {
+			io.WriteString(w, `{"ok":true,"result":{"id":42,"is_bot":true,"username":"signals_bot"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusConflict)
+		io.WriteString(w, `{"ok":false,"error_code":409,"description":"Conflict: can't use getUpdates method while webhook is active"}`)
+	}))
+	defer server.Close()
+
+	err := newTestPoller(t, server.URL).Run(context.Background(), make(chan signalpkg.Message))
+	if err == nil || !strings.Contains(err.Error(), "webhook is active") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Fatalf("error leaks the bot token: %v", err)
+	}
+}
+
+func TestPollerResumesFromSavedOffset(t *testing.T) {
+	api := &fakeBotAPI{batches: []string{`{"ok":true,"result":[
+		{"update_id":200,"channel_post":{"message_id":1,"date":1710000000,
+			"chat":{"id":-1001234567890,"type":"channel","title":"Pumps"},"text":"queued while down"}},
+		{"update_id":201,"channel_post":{"message_id":2,"date":1710000100,
+			"chat":{"id":-1001234567890,"type":"channel","title":"Pumps"},"text":"live"}}
+	]}`}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	offsetPath := filepath.Join(t.TempDir(), "state", "bot.offset")
+	start := func() (chan signalpkg.Message, func()) {
+		p, err := NewPoller(config.TelegramBotConfig{APIURL: server.URL, PollTimeoutSeconds: 1, OffsetPath: offsetPath}, testToken, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if err != nil {
+			t.Fatalf("new poller: %v", err)
+		}
+		p.now = func() time.Time { return time.Unix(1710000060, 0) }
+		ctx, cancel := context.WithCancel(context.Background())
+		out := make(chan signalpkg.Message, 4)
+		done := make(chan error, 1)
+		go func() { done <- p.Run(ctx, out) }()
+		return out, func() {
+			cancel()
+			if err := <-done; err != context.Canceled {
+				t.Fatalf("expected context.Canceled, got %v", err)
+			}
+		}
+	}
+
+	out, stop := start()
+	var got []signalpkg.Message
+	for len(got) < 2 {
+		select {
+		case msg := <-out:
+			got = append(got, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for posts, got %d", len(got))
+		}
+	}
+	if !got[0].Recovered || got[1].Recovered {
+		t.Fatalf("expected only the post from before startup recovered, got %v and %v", got[0].Recovered, got[1].Recovered)
+	}
+	// The offset is saved once the batch has been handed on.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, err := os.ReadFile(offsetPath); err == nil && strings.TrimSpace(string(data)) == "202" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("offset not saved")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+
+	api.mu.Lock()
+	api.offsets = nil
+	api.mu.Unlock()
+	_, stop = start()
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		api.mu.Lock()
+		n := len(api.offsets)
+		api.mu.Unlock()
+		if n > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if len(api.offsets) == 0 || api.offsets[0] != "202" {
+		t.Fatalf("expected restart to poll from offset 202, got %v", api.offsets)
+	}
+}
+
+func TestPollerKeepsButtonOnlyPosts(t *testing.T) {
+	p := newTestPoller(t, "http://unused")
+	post := func(markup *inlineKeyboard) update {
+		return update{ChannelPost: &message{MessageID: 5, Date: 1710000000, Chat: chat{ID: -1001234567890, Type: "channel"}, ReplyMarkup: markup}}
+	}
+
+	if _, ok := p.convert(post(nil), time.Now()); ok {
+		t.Fatalf("expected a post without text or links dropped")
+	}
+	msg, ok := p.convert(post(&inlineKeyboard{InlineKeyboard: [][]inlineButton{{{Text: "Trade", URL: "https://www.mexc.com/exchange/AAA_USDT"}}}}), time.Now())
+	if !ok || len(msg.Links) != 1 || msg.Links[0].Kind != "button" {
+		t.Fatalf("expected the button-only post kept, got %+v (ok=%v)", msg, ok)
+	}
+}
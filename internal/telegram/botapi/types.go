@@ -0,0 +1,79 @@
+package botapi
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// response is the envelope every Bot API method returns.
+type response struct {
+	OK          bool                `json:"ok"`
+	Result      json.RawMessage     `json:"result"`
+	ErrorCode   int                 `json:"error_code"`
+	Description string              `json:"description"`
+	Parameters  *responseParameters `json:"parameters"`
+}
+
+type responseParameters struct {
+	RetryAfter int `json:"retry_after"`
+}
+
+// apiError is a Bot API failure reported in the response envelope.
+type apiError struct {
+	Code        int
+	Description string
+	RetryAfter  int
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("bot api error %d: %s", e.Code, e.Description)
+}
+
+type update struct {
+	UpdateID          int64    `json:"update_id"`
+	ChannelPost       *message `json:"channel_post"`
+	EditedChannelPost *message `json:"edited_channel_post"`
+}
+
+type message struct {
+	MessageID       int64           `json:"message_id"`
+	Date            int64           `json:"date"`
+	EditDate        int64           `json:"edit_date"`
+	Chat            chat            `json:"chat"`
+	From            *botUser        `json:"from"`
+	AuthorSignature string          `json:"author_signature"`
+	Text            string          `json:"text"`
+	Entities        []entity        `json:"entities"`
+	Caption         string          `json:"caption"`
+	CaptionEntities []entity        `json:"caption_entities"`
+	ReplyMarkup     *inlineKeyboard `json:"reply_markup"`
+}
+
+type chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Username string `json:"username"`
+}
+
+type botUser struct {
+	ID       int64  `json:"id"`
+	IsBot    bool   `json:"is_bot"`
+	Username string `json:"username"`
+}
+
+type entity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url"`
+}
+
+type inlineKeyboard struct {
+	InlineKeyboard [][]inlineButton `json:"inline_keyboard"`
+}
+
+type inlineButton struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
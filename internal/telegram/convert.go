@@ -0,0 +1,193 @@
+package telegram
+
+import (
+	"sync"
+	"unicode/utf16"
+
+	"github.com/gotd/td/tg"
+
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+// peerMeta is the display data Telegram ships alongside updates, plus the
+// access hash needed to address the peer in API calls.
+type peerMeta struct {
+	title      string
+	username   string
+	accessHash int64
+}
+
+// peerCache remembers chat and user metadata from earlier updates, because
+// short updates arrive without the Chats/Users lists. Entries are
+// keyed by peer type, since channel, chat and user IDs overlap.
+type peerCache struct {
+	mu    sync.RWMutex
+	peers map[peerKey]peerMeta
+}
+
+func newPeerCache() *peerCache {
+	return &peerCache{peers: make(map[peerKey]peerMeta)}
+}
+
+func (c *peerCache) remember(chats []tg.ChatClass, users []tg.UserClass) {
+	if len(chats) == 0 && len(users) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, chat := range chats {
+		switch ch := chat.(type) {
+		case *tg.Channel:
+			c.put(peerKey{peerChannel, ch.ID}, peerMeta{title: ch.Title, username: ch.Username, accessHash: ch.AccessHash}, ch.Min)
+		case *tg.Chat:
+			c.put(peerKey{peerChat, ch.ID}, peerMeta{title: ch.Title}, false)
+		case *tg.ChannelForbidden:
+			c.put(peerKey{peerChannel, ch.ID}, peerMeta{title: ch.Title, accessHash: ch.AccessHash}, false)
+		case *tg.ChatForbidden:
+			c.put(peerKey{peerChat, ch.ID}, peerMeta{title: ch.Title}, false)
+		}
+	}
+	for _, user := range users {
+		if u, ok := user.(*tg.User); ok {
+			title := u.FirstName
+			if u.LastName != "" {
+				title += " " + u.LastName
+			}
+			c.put(peerKey{peerUser, u.ID}, peerMeta{title: title, username: u.Username, accessHash: u.AccessHash}, u.Min)
+		}
+	}
+}
+
+// put stores meta for key. Min constructors carry an access hash that is only
+// valid in the originating context, so they never replace a known one.
+func (c *peerCache) put(key peerKey, meta peerMeta, isMin bool) {
+	if prev, ok := c.peers[key]; ok && (isMin || meta.accessHash == 0) {
+		meta.accessHash = prev.accessHash
+	} else if isMin {
+		meta.accessHash = 0
+	}
+	c.peers[key] = meta
+}
+
+func (c *peerCache) lookup(key peerKey) peerMeta {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.peers[key]
+}
+
+func (c *peerCache) user(id int64) peerMeta {
+	return c.lookup(peerKey{peerUser, id})
+}
+
+// describePeer resolves the metadata for the chat a message was posted in.
+func (c *peerCache) describePeer(peer tg.PeerClass) peerMeta {
+	key, ok := keyOf(peer)
+	if !ok {
+		return peerMeta{}
+	}
+	return c.lookup(key)
+}
+
+// sender returns the author ID and display name of m.
+func (c *peerCache) sender(m *tg.Message) (int64, string) {
+	var (
+		id   int64
+		name string
+	)
+	if from, ok := m.GetFromID(); ok {
+		if u, ok := from.(*tg.PeerUser); ok {
+			id = u.UserID
+			meta := c.user(u.UserID)
+			name = meta.username
+			if name == "" {
+				name = meta.title
+			}
+		}
+	}
+	if author, ok := m.GetPostAuthor(); ok && author != "" {
+		name = author
+	}
+	return id, name
+}
+
+func convertEntities(entities []tg.MessageEntityClass) []signalpkg.Entity {
+	if len(entities) == 0 {
+		return nil
+	}
+	out := make([]signalpkg.Entity, 0, len(entities))
+	for _, ent := range entities {
+		e := signalpkg.Entity{
+			Offset: ent.GetOffset(),
+			Length: ent.GetLength(),
+		}
+		switch v := ent.(type) {
+		case *tg.MessageEntityURL:
+			e.Type = "url"
+		case *tg.MessageEntityTextURL:
+			e.Type = "text_url"
+			e.URL = v.URL
+		case *tg.MessageEntityHashtag:
+			e.Type = "hashtag"
+		case *tg.MessageEntityCashtag:
+			e.Type = "cashtag"
+		case *tg.MessageEntityMention:
+			e.Type = "mention"
+		case *tg.MessageEntityBold:
+			e.Type = "bold"
+		case *tg.MessageEntityItalic:
+			e.Type = "italic"
+		case *tg.MessageEntityCode:
+			e.Type = "code"
+		case *tg.MessageEntityPre:
+			e.Type = "pre"
+		default:
+			e.Type = ent.TypeName()
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// collectLinks gathers URLs that are not part of the visible text: text_url
+// entities ("Click here to trade") and inline keyboard URL buttons.
+func collectLinks(m *tg.Message) []signalpkg.Link {
+	var links []signalpkg.Link
+	var units []uint16
+	for _, ent := range m.Entities {
+		v, ok := ent.(*tg.MessageEntityTextURL)
+		if !ok || v.URL == "" {
+			continue
+		}
+		if units == nil {
+			units = utf16.Encode([]rune(m.Message))
+		}
+		links = append(links, signalpkg.Link{URL: v.URL, Kind: "text_url", Label: utf16Slice(units, v.Offset, v.Length)})
+	}
+
+	markup, ok := m.ReplyMarkup.(*tg.ReplyInlineMarkup)
+	if !ok {
+		return links
+	}
+	for _, row := range markup.Rows {
+		for _, button := range row.Buttons {
+			switch b := button.(type) {
+			case *tg.KeyboardButtonURL:
+				links = append(links, signalpkg.Link{URL: b.URL, Kind: "button", Label: b.Text})
+			case *tg.KeyboardButtonURLAuth:
+				links = append(links, signalpkg.Link{URL: b.URL, Kind: "button", Label: b.Text})
+			}
+		}
+	}
+	return links
+}
+
+// utf16Slice cuts an entity's text out of the message; Telegram offsets count
+// UTF-16 code units, not bytes or runes.
+func utf16Slice(units []uint16, offset, length int) string {
+	if offset < 0 || length <= 0 || offset >= len(units) {
+		return ""
+	}
+	end := min(offset+length, len(units))
+	return string(utf16.Decode(units[offset:end]))
+}
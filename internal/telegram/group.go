@@ -0,0 +1,157 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/user/mexc-bot/internal/metrics"
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+	"github.com/user/mexc-bot/internal/source"
+)
+
+var (
+	groupFirstCopies = metrics.Default.Counter("mexc_bot_telegram_first_copies_total",
+		"Posts forwarded because this account delivered them first.", "account")
+	groupCopyLag = metrics.Default.Histogram("mexc_bot_telegram_copy_lag_seconds",
+		"How far behind the first copy an account delivered a duplicate post.",
+		[]float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 5}, "account")
+)
+
+var _ source.Source = (*Group)(nil)
+
+// copyTTL is how long a forwarded post is remembered; later copies are
+// forwarded again, which the engine's dedupe cache still catches.
+const copyTTL = 10 * time.Minute
+
+// copyKey identifies one post across accounts. Channel message IDs are shared
+// by every subscriber, but private chats and basic groups number messages per
+// account, so those copies are matched on sender, date and text instead.
+type copyKey struct {
+	chatType string
+	chatID   int64
+	id       int64 // channels only
+	sender   int64 // user and chat peers only
+	date     int64 // user and chat peers only
+	edited   bool
+	text     uint64 // distinguishes successive edits, and user/chat posts
+}
+
+type firstCopy struct {
+	account    string
+	receivedAt time.Time
+}
+
+// Group runs several listeners as redundant sources for the same chats and
+// forwards only the first copy of each post, so the fastest session wins and
+// one banned or disconnected account does not stop ingestion.
+type Group struct {
+	logger    *slog.Logger
+	listeners []*Listener
+
+	mu        sync.Mutex
+	seen      map[copyKey]firstCopy
+	lastPrune time.Time
+}
+
+// NewGroup fans in the given listeners.
+func NewGroup(logger *slog.Logger, listeners ...*Listener) *Group {
+	return &Group{logger: logger, listeners: listeners, seen: make(map[copyKey]firstCopy)}
+}
+
+// Name implements source.Source.
+func (g *Group) Name() string {
+	return "telegram"
+}
+
+// Run starts every listener and forwards deduplicated messages to out until
+// ctx is cancelled or every listener has stopped. A failing listener is logged
+// and the others keep running.
+func (g *Group) Run(ctx context.Context, out chan<- signalpkg.Message) error {
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		stopped []error
+	)
+	for _, l := range g.listeners {
+		in := make(chan signalpkg.Message, 64)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			defer close(in)
+			err := l.Run(ctx, in)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				err = errors.New("listener stopped")
+			}
+			g.logger.Error("telegram account stopped", "account", l.Account(), "error", err)
+			errMu.Lock()
+			stopped = append(stopped, err)
+			errMu.Unlock()
+		}()
+		go func() {
+			defer wg.Done()
+			for msg := range in {
+				if !g.admit(msg) {
+					continue
+				}
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.Join(append([]error{errors.New("all telegram accounts stopped")}, stopped...)...)
+}
+
+// admit reports whether msg is the first copy of its post and records the
+// copy metrics.
+func (g *Group) admit(msg signalpkg.Message) bool {
+	key := copyKey{chatType: msg.ChatType, chatID: msg.ChatID, edited: msg.Edited}
+	if msg.ChatType == "channel" {
+		key.id = msg.ID
+	} else {
+		key.sender, key.date = msg.SenderID, msg.Timestamp.Unix()
+	}
+	if msg.Edited || msg.ChatType != "channel" {
+		h := fnv.New64a()
+		h.Write([]byte(msg.Text))
+		key.text = h.Sum64()
+	}
+	received := msg.ReceivedAt
+	if received.IsZero() {
+		received = time.Now()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if received.Sub(g.lastPrune) > time.Minute {
+		for k, c := range g.seen {
+			if received.Sub(c.receivedAt) > copyTTL {
+				delete(g.seen, k)
+			}
+		}
+		g.lastPrune = received
+	}
+
+	if first, ok := g.seen[key]; ok {
+		if first.account != msg.Account {
+			groupCopyLag.Observe(received.Sub(first.receivedAt).Seconds(), msg.Account)
+		}
+		return false
+	}
+	g.seen[key] = firstCopy{account: msg.Account, receivedAt: received}
+	groupFirstCopies.Inc(msg.Account)
+	return true
+}
@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+func TestGroupForwardsFirstCopy(t *testing.T) {
+	g := NewGroup(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now()
+	post := signalpkg.Message{ChatType: "channel", ChatID: 1001, ID: 5, Text: "MEGA PUMP SIGNAL", ReceivedAt: now}
+
+	primary, backup := post, post
+	primary.Account, backup.Account = "group-test-primary", "group-test-backup"
+	backup.ReceivedAt = now.Add(40 * time.Millisecond)
+
+	if !g.admit(primary) {
+		t.Fatalf("expected first copy forwarded")
+	}
+	if g.admit(backup) {
+		t.Fatalf("expected duplicate from second account dropped")
+	}
+	if got := groupFirstCopies.Value("group-test-primary"); got != 1 {
+		t.Fatalf("expected one first copy for primary, got %v", got)
+	}
+
+	// Same message ID in a different peer namespace is a different post.
+	other := backup
+	other.ChatType = "user"
+	if !g.admit(other) {
+		t.Fatalf("expected post from another peer type forwarded")
+	}
+
+	// Each distinct edit is forwarded once.
+	edit := primary
+	edit.Edited, edit.Text = true, "MEGA PUMP SIGNAL $AAA"
+	if !g.admit(edit) || g.admit(edit) {
+		t.Fatalf("expected edit forwarded exactly once")
+	}
+	edit.Text = "MEGA PUMP SIGNAL $BBB"
+	if !g.admit(edit) {
+		t.Fatalf("expected second edit forwarded")
+	}
+}
+
+func TestGroupMatchesPerAccountMessageIDs(t *testing.T) {
+	g := NewGroup(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Now()
+	posted := time.Unix(1710000000, 0)
+
+	// A basic group post: each account sees its own message ID.
+	primary := signalpkg.Message{Account: "a", ChatType: "chat", ChatID: 4567, ID: 120, SenderID: 777, Text: "PUMP ALERT $ABC", Timestamp: posted, ReceivedAt: now}
+	backup := primary
+	backup.Account, backup.ID = "b", 98
+
+	if !g.admit(primary) {
+		t.Fatalf("expected first copy forwarded")
+	}
+	if g.admit(backup) {
+		t.Fatalf("expected copy with a different per-account ID dropped")
+	}
+
+	// Unrelated private messages that happen to share an ID are both kept.
+	dmA := signalpkg.Message{Account: "a", ChatType: "user", ChatID: 10, ID: 7, SenderID: 10, Text: "hello", Timestamp: posted, ReceivedAt: now}
+	dmB := signalpkg.Message{Account: "b", ChatType: "user", ChatID: 11, ID: 7, SenderID: 11, Text: "signal", Timestamp: posted, ReceivedAt: now}
+	if !g.admit(dmA) || !g.admit(dmB) {
+		t.Fatalf("expected unrelated private messages with equal IDs forwarded")
+	}
+}
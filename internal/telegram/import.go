@@ -0,0 +1,133 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/session/tdesktop"
+)
+
+// ImportOptions tunes ImportSession.
+type ImportOptions struct {
+	// Passcode unlocks a TDesktop tdata directory protected by a local passcode.
+	Passcode []byte
+	// Account selects the TDesktop account when tdata holds several (0-based).
+	Account int
+	// Force overwrites an existing destination file.
+	Force bool
+}
+
+// ImportResult describes the imported session.
+type ImportResult struct {
+	Format string // telethon-sqlite, telethon-string or tdesktop
+	DC     int
+	Addr   string
+}
+
+// ImportSession converts a Telethon session (a .session SQLite file or a
+// StringSession string in a text file) or a TDesktop tdata directory into a
+// gotd session saved through dst, which Listener then loads directly.
+func ImportSession(ctx context.Context, src string, dst *SessionStorage, opts ImportOptions) (ImportResult, error) {
+	if _, err := os.Stat(dst.Path()); err == nil && !opts.Force {
+		return ImportResult{}, fmt.Errorf("%s already exists; pass -force to overwrite", dst.Path())
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	var (
+		data   *session.Data
+		format string
+	)
+	if info.IsDir() {
+		format = "tdesktop"
+		data, err = readTDesktop(src, opts)
+	} else {
+		var raw []byte
+		if raw, err = os.ReadFile(src); err != nil {
+			return ImportResult{}, err
+		}
+		if bytes.HasPrefix(raw, []byte(sqliteMagic)) {
+			format = "telethon-sqlite"
+			data, err = readTelethonSQLite(raw)
+		} else {
+			format = "telethon-string"
+			data, err = session.TelethonSession(strings.TrimSpace(string(raw)))
+		}
+	}
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("read %s session: %w", format, err)
+	}
+
+	loader := session.Loader{Storage: dst}
+	if err := loader.Save(ctx, data); err != nil {
+		return ImportResult{}, err
+	}
+	return ImportResult{Format: format, DC: data.DC, Addr: data.Addr}, nil
+}
+
+func readTDesktop(root string, opts ImportOptions) (*session.Data, error) {
+	accounts, err := tdesktop.Read(root, opts.Passcode)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Account < 0 || opts.Account >= len(accounts) {
+		return nil, fmt.Errorf("account %d requested, tdata holds %d", opts.Account, len(accounts))
+	}
+	return session.TDesktopSession(accounts[opts.Account])
+}
+
+// readTelethonSQLite reads the sessions table Telethon's SQLiteSession keeps:
+// (dc_id INTEGER PRIMARY KEY, server_address TEXT, port INTEGER, auth_key BLOB, takeout_id INTEGER).
+// The row is re-encoded as a StringSession so gotd derives the key ID itself.
+func readTelethonSQLite(raw []byte) (*session.Data, error) {
+	db, err := openSQLite(raw)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.table("sessions")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if len(row.columns) < 4 {
+			continue
+		}
+		key, _ := row.columns[3].([]byte)
+		if len(key) != 256 {
+			continue
+		}
+		// dc_id aliases the rowid, so SQLite stores NULL in the record itself.
+		dc := row.rowID
+		if v, ok := row.columns[0].(int64); ok {
+			dc = v
+		}
+		addr, _ := row.columns[1].(string)
+		port, _ := row.columns[2].(int64)
+
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid server address %q", addr)
+		}
+		if v4 := ip.To4(); v4 != nil {
+			ip = v4
+		}
+		buf := make([]byte, 0, 1+len(ip)+2+len(key))
+		buf = append(buf, byte(dc))
+		buf = append(buf, ip...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(port))
+		buf = append(buf, key...)
+		return session.TelethonSession("1" + base64.URLEncoding.EncodeToString(buf))
+	}
+	return nil, errors.New("no authorised session row found")
+}
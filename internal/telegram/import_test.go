@@ -0,0 +1,75 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gotd/td/session"
+
+	"github.com/user/mexc-bot/internal/config"
+)
+
+func TestImportTelethonSQLite(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "session.json")
+	storage, err := NewSessionStorage(dst, config.SecretRef{}, nil)
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	res, err := ImportSession(context.Background(), "testdata/telethon.session", storage, ImportOptions{})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if res.Format != "telethon-sqlite" || res.DC != 2 || res.Addr != "149.154.167.51:443" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 session file, got %v", info.Mode().Perm())
+	}
+
+	loader := session.Loader{Storage: &session.FileStorage{Path: dst}}
+	data, err := loader.Load(context.Background())
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := make([]byte, 256)
+	for i := range want {
+		want[i] = byte((i*7 + 3) % 256)
+	}
+	if data.DC != 2 || !bytes.Equal(data.AuthKey, want) || len(data.AuthKeyID) != 8 {
+		t.Fatalf("unexpected session data dc=%d key=%x id=%x", data.DC, data.AuthKey[:8], data.AuthKeyID)
+	}
+
+	if _, err := ImportSession(context.Background(), "testdata/telethon.session", storage, ImportOptions{}); err == nil {
+		t.Fatalf("expected refusal to overwrite existing session")
+	}
+}
+
+func TestSQLiteReaderWalksInteriorPages(t *testing.T) {
+	raw, err := os.ReadFile("testdata/telethon.session")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	db, err := openSQLite(raw)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	rows, err := db.table("entities")
+	if err != nil {
+		t.Fatalf("entities: %v", err)
+	}
+	if len(rows) != 200 {
+		t.Fatalf("expected 200 entities, got %d", len(rows))
+	}
+	last := rows[len(rows)-1]
+	if last.rowID != 200 || last.columns[2] != "user199" || last.columns[1] != int64(-199*1000003) {
+		t.Fatalf("unexpected last row %+v", last)
+	}
+}
@@ -5,83 +5,117 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"os"
-	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/gotd/td/session"
 	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/updates"
+	updhook "github.com/gotd/td/telegram/updates/hook"
 	"github.com/gotd/td/tg"
 
 	"github.com/user/mexc-bot/internal/config"
+	"github.com/user/mexc-bot/internal/metrics"
 	signalpkg "github.com/user/mexc-bot/internal/signal"
+	"github.com/user/mexc-bot/internal/source"
 )
 
+// receiveLatency is measured against the server post date, which has
+// one-second resolution; groupCopyLag compares accounts more precisely.
+var receiveLatency = metrics.Default.Histogram("mexc_bot_telegram_receive_latency_seconds",
+	"Delay between the Telegram post date and local receipt, by account.",
+	[]float64{0.25, 0.5, 1, 2, 5, 10, 30}, "account")
+
+var _ source.Source = (*Listener)(nil)
+
 // Listener consumes Telegram updates via a user session authenticated through MTProto.
 type Listener struct {
-	logger       *slog.Logger
-	cfg          config.TelegramConfig
-	client       *telegram.Client
-	storage      *session.FileStorage
-	allowedChats map[int64]struct{}
+	logger   *slog.Logger
+	cfg      config.TelegramConfig
+	client   *telegram.Client
+	storage  *SessionStorage
+	allowed  *allowList
+	chatRefs []chatRef
+	peers    *peerCache
+
+	gaps      *updates.Manager
+	state     *updateStore
+	recovered *recoveryTracker
 
 	outMu sync.RWMutex
 	out   chan<- signalpkg.Message
-}
 
-// NewListener prepares a MTProto-based listener using gotd/td.
-func NewListener(cfg config.TelegramConfig, apiHash string, logger *slog.Logger) (*Listener, error) {
-	if cfg.APIID <= 0 {
-		return nil, errors.New("telegram api_id must be positive")
-	}
-	if strings.TrimSpace(apiHash) == "" {
-		return nil, errors.New("telegram api_hash must be provided")
-	}
-	if strings.TrimSpace(cfg.SessionStoragePath) == "" {
-		return nil, errors.New("telegram session_storage_path must be provided")
-	}
+	// connMu guards the connected client, used by Sender, and the chats it
+	// posts to, whose echoes must not be read back as signals.
+	connMu   sync.RWMutex
+	api      *tg.Client
+	selfID   int64
+	ownChats map[peerKey]struct{}
+}
 
-	sessionPath := filepath.Clean(cfg.SessionStoragePath)
-	if err := os.MkdirAll(filepath.Dir(sessionPath), 0o700); err != nil {
-		return nil, fmt.Errorf("prepare session directory: %w", err)
+// NewListener prepares a MTProto-based listener using gotd/td. The session is
+// loaded from and saved to storage (see OpenSessionStorage); update state is
+// kept in "<session path>.updates" so gaps are recovered across restarts.
+// Call Close to release the state file.
+func NewListener(cfg config.TelegramConfig, apiHash string, storage *SessionStorage, logger *slog.Logger) (*Listener, error) {
+	if err := validateClient(cfg, apiHash); err != nil {
+		return nil, err
 	}
 
-	storage := &session.FileStorage{Path: sessionPath}
-	allowed := make(map[int64]struct{}, len(cfg.AllowedChatIDs))
-	for _, id := range cfg.AllowedChatIDs {
-		allowed[id] = struct{}{}
+	refs := make([]chatRef, 0, len(cfg.AllowedChats))
+	for _, entry := range cfg.AllowedChats {
+		ref, err := parseChatRef(entry)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
 	}
 
-	device := telegram.DeviceConfig{
-		DeviceModel:    fallback(cfg.DeviceModel, "mexc-bot"),
-		SystemLangCode: fallback(cfg.SystemLanguage, "en"),
-		LangCode:       fallback(cfg.SystemLanguage, "en"),
-		AppVersion:     fallback(cfg.ApplicationVersion, "0.1.0"),
+	state, err := openUpdateStore(storage.Path() + ".updates")
+	if err != nil {
+		return nil, err
 	}
 
 	listener := &Listener{
-		logger:       logger,
-		cfg:          cfg,
-		storage:      storage,
-		allowedChats: allowed,
+		logger:    logger,
+		cfg:       cfg,
+		storage:   storage,
+		allowed:   newAllowList(cfg.AllowedChatIDs, refs),
+		chatRefs:  refs,
+		peers:     newPeerCache(),
+		state:     state,
+		recovered: newRecoveryTracker(),
 	}
 
+	listener.gaps = listener.newUpdateManager()
+
 	client := telegram.NewClient(cfg.APIID, strings.TrimSpace(apiHash), telegram.Options{
 		SessionStorage: storage,
-		UpdateHandler: telegram.UpdateHandlerFunc(func(ctx context.Context, u tg.UpdatesClass) error {
-			return listener.handleUpdate(ctx, u)
-		}),
-		Device: device,
+		UpdateHandler:  listener.gaps,
+		Middlewares:    []telegram.Middleware{updhook.UpdateHook(listener.gaps.Handle)},
+		Device:         deviceConfig(cfg),
 	})
 	listener.client = client
 
 	return listener, nil
 }
 
+// newUpdateManager orders updates by pts, recovers gaps from l.state and
+// expands the short update forms into full messages before handleUpdate sees
+// them.
+func (l *Listener) newUpdateManager() *updates.Manager {
+	return updates.New(updates.Config{
+		Handler:      telegram.UpdateHandlerFunc(l.handleUpdate),
+		Storage:      l.state,
+		AccessHasher: l.state,
+		OnChannelTooLong: func(channelID int64) {
+			l.logger.Warn("telegram channel gap too long to recover; posts in the gap are lost", "channel_id", channelID)
+		},
+	})
+}
+
 // Run begins consuming updates until the provided context is cancelled.
-// The session must already be authorised; use an external tool to create the session file.
+// The session must already be authorised; create it with "bot telegram login".
 func (l *Listener) Run(ctx context.Context, out chan<- signalpkg.Message) error {
 	l.outMu.Lock()
 	l.out = out
@@ -98,33 +132,100 @@ func (l *Listener) Run(ctx context.Context, out chan<- signalpkg.Message) error
 			return fmt.Errorf("telegram auth status: %w", err)
 		}
 		if !status.Authorized {
-			return errors.New("telegram session is not authorised; import a user session first")
+			return errors.New("telegram session is not authorised; run \"bot telegram login\" first")
 		}
-		if status.User != nil {
-			l.logger.Info("telegram session ready", "user_id", status.User.ID, "username", status.User.Username)
-		} else {
-			l.logger.Info("telegram session ready")
+		self := status.User
+		if self == nil {
+			if self, err = l.client.Self(runCtx); err != nil {
+				return fmt.Errorf("telegram self: %w", err)
+			}
+		}
+		l.logger.Info("telegram session ready", "user_id", self.ID, "username", self.Username)
+		l.connMu.Lock()
+		l.api, l.selfID = l.client.API(), self.ID
+		l.connMu.Unlock()
+		defer func() {
+			l.connMu.Lock()
+			l.api = nil
+			l.connMu.Unlock()
+		}()
+		if err := l.resolveAllowed(runCtx, l.client.API()); err != nil {
+			return err
 		}
 
-		<-runCtx.Done()
-		return runCtx.Err()
+		// The manager loads the persisted pts/qts and fetches the difference
+		// since the last run before live updates are applied.
+		api := recoveringAPI{API: l.client.API(), tracker: l.recovered}
+		return l.gaps.Run(runCtx, api, self.ID, updates.AuthOptions{
+			OnStart: func(ctx context.Context) {
+				l.logger.Info("telegram update state loaded")
+			},
+		})
 	})
 }
 
+// Account names the session this listener runs as.
+func (l *Listener) Account() string {
+	if l.cfg.Account == "" {
+		return "default"
+	}
+	return l.cfg.Account
+}
+
+// Name implements source.Source.
+func (l *Listener) Name() string {
+	return "telegram:" + l.Account()
+}
+
+// connection returns the client while the session is connected, and the
+// account's user ID.
+func (l *Listener) connection() (*tg.Client, int64) {
+	l.connMu.RLock()
+	defer l.connMu.RUnlock()
+	return l.api, l.selfID
+}
+
+func (l *Listener) markOwnChat(key peerKey) {
+	l.connMu.Lock()
+	defer l.connMu.Unlock()
+	if l.ownChats == nil {
+		l.ownChats = make(map[peerKey]struct{})
+	}
+	l.ownChats[key] = struct{}{}
+}
+
+// isOwnPost reports whether m is a message this account sent to a chat it
+// posts notifications to.
+func (l *Listener) isOwnPost(key peerKey, m *tg.Message) bool {
+	if !m.Out {
+		return false
+	}
+	l.connMu.RLock()
+	defer l.connMu.RUnlock()
+	_, ok := l.ownChats[key]
+	return ok
+}
+
+// Close releases the update state file.
+func (l *Listener) Close() error {
+	return l.state.Close()
+}
+
 func (l *Listener) handleUpdate(ctx context.Context, upd tg.UpdatesClass) error {
 	switch u := upd.(type) {
 	case *tg.Updates:
+		l.peers.remember(u.Chats, u.Users)
 		for _, item := range u.Updates {
 			l.handleUpdateClass(ctx, item)
 		}
 	case *tg.UpdatesCombined:
+		// The update manager rewrites UpdateShortMessage and
+		// UpdateShortChatMessage into an UpdateNewMessage and delivers it in
+		// this form, after ordering it by pts.
+		l.peers.remember(u.Chats, u.Users)
 		for _, item := range u.Updates {
 			l.handleUpdateClass(ctx, item)
 		}
-	case *tg.UpdateShort:
-		l.handleUpdateClass(ctx, u.Update)
-	case *tg.UpdateShortMessage, *tg.UpdateShortChatMessage, *tg.UpdateShortSentMessage:
-		// Short updates omit Peer/Chat metadata we rely on; ignore them.
 	default:
 		// Unhandled update types are ignored.
 	}
@@ -134,40 +235,58 @@ func (l *Listener) handleUpdate(ctx context.Context, upd tg.UpdatesClass) error
 func (l *Listener) handleUpdateClass(ctx context.Context, upd tg.UpdateClass) {
 	switch u := upd.(type) {
 	case *tg.UpdateNewMessage:
-		l.consumeMessage(ctx, u.Message)
+		l.consumeMessage(ctx, u.Message, false)
 	case *tg.UpdateNewChannelMessage:
-		l.consumeMessage(ctx, u.Message)
+		l.consumeMessage(ctx, u.Message, false)
 	case *tg.UpdateEditMessage:
-		l.consumeMessage(ctx, u.Message)
+		l.consumeMessage(ctx, u.Message, true)
 	case *tg.UpdateEditChannelMessage:
-		l.consumeMessage(ctx, u.Message)
+		l.consumeMessage(ctx, u.Message, true)
 	default:
 	}
 }
 
-func (l *Listener) consumeMessage(ctx context.Context, msg tg.MessageClass) {
+func (l *Listener) consumeMessage(ctx context.Context, msg tg.MessageClass, edited bool) {
+	receivedAt := time.Now()
 	m, ok := msg.(*tg.Message)
 	if !ok {
 		return
 	}
-	if strings.TrimSpace(m.Message) == "" {
+	// A post may carry its signal only in a button, so empty text alone is
+	// not a reason to drop it.
+	links := collectLinks(m)
+	if strings.TrimSpace(m.Message) == "" && len(links) == 0 {
 		return
 	}
 
-	chatID, ok := peerID(m.PeerID)
-	if !ok {
+	key, ok := keyOf(m.PeerID)
+	if !ok || !l.allowed.allows(key) || l.isOwnPost(key, m) {
 		return
 	}
-	if len(l.allowedChats) > 0 {
-		if _, allowed := l.allowedChats[chatID]; !allowed {
-			return
-		}
-	}
 
+	chat := l.peers.describePeer(m.PeerID)
+	senderID, senderName := l.peers.sender(m)
+	timestamp := time.Unix(int64(m.Date), 0)
+	receiveLatency.Observe(receivedAt.Sub(timestamp).Seconds(), l.Account())
 	signalMsg := signalpkg.Message{
-		ID:        int64(m.ID),
-		Text:      m.Message,
-		Timestamp: time.Unix(int64(m.Date), 0),
+		Account:      l.Account(),
+		ID:           int64(m.ID),
+		ChatID:       key.id,
+		ChatType:     key.kind.String(),
+		ChatTitle:    chat.title,
+		ChatUsername: chat.username,
+		SenderID:     senderID,
+		SenderName:   senderName,
+		Text:         m.Message,
+		Entities:     convertEntities(m.Entities),
+		Links:        links,
+		Timestamp:    timestamp,
+		ReceivedAt:   receivedAt,
+		Edited:       edited,
+		Recovered:    l.recovered.take(key, m.ID),
+	}
+	if date, ok := m.GetEditDate(); ok && edited {
+		signalMsg.EditedAt = time.Unix(int64(date), 0)
 	}
 
 	l.outMu.RLock()
@@ -183,16 +302,23 @@ func (l *Listener) consumeMessage(ctx context.Context, msg tg.MessageClass) {
 	}
 }
 
-func peerID(peer tg.PeerClass) (int64, bool) {
-	switch p := peer.(type) {
-	case *tg.PeerChannel:
-		return int64(p.ChannelID), true
-	case *tg.PeerChat:
-		return int64(p.ChatID), true
-	case *tg.PeerUser:
-		return int64(p.UserID), true
-	default:
-		return 0, false
+// validateClient checks the MTProto client credentials.
+func validateClient(cfg config.TelegramConfig, apiHash string) error {
+	if cfg.APIID <= 0 {
+		return errors.New("telegram api_id must be positive")
+	}
+	if strings.TrimSpace(apiHash) == "" {
+		return errors.New("telegram api_hash must be provided")
+	}
+	return nil
+}
+
+func deviceConfig(cfg config.TelegramConfig) telegram.DeviceConfig {
+	return telegram.DeviceConfig{
+		DeviceModel:    fallback(cfg.DeviceModel, "mexc-bot"),
+		SystemLangCode: fallback(cfg.SystemLanguage, "en"),
+		LangCode:       fallback(cfg.SystemLanguage, "en"),
+		AppVersion:     fallback(cfg.ApplicationVersion, "0.1.0"),
 	}
 }
 
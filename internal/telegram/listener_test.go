@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+
+	signalpkg "github.com/user/mexc-bot/internal/signal"
+)
+
+func newTestListener(refs ...string) (*Listener, chan signalpkg.Message) {
+	parsed := make([]chatRef, 0, len(refs))
+	for _, r := range refs {
+		ref, _ := parseChatRef(r)
+		parsed = append(parsed, ref)
+	}
+	out := make(chan signalpkg.Message, 4)
+	return &Listener{
+		logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		allowed:   newAllowList(nil, parsed),
+		peers:     newPeerCache(),
+		recovered: newRecoveryTracker(),
+		out:       out,
+	}, out
+}
+
+// stubUpdatesAPI reports an empty difference, so the update manager applies
+// pushed updates in pts order starting at pts 100.
+type stubUpdatesAPI struct{}
+
+func (stubUpdatesAPI) UpdatesGetState(ctx context.Context) (*tg.UpdatesState, error) {
+	return &tg.UpdatesState{Pts: 100, Date: 1710000000, Seq: 1}, nil
+}
+
+func (stubUpdatesAPI) UpdatesGetDifference(ctx context.Context, req *tg.UpdatesGetDifferenceRequest) (tg.UpdatesDifferenceClass, error) {
+	return &tg.UpdatesDifferenceEmpty{Date: req.Date, Seq: 1}, nil
+}
+
+func (stubUpdatesAPI) UpdatesGetChannelDifference(ctx context.Context, req *tg.UpdatesGetChannelDifferenceRequest) (tg.UpdatesChannelDifferenceClass, error) {
+	return &tg.UpdatesChannelDifferenceEmpty{Pts: 1, Final: true}, nil
+}
+
+// runUpdates starts the listener's update manager, as Run does once the
+// session is authorised, and returns it ready for pushed updates.
+func runUpdates(t *testing.T, l *Listener, self int64) *updates.Manager {
+	t.Helper()
+	state, err := openUpdateStore(filepath.Join(t.TempDir(), "session.updates"))
+	if err != nil {
+		t.Fatalf("open update store: %v", err)
+	}
+	l.state = state
+	gaps := l.newUpdateManager()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = gaps.Run(ctx, stubUpdatesAPI{}, self, updates.AuthOptions{OnStart: func(context.Context) { close(started) }})
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		_ = state.Close()
+	})
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("update manager did not start")
+	}
+	return gaps
+}
+
+func receive(t *testing.T, out <-chan signalpkg.Message) signalpkg.Message {
+	t.Helper()
+	select {
+	case msg := <-out:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no message delivered")
+		return signalpkg.Message{}
+	}
+}
+
+func TestListenerHandlesShortUpdates(t *testing.T) {
+	ctx := context.Background()
+	l, out := newTestListener("user:777", "chat:4567")
+	gaps := runUpdates(t, l, 1)
+
+	// Optional fields are only visible once their flag is set, as on decoded updates.
+	signal := &tg.UpdateShortMessage{ID: 10, UserID: 777, Message: "MEGA PUMP SIGNAL", Date: 1710000000, Pts: 101, PtsCount: 1}
+	signal.SetEntities([]tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 4}})
+	for _, u := range []tg.UpdatesClass{
+		signal,
+		// Same numeric ID as the allowed group, but a private chat: not allowed.
+		&tg.UpdateShortMessage{ID: 11, UserID: 4567, Message: "spoof", Date: 1710000001, Pts: 102, PtsCount: 1},
+		&tg.UpdateShortChatMessage{ID: 12, FromID: 777, ChatID: 4567, Message: "PUMP ALERT", Date: 1710000002, Pts: 103, PtsCount: 1},
+	} {
+		if err := gaps.Handle(ctx, u); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+
+	private := receive(t, out)
+	if private.ChatID != 777 || private.ChatType != "user" || private.SenderID != 777 || private.ID != 10 || len(private.Entities) != 1 {
+		t.Fatalf("unexpected private message %+v", private)
+	}
+	group := receive(t, out)
+	if group.ChatID != 4567 || group.ChatType != "chat" || group.SenderID != 777 || group.Text != "PUMP ALERT" || group.Timestamp.Unix() != 1710000002 {
+		t.Fatalf("unexpected group message %+v", group)
+	}
+}
+
+// TestListenerHandlesCombinedShortUpdates feeds the handler the form the
+// update manager hands it for short messages: an UpdatesCombined carrying the
+// UpdateNewMessage it built from UpdateShortMessage or UpdateShortChatMessage.
+func TestListenerHandlesCombinedShortUpdates(t *testing.T) {
+	ctx := context.Background()
+	l, out := newTestListener("user:777", "chat:4567")
+
+	private := &tg.Message{ID: 10, PeerID: &tg.PeerUser{UserID: 777}, Message: "MEGA PUMP SIGNAL", Date: 1710000000}
+	private.SetFromID(&tg.PeerUser{UserID: 777})
+	private.SetEntities([]tg.MessageEntityClass{&tg.MessageEntityBold{Offset: 0, Length: 4}})
+	group := &tg.Message{ID: 12, PeerID: &tg.PeerChat{ChatID: 4567}, Message: "PUMP ALERT", Date: 1710000002}
+	group.SetFromID(&tg.PeerUser{UserID: 777})
+
+	for _, msg := range []*tg.Message{private, group} {
+		combined := &tg.UpdatesCombined{
+			Updates: []tg.UpdateClass{&tg.UpdateNewMessage{Message: msg, Pts: 101, PtsCount: 1}},
+			Date:    msg.Date,
+		}
+		if err := l.handleUpdate(ctx, combined); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+
+	if got := receive(t, out); got.ChatID != 777 || got.ChatType != "user" || got.SenderID != 777 || got.ID != 10 || len(got.Entities) != 1 {
+		t.Fatalf("unexpected private message %+v", got)
+	}
+	if got := receive(t, out); got.ChatID != 4567 || got.ChatType != "chat" || got.SenderID != 777 || got.Text != "PUMP ALERT" {
+		t.Fatalf("unexpected group message %+v", got)
+	}
+}
+
+func TestListenerSkipsOwnNotifications(t *testing.T) {
+	ctx := context.Background()
+	l, out := newTestListener()
+	l.markOwnChat(peerKey{peerUser, 777})
+	gaps := runUpdates(t, l, 1)
+
+	for _, u := range []tg.UpdatesClass{
+		// Our own post to the notify chat echoes back through the update stream.
+		&tg.UpdateShortMessage{ID: 20, UserID: 777, Out: true, Message: "Order submitted: BUY ABCUSDT", Date: 1710000000, Pts: 101, PtsCount: 1},
+		// Incoming messages in that chat and our posts elsewhere still pass.
+		&tg.UpdateShortMessage{ID: 21, UserID: 777, Message: "MEGA PUMP SIGNAL", Date: 1710000001, Pts: 102, PtsCount: 1},
+		&tg.UpdateShortMessage{ID: 22, UserID: 888, Out: true, Message: "PUMP ALERT", Date: 1710000002, Pts: 103, PtsCount: 1},
+	} {
+		if err := gaps.Handle(ctx, u); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+
+	if msg := receive(t, out); msg.ID != 21 {
+		t.Fatalf("expected incoming message 21, got %d", msg.ID)
+	}
+	if msg := receive(t, out); msg.ID != 22 {
+		t.Fatalf("expected own post elsewhere, got %d", msg.ID)
+	}
+}
+
+func TestListenerKeepsButtonOnlyPosts(t *testing.T) {
+	ctx := context.Background()
+	l, out := newTestListener("chat:4567")
+
+	bare := &tg.Message{ID: 30, PeerID: &tg.PeerChat{ChatID: 4567}, Date: 1710000000}
+	button := &tg.Message{ID: 31, PeerID: &tg.PeerChat{ChatID: 4567}, Date: 1710000001}
+	button.SetReplyMarkup(&tg.ReplyInlineMarkup{Rows: []tg.KeyboardButtonRow{{Buttons: []tg.KeyboardButtonClass{
+		&tg.KeyboardButtonURL{Text: "Trade", URL: "https://www.mexc.com/exchange/AAA_USDT"},
+	}}}})
+	for _, msg := range []*tg.Message{bare, button} {
+		if err := l.handleUpdate(ctx, &tg.UpdatesCombined{Updates: []tg.UpdateClass{&tg.UpdateNewMessage{Message: msg}}}); err != nil {
+			t.Fatalf("handle: %v", err)
+		}
+	}
+
+	got := receive(t, out)
+	if got.ID != 31 || len(got.Links) != 1 || got.Links[0].Kind != "button" {
+		t.Fatalf("expected the button-only post, got %+v", got)
+	}
+	select {
+	case extra := <-out:
+		t.Fatalf("expected the empty post dropped, got %+v", extra)
+	default:
+	}
+}
@@ -0,0 +1,142 @@
+package telegram
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/tg"
+	"golang.org/x/term"
+
+	"github.com/user/mexc-bot/internal/config"
+)
+
+// Login runs the interactive user sign-in flow and writes the authorised
+// session to storage. Prompts go to out and answers are read line by line from
+// in; when in is a terminal the password is read without echo. An already
+// authorised session is left untouched.
+func Login(ctx context.Context, cfg config.TelegramConfig, apiHash string, storage *SessionStorage, in io.Reader, out io.Writer) error {
+	if err := validateClient(cfg, apiHash); err != nil {
+		return err
+	}
+
+	client := telegram.NewClient(cfg.APIID, strings.TrimSpace(apiHash), telegram.Options{
+		SessionStorage: storage,
+		Device:         deviceConfig(cfg),
+	})
+	prompter := &terminalAuth{in: bufio.NewReader(in), out: out}
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		prompter.readSecret = func() (string, error) {
+			b, err := term.ReadPassword(int(f.Fd()))
+			fmt.Fprintln(out)
+			return string(b), err
+		}
+	}
+
+	err := client.Run(ctx, func(ctx context.Context) error {
+		flow := auth.NewFlow(prompter, auth.SendCodeOptions{})
+		if err := client.Auth().IfNecessary(ctx, flow); err != nil {
+			return fmt.Errorf("telegram sign-in: %w", err)
+		}
+		status, err := client.Auth().Status(ctx)
+		if err != nil {
+			return fmt.Errorf("telegram auth status: %w", err)
+		}
+		if !status.Authorized || status.User == nil {
+			return errors.New("telegram sign-in finished without an authorised user")
+		}
+		fmt.Fprintf(out, "Signed in as %s (id %d).\n", displayName(status.User), status.User.ID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if storage.Encrypted() {
+		fmt.Fprintf(out, "Encrypted session written to %s.\n", storage.Path())
+	} else {
+		fmt.Fprintf(out, "Session written to %s.\n", storage.Path())
+	}
+	return nil
+}
+
+// terminalAuth answers gotd's auth flow from line-oriented input. Sign-up is
+// refused: the bot must run as an existing account with channel access.
+type terminalAuth struct {
+	in  *bufio.Reader
+	out io.Writer
+	// readSecret reads the password without echo; nil reads it as a line.
+	readSecret func() (string, error)
+}
+
+func (t *terminalAuth) prompt(label string) (string, error) {
+	fmt.Fprint(t.out, label)
+	line, err := t.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("read %s: %w", promptName(label), err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func (t *terminalAuth) promptSecret(label string) (string, error) {
+	if t.readSecret == nil {
+		return t.prompt(label)
+	}
+	fmt.Fprint(t.out, label)
+	secret, err := t.readSecret()
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", promptName(label), err)
+	}
+	return strings.TrimSpace(secret), nil
+}
+
+func promptName(label string) string {
+	return strings.TrimSuffix(strings.TrimSpace(label), ":")
+}
+
+func (t *terminalAuth) Phone(ctx context.Context) (string, error) {
+	return t.prompt("Phone number (international format, e.g. +15551234567): ")
+}
+
+func (t *terminalAuth) Password(ctx context.Context) (string, error) {
+	pwd, err := t.promptSecret("Two-step verification password: ")
+	if err != nil {
+		return "", err
+	}
+	if pwd == "" {
+		return "", auth.ErrPasswordNotProvided
+	}
+	return pwd, nil
+}
+
+func (t *terminalAuth) Code(ctx context.Context, sentCode *tg.AuthSentCode) (string, error) {
+	label := "Login code: "
+	switch sentCode.Type.(type) {
+	case *tg.AuthSentCodeTypeApp:
+		label = "Login code (sent to your Telegram app): "
+	case *tg.AuthSentCodeTypeSMS:
+		label = "Login code (sent by SMS): "
+	}
+	return t.prompt(label)
+}
+
+func (t *terminalAuth) AcceptTermsOfService(ctx context.Context, tos tg.HelpTermsOfService) error {
+	return errors.New("telegram requires accepting new terms of service; sign in once with an official client")
+}
+
+func (t *terminalAuth) SignUp(ctx context.Context) (auth.UserInfo, error) {
+	return auth.UserInfo{}, errors.New("phone number is not registered with Telegram; sign up with an official client first")
+}
+
+func displayName(u *tg.User) string {
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gotd/td/telegram/auth"
+	"github.com/gotd/td/tg"
+)
+
+func TestTerminalAuthScriptedInput(t *testing.T) {
+	ctx := context.Background()
+	var out strings.Builder
+	prompter := &terminalAuth{in: bufio.NewReader(strings.NewReader("+15551234567\n 12345 \nhunter2\n")), out: &out}
+
+	phone, err := prompter.Phone(ctx)
+	if err != nil || phone != "+15551234567" {
+		t.Fatalf("phone: got %q, %v", phone, err)
+	}
+	code, err := prompter.Code(ctx, &tg.AuthSentCode{Type: &tg.AuthSentCodeTypeApp{}})
+	if err != nil || code != "12345" {
+		t.Fatalf("code: got %q, %v", code, err)
+	}
+	// Without a terminal the password is an ordinary line.
+	pwd, err := prompter.Password(ctx)
+	if err != nil || pwd != "hunter2" {
+		t.Fatalf("password: got %q, %v", pwd, err)
+	}
+	if !strings.Contains(out.String(), "sent to your Telegram app") {
+		t.Fatalf("unexpected prompts %q", out.String())
+	}
+
+	if _, err := prompter.Phone(ctx); err == nil || !strings.Contains(err.Error(), "read Phone number") {
+		t.Fatalf("expected error at end of input, got %v", err)
+	}
+}
+
+func TestTerminalAuthReadsPasswordWithoutEcho(t *testing.T) {
+	ctx := context.Background()
+	var out strings.Builder
+	lines := "line input must not be used\n"
+	secrets := []string{"hunter2", ""}
+	prompter := &terminalAuth{
+		in:  bufio.NewReader(strings.NewReader(lines)),
+		out: &out,
+		readSecret: func() (string, error) {
+			s := secrets[0]
+			secrets = secrets[1:]
+			return s, nil
+		},
+	}
+
+	pwd, err := prompter.Password(ctx)
+	if err != nil || pwd != "hunter2" {
+		t.Fatalf("password: got %q, %v", pwd, err)
+	}
+	if strings.Contains(out.String(), "hunter2") {
+		t.Fatalf("password echoed: %q", out.String())
+	}
+	if _, err := prompter.Password(ctx); !errors.Is(err, auth.ErrPasswordNotProvided) {
+		t.Fatalf("expected ErrPasswordNotProvided for an empty password, got %v", err)
+	}
+	if line, _ := prompter.in.ReadString('\n'); line != lines {
+		t.Fatalf("password prompt consumed line input")
+	}
+}
@@ -0,0 +1,271 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/tg"
+)
+
+// peerKind distinguishes the Telegram peer namespaces; a channel, a basic
+// group and a user may share the same numeric ID.
+type peerKind uint8
+
+const (
+	peerUser peerKind = iota + 1
+	peerChat
+	peerChannel
+)
+
+func (k peerKind) String() string {
+	switch k {
+	case peerUser:
+		return "user"
+	case peerChat:
+		return "chat"
+	case peerChannel:
+		return "channel"
+	default:
+		return "unknown"
+	}
+}
+
+// peerKey identifies a peer unambiguously.
+type peerKey struct {
+	kind peerKind
+	id   int64
+}
+
+func (k peerKey) String() string {
+	return k.kind.String() + ":" + strconv.FormatInt(k.id, 10)
+}
+
+func keyOf(peer tg.PeerClass) (peerKey, bool) {
+	switch p := peer.(type) {
+	case *tg.PeerChannel:
+		return peerKey{peerChannel, p.ChannelID}, true
+	case *tg.PeerChat:
+		return peerKey{peerChat, p.ChatID}, true
+	case *tg.PeerUser:
+		return peerKey{peerUser, p.UserID}, true
+	default:
+		return peerKey{}, false
+	}
+}
+
+func keyOfChat(chat tg.ChatClass) (peerKey, bool) {
+	switch c := chat.(type) {
+	case *tg.Channel:
+		return peerKey{peerChannel, c.ID}, true
+	case *tg.ChannelForbidden:
+		return peerKey{peerChannel, c.ID}, true
+	case *tg.Chat:
+		return peerKey{peerChat, c.ID}, true
+	case *tg.ChatForbidden:
+		return peerKey{peerChat, c.ID}, true
+	default:
+		return peerKey{}, false
+	}
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+
+// chatRef is one telegram.allowed_chats entry. Exactly one of username,
+// invite or key is set; the first two are resolved once connected.
+type chatRef struct {
+	raw      string
+	username string
+	invite   string
+	key      peerKey
+}
+
+// parseChatRef accepts @username, t.me/username, t.me/+hash and
+// t.me/joinchat/hash invite links, t.me/c/<id> private channel links,
+// channel:/chat:/user:<id>, and Bot API style marked IDs (-100<id> for
+// channels, -<id> for basic groups, <id> for users).
+func parseChatRef(entry string) (chatRef, error) {
+	raw := strings.TrimSpace(entry)
+	ref := chatRef{raw: raw}
+	s := raw
+
+	if kind, id, ok := strings.Cut(s, ":"); ok && !strings.Contains(id, "/") {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil || n <= 0 {
+			return ref, fmt.Errorf("allowed chat %q: invalid id", raw)
+		}
+		switch kind {
+		case "channel":
+			ref.key = peerKey{peerChannel, n}
+		case "chat":
+			ref.key = peerKey{peerChat, n}
+		case "user":
+			ref.key = peerKey{peerUser, n}
+		default:
+			return ref, fmt.Errorf("allowed chat %q: unknown peer type %q", raw, kind)
+		}
+		return ref, nil
+	}
+
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		switch {
+		case n <= -1000000000000:
+			ref.key = peerKey{peerChannel, -n - 1000000000000}
+		case n < 0:
+			ref.key = peerKey{peerChat, -n}
+		case n > 0:
+			ref.key = peerKey{peerUser, n}
+		default:
+			return ref, fmt.Errorf("allowed chat %q: invalid id", raw)
+		}
+		return ref, nil
+	}
+
+	if name, ok := strings.CutPrefix(s, "@"); ok {
+		if !usernamePattern.MatchString(name) {
+			return ref, fmt.Errorf("allowed chat %q: invalid username", raw)
+		}
+		ref.username = name
+		return ref, nil
+	}
+
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "http://")
+	host, path, _ := strings.Cut(s, "/")
+	switch strings.ToLower(strings.TrimPrefix(host, "www.")) {
+	case "t.me", "telegram.me", "telegram.dog":
+	default:
+		if usernamePattern.MatchString(raw) {
+			ref.username = raw
+			return ref, nil
+		}
+		return ref, fmt.Errorf("allowed chat %q: expected @username, t.me link or numeric id", raw)
+	}
+
+	path, _, _ = strings.Cut(path, "?")
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case strings.HasPrefix(parts[0], "+") && len(parts[0]) > 1:
+		ref.invite = parts[0][1:]
+	case parts[0] == "joinchat" && len(parts) > 1 && parts[1] != "":
+		ref.invite = parts[1]
+	case parts[0] == "c" && len(parts) > 1:
+		n, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || n <= 0 {
+			return ref, fmt.Errorf("allowed chat %q: invalid private channel link", raw)
+		}
+		ref.key = peerKey{peerChannel, n}
+	case parts[0] == "s" && len(parts) > 1 && usernamePattern.MatchString(parts[1]):
+		ref.username = parts[1]
+	case usernamePattern.MatchString(parts[0]):
+		ref.username = parts[0]
+	default:
+		return ref, fmt.Errorf("allowed chat %q: unsupported t.me link", raw)
+	}
+	return ref, nil
+}
+
+// allowList gates which peers the listener forwards. Typed keys come from
+// telegram.allowed_chats; legacy allowed_chat_ids match a bare ID of any peer
+// type. An empty configuration allows everything.
+type allowList struct {
+	mu         sync.RWMutex
+	configured bool
+	keys       map[peerKey]struct{}
+	ids        map[int64]struct{}
+}
+
+func newAllowList(ids []int64, refs []chatRef) *allowList {
+	a := &allowList{
+		configured: len(ids) > 0 || len(refs) > 0,
+		keys:       make(map[peerKey]struct{}),
+		ids:        make(map[int64]struct{}, len(ids)),
+	}
+	for _, id := range ids {
+		a.ids[id] = struct{}{}
+	}
+	for _, ref := range refs {
+		if ref.key.kind != 0 {
+			a.keys[ref.key] = struct{}{}
+		}
+	}
+	return a
+}
+
+func (a *allowList) add(key peerKey) {
+	a.mu.Lock()
+	a.keys[key] = struct{}{}
+	a.mu.Unlock()
+}
+
+func (a *allowList) allows(key peerKey) bool {
+	if !a.configured {
+		return true
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if _, ok := a.keys[key]; ok {
+		return true
+	}
+	_, ok := a.ids[key.id]
+	return ok
+}
+
+// resolveAllowed turns username and invite entries into peer keys and logs the
+// effective allowlist. Any entry that cannot be resolved fails startup rather
+// than silently narrowing what the bot listens to.
+func (l *Listener) resolveAllowed(ctx context.Context, api *tg.Client) error {
+	for _, ref := range l.chatRefs {
+		key := ref.key
+		if key.kind == 0 {
+			var err error
+			if key, err = l.resolveChat(ctx, api, ref); err != nil {
+				return err
+			}
+			l.allowed.add(key)
+		}
+		meta := l.peers.lookup(key)
+		l.logger.Info("allowed chat resolved", "entry", ref.raw, "peer", key.String(), "title", meta.title, "username", meta.username)
+	}
+	for _, id := range l.cfg.AllowedChatIDs {
+		l.logger.Info("allowed chat id", "id", id, "peer", "any")
+	}
+	return nil
+}
+
+func (l *Listener) resolveChat(ctx context.Context, api *tg.Client, ref chatRef) (peerKey, error) {
+	if ref.username != "" {
+		res, err := api.ContactsResolveUsername(ctx, &tg.ContactsResolveUsernameRequest{Username: ref.username})
+		if err != nil {
+			return peerKey{}, fmt.Errorf("resolve allowed chat %q: %w", ref.raw, err)
+		}
+		l.peers.remember(res.Chats, res.Users)
+		key, ok := keyOf(res.Peer)
+		if !ok {
+			return peerKey{}, fmt.Errorf("resolve allowed chat %q: unexpected peer %T", ref.raw, res.Peer)
+		}
+		return key, nil
+	}
+
+	invite, err := api.MessagesCheckChatInvite(ctx, ref.invite)
+	if err != nil {
+		return peerKey{}, fmt.Errorf("check invite for allowed chat %q: %w", ref.raw, err)
+	}
+	var chat tg.ChatClass
+	switch v := invite.(type) {
+	case *tg.ChatInviteAlready:
+		chat = v.Chat
+	case *tg.ChatInvitePeek:
+		chat = v.Chat
+	default:
+		return peerKey{}, fmt.Errorf("allowed chat %q: not a member yet; join it with this account first", ref.raw)
+	}
+	l.peers.remember([]tg.ChatClass{chat}, nil)
+	key, ok := keyOfChat(chat)
+	if !ok {
+		return peerKey{}, fmt.Errorf("check invite for allowed chat %q: unexpected chat %T", ref.raw, chat)
+	}
+	return key, nil
+}
@@ -0,0 +1,67 @@
+package telegram
+
+import "testing"
+
+func TestParseChatRef(t *testing.T) {
+	cases := []struct {
+		entry    string
+		username string
+		invite   string
+		key      peerKey
+	}{
+		{entry: "@PumpSignals", username: "PumpSignals"},
+		{entry: "https://t.me/PumpSignals", username: "PumpSignals"},
+		{entry: "t.me/s/PumpSignals?before=10", username: "PumpSignals"},
+		{entry: "PumpSignals", username: "PumpSignals"},
+		{entry: "https://t.me/+AbCdEf123", invite: "AbCdEf123"},
+		{entry: "t.me/joinchat/AbCdEf123", invite: "AbCdEf123"},
+		{entry: "https://t.me/c/1234567890/55", key: peerKey{peerChannel, 1234567890}},
+		{entry: "-1001234567890", key: peerKey{peerChannel, 1234567890}},
+		{entry: "-4567", key: peerKey{peerChat, 4567}},
+		{entry: "777", key: peerKey{peerUser, 777}},
+		{entry: "chat:4567", key: peerKey{peerChat, 4567}},
+	}
+	for _, tc := range cases {
+		ref, err := parseChatRef(tc.entry)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.entry, err)
+		}
+		if ref.username != tc.username || ref.invite != tc.invite || ref.key != tc.key {
+			t.Fatalf("%s: got %+v", tc.entry, ref)
+		}
+	}
+
+	for _, bad := range []string{"@ab", "https://example.com/PumpSignals", "group:12", "t.me/c/x", "0"} {
+		if _, err := parseChatRef(bad); err == nil {
+			t.Fatalf("%s: expected error", bad)
+		}
+	}
+}
+
+func TestAllowListIsTypeAware(t *testing.T) {
+	ref, _ := parseChatRef("-1001234567890")
+	list := newAllowList([]int64{42}, []chatRef{ref, {raw: "@PumpSignals", username: "PumpSignals"}})
+
+	if !list.allows(peerKey{peerChannel, 1234567890}) {
+		t.Fatalf("expected configured channel to be allowed")
+	}
+	if list.allows(peerKey{peerUser, 1234567890}) {
+		t.Fatalf("user sharing the channel ID must not be allowed")
+	}
+	if !list.allows(peerKey{peerChat, 42}) || !list.allows(peerKey{peerChannel, 42}) {
+		t.Fatalf("legacy allowed_chat_ids should match any peer type")
+	}
+
+	// Unresolved usernames keep the list restrictive until they resolve.
+	if list.allows(peerKey{peerChannel, 99}) {
+		t.Fatalf("unresolved entry must not open the allowlist")
+	}
+	list.add(peerKey{peerChannel, 99})
+	if !list.allows(peerKey{peerChannel, 99}) {
+		t.Fatalf("expected resolved username to be allowed")
+	}
+
+	if !newAllowList(nil, nil).allows(peerKey{peerUser, 1}) {
+		t.Fatalf("empty allowlist should allow everything")
+	}
+}
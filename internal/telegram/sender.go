@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+
+	"github.com/user/mexc-bot/internal/notify"
+)
+
+// maxFloodRetry is the longest flood wait Send sits out before retrying once.
+const maxFloodRetry = 30 * time.Second
+
+// Sender posts messages to one chat through a running Listener's session, so
+// notifications share the connection that ingests signals.
+type Sender struct {
+	listener *Listener
+	ref      chatRef
+	self     bool
+
+	mu   sync.Mutex
+	peer tg.InputPeerClass
+}
+
+var _ notify.Sender = (*Sender)(nil)
+
+// Sender returns a sender for chat: "me" for Saved Messages, or any form
+// accepted by telegram.allowed_chats. The chat is resolved on first use.
+func (l *Listener) Sender(chat string) (*Sender, error) {
+	s := &Sender{listener: l}
+	switch strings.ToLower(strings.TrimSpace(chat)) {
+	case "me", "self", "saved":
+		s.self = true
+		return s, nil
+	}
+	ref, err := parseChatRef(chat)
+	if err != nil {
+		return nil, err
+	}
+	s.ref = ref
+	return s, nil
+}
+
+// Send posts text without link previews. A short flood wait is sat out and
+// the message retried once.
+func (s *Sender) Send(ctx context.Context, text string) error {
+	api, self := s.listener.connection()
+	if api == nil {
+		return errors.New("telegram session is not connected")
+	}
+	peer, err := s.resolve(ctx, api, self)
+	if err != nil {
+		return err
+	}
+
+	var buf [8]byte
+	for attempt := 0; ; attempt++ {
+		if _, err := rand.Read(buf[:]); err != nil {
+			return err
+		}
+		_, err := api.MessagesSendMessage(ctx, &tg.MessagesSendMessageRequest{
+			Peer:      peer,
+			Message:   text,
+			NoWebpage: true,
+			RandomID:  int64(binary.LittleEndian.Uint64(buf[:])),
+		})
+		wait, flood := tgerr.AsFloodWait(err)
+		if !flood || attempt > 0 || wait > maxFloodRetry {
+			if err != nil {
+				return fmt.Errorf("send to %s: %w", s.describe(), err)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *Sender) resolve(ctx context.Context, api *tg.Client, self int64) (tg.InputPeerClass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.peer != nil {
+		return s.peer, nil
+	}
+	l := s.listener
+	if s.self {
+		s.peer = &tg.InputPeerSelf{}
+		l.markOwnChat(peerKey{peerUser, self})
+		return s.peer, nil
+	}
+
+	key := s.ref.key
+	if key.kind == 0 {
+		var err error
+		if key, err = l.resolveChat(ctx, api, s.ref); err != nil {
+			return nil, err
+		}
+	}
+	hash := l.peers.lookup(key).accessHash
+	if hash == 0 && key.kind == peerChannel {
+		// Channels seen only through updates have their hash in the state file.
+		if h, ok, err := l.state.GetChannelAccessHash(ctx, self, key.id); err == nil && ok {
+			hash = h
+		}
+	}
+	switch {
+	case key.kind == peerChat:
+		s.peer = &tg.InputPeerChat{ChatID: key.id}
+	case hash == 0:
+		return nil, fmt.Errorf("notify chat %q: access hash unknown; use its @username or invite link", s.ref.raw)
+	case key.kind == peerChannel:
+		s.peer = &tg.InputPeerChannel{ChannelID: key.id, AccessHash: hash}
+	default:
+		s.peer = &tg.InputPeerUser{UserID: key.id, AccessHash: hash}
+	}
+	l.markOwnChat(key)
+	return s.peer, nil
+}
+
+func (s *Sender) describe() string {
+	if s.self {
+		return "saved messages"
+	}
+	return s.ref.raw
+}
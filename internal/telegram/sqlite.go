@@ -0,0 +1,289 @@
+package telegram
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// sqliteMagic opens every SQLite 3 database file.
+const sqliteMagic = "SQLite format 3\x00"
+
+// sqliteDB is a minimal read-only SQLite 3 reader: enough to walk table
+// b-trees and decode records, which is all a Telethon .session needs. It does
+// not read the write-ahead log, so only checkpointed data is visible.
+type sqliteDB struct {
+	data     []byte
+	pageSize int
+	usable   int
+}
+
+// sqliteRow is one table row; columns hold nil, int64, float64, string or []byte.
+type sqliteRow struct {
+	rowID   int64
+	columns []any
+}
+
+func openSQLite(data []byte) (*sqliteDB, error) {
+	if len(data) < 100 || !bytes.HasPrefix(data, []byte(sqliteMagic)) {
+		return nil, errors.New("not an SQLite 3 database")
+	}
+	pageSize := int(binary.BigEndian.Uint16(data[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, fmt.Errorf("invalid sqlite page size %d", pageSize)
+	}
+	return &sqliteDB{data: data, pageSize: pageSize, usable: pageSize - int(data[20])}, nil
+}
+
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	start := int(n-1) * db.pageSize
+	if n == 0 || start+db.pageSize > len(db.data) {
+		return nil, fmt.Errorf("sqlite page %d out of range", n)
+	}
+	return db.data[start : start+db.pageSize], nil
+}
+
+// table returns all rows of the named table.
+func (db *sqliteDB) table(name string) ([]sqliteRow, error) {
+	master, err := db.scan(1)
+	if err != nil {
+		return nil, fmt.Errorf("read sqlite_master: %w", err)
+	}
+	for _, row := range master {
+		if len(row.columns) < 4 {
+			continue
+		}
+		typ, _ := row.columns[0].(string)
+		tbl, _ := row.columns[1].(string)
+		root, _ := row.columns[3].(int64)
+		if typ == "table" && tbl == name && root > 0 {
+			return db.scan(uint32(root))
+		}
+	}
+	return nil, fmt.Errorf("table %q not found", name)
+}
+
+// scan walks the table b-tree rooted at page root.
+func (db *sqliteDB) scan(root uint32) ([]sqliteRow, error) {
+	var rows []sqliteRow
+	// A well-formed b-tree reaches each page once; a corrupt one can link
+	// pages into a cycle.
+	visited := make(map[uint32]bool)
+	var walk func(n uint32, depth int) error
+	walk = func(n uint32, depth int) error {
+		if depth > 32 {
+			return errors.New("sqlite b-tree too deep")
+		}
+		if visited[n] {
+			return fmt.Errorf("sqlite page %d referenced twice", n)
+		}
+		visited[n] = true
+		pg, err := db.page(n)
+		if err != nil {
+			return err
+		}
+		hdr := 0
+		if n == 1 {
+			hdr = 100
+		}
+		if hdr+8 > len(pg) {
+			return fmt.Errorf("sqlite page %d truncated", n)
+		}
+		kind := pg[hdr]
+		cells := int(binary.BigEndian.Uint16(pg[hdr+3:]))
+		ptrs := hdr + 8
+		if kind == 0x05 {
+			ptrs = hdr + 12
+		}
+		if ptrs+2*cells > len(pg) {
+			return fmt.Errorf("sqlite page %d truncated", n)
+		}
+
+		switch kind {
+		case 0x05: // interior table page
+			for i := 0; i < cells; i++ {
+				off := int(binary.BigEndian.Uint16(pg[ptrs+2*i:]))
+				if off+4 > len(pg) {
+					return fmt.Errorf("sqlite page %d: bad cell offset", n)
+				}
+				if err := walk(binary.BigEndian.Uint32(pg[off:]), depth+1); err != nil {
+					return err
+				}
+			}
+			return walk(binary.BigEndian.Uint32(pg[hdr+8:]), depth+1)
+		case 0x0D: // leaf table page
+			for i := 0; i < cells; i++ {
+				off := int(binary.BigEndian.Uint16(pg[ptrs+2*i:]))
+				row, err := db.leafCell(pg, off)
+				if err != nil {
+					return fmt.Errorf("sqlite page %d cell %d: %w", n, i, err)
+				}
+				rows = append(rows, row)
+			}
+			return nil
+		default:
+			return fmt.Errorf("sqlite page %d: unexpected page type %#x", n, kind)
+		}
+	}
+	if err := walk(root, 0); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (db *sqliteDB) leafCell(pg []byte, off int) (sqliteRow, error) {
+	if off >= len(pg) {
+		return sqliteRow{}, errors.New("bad cell offset")
+	}
+	size, n := sqliteVarint(pg[off:])
+	if n == 0 {
+		return sqliteRow{}, errors.New("cell size truncated")
+	}
+	off += n
+	rowID, n := sqliteVarint(pg[off:])
+	if n == 0 {
+		return sqliteRow{}, errors.New("cell rowid truncated")
+	}
+	off += n
+	// A payload cannot be larger than the file holding it; checking before
+	// the int conversion keeps a corrupt size from going negative or
+	// driving a huge allocation.
+	if size > uint64(len(db.data)) {
+		return sqliteRow{}, fmt.Errorf("cell payload size %d exceeds database size", size)
+	}
+
+	payload, err := db.payload(pg, off, int(size))
+	if err != nil {
+		return sqliteRow{}, err
+	}
+	cols, err := sqliteRecord(payload)
+	if err != nil {
+		return sqliteRow{}, err
+	}
+	return sqliteRow{rowID: int64(rowID), columns: cols}, nil
+}
+
+// payload reassembles a cell payload, following overflow pages when it does
+// not fit on the leaf page.
+func (db *sqliteDB) payload(pg []byte, off, size int) ([]byte, error) {
+	maxLocal := db.usable - 35
+	local := size
+	if size > maxLocal {
+		minLocal := (db.usable-12)*32/255 - 23
+		local = minLocal + (size-minLocal)%(db.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if off+local > len(pg) {
+		return nil, errors.New("cell payload truncated")
+	}
+	out := make([]byte, 0, size)
+	out = append(out, pg[off:off+local]...)
+	if local == size {
+		return out, nil
+	}
+	if off+local+4 > len(pg) {
+		return nil, errors.New("overflow pointer truncated")
+	}
+	next := binary.BigEndian.Uint32(pg[off+local:])
+	for len(out) < size {
+		ov, err := db.page(next)
+		if err != nil {
+			return nil, fmt.Errorf("overflow: %w", err)
+		}
+		chunk := min(size-len(out), db.usable-4)
+		out = append(out, ov[4:4+chunk]...)
+		next = binary.BigEndian.Uint32(ov)
+	}
+	return out, nil
+}
+
+// sqliteRecord decodes a record: a header of serial types followed by values.
+func sqliteRecord(rec []byte) ([]any, error) {
+	// Compare in uint64: a corrupt varint converted to int can go negative.
+	hdrSize, n := sqliteVarint(rec)
+	if n == 0 || hdrSize < uint64(n) || hdrSize > uint64(len(rec)) {
+		return nil, errors.New("bad record header")
+	}
+	var types []uint64
+	for pos := n; pos < int(hdrSize); {
+		t, m := sqliteVarint(rec[pos:])
+		if m == 0 {
+			return nil, errors.New("bad serial type")
+		}
+		types = append(types, t)
+		pos += m
+	}
+
+	body := rec[hdrSize:]
+	cols := make([]any, 0, len(types))
+	for _, t := range types {
+		var width uint64
+		switch {
+		case t == 0 || t == 8 || t == 9:
+			width = 0
+		case t <= 4:
+			width = t
+		case t == 5:
+			width = 6
+		case t == 6 || t == 7:
+			width = 8
+		case t >= 12:
+			width = (t - 12) / 2
+		default:
+			return nil, fmt.Errorf("reserved serial type %d", t)
+		}
+		if width > uint64(len(body)) {
+			return nil, errors.New("record body truncated")
+		}
+		size := int(width)
+		v := body[:size]
+		body = body[size:]
+
+		switch {
+		case t == 0:
+			cols = append(cols, nil)
+		case t == 8:
+			cols = append(cols, int64(0))
+		case t == 9:
+			cols = append(cols, int64(1))
+		case t == 7:
+			cols = append(cols, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t <= 6:
+			var x int64
+			for _, b := range v {
+				x = x<<8 | int64(b)
+			}
+			// Sign-extend from the stored width.
+			shift := 64 - 8*uint(size)
+			cols = append(cols, x<<shift>>shift)
+		case t%2 == 0:
+			cols = append(cols, append([]byte(nil), v...))
+		default:
+			cols = append(cols, string(v))
+		}
+	}
+	return cols, nil
+}
+
+// sqliteVarint decodes a big-endian SQLite varint and returns its length, or 0
+// when buf is too short.
+func sqliteVarint(buf []byte) (uint64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(buf); i++ {
+		if i == 8 {
+			return v<<8 | uint64(buf[i]), 9
+		}
+		v = v<<7 | uint64(buf[i]&0x7f)
+		if buf[i]&0x80 == 0 {
+			return v, i + 1
+		}
+	}
+	return 0, 0
+}
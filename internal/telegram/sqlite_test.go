@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestSQLiteRejectsOversizedCell(t *testing.T) {
+	db := &sqliteDB{data: make([]byte, 4096), pageSize: 1024, usable: 1024}
+	for name, cell := range map[string][]byte{
+		// 9-byte varint with every bit set: negative once converted to int.
+		"negative":  {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"too large": {0x81, 0x80, 0x80, 0x00, 0x01},
+		"truncated": {0x81},
+	} {
+		pg := make([]byte, 1024)
+		copy(pg[100:], cell)
+		if name == "truncated" {
+			pg = pg[:101]
+		}
+		_, err := db.leafCell(pg, 100)
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		if name != "truncated" && !strings.Contains(err.Error(), "exceeds database size") {
+			t.Fatalf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestSQLiteRejectsMalformedRecords(t *testing.T) {
+	huge := []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	for name, rec := range map[string][]byte{
+		// Header size of 2^64-1: -1 once converted to int.
+		"header size": append(append([]byte(nil), huge...), 'a', 'b'),
+		// A 9-byte serial type whose body width overflows int.
+		"serial type": append(append([]byte{0x0a}, huge[:8]...), 0x7f, 'a', 'b', 'c'),
+		"short body":  {0x02, 0x19, 'h', 'i'},
+	} {
+		if _, err := sqliteRecord(rec); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+
+	cols, err := sqliteRecord([]byte{0x02, 0x11, 'h', 'i'})
+	if err != nil || len(cols) != 1 || cols[0] != "hi" {
+		t.Fatalf("expected [hi], got %v, %v", cols, err)
+	}
+}
+
+func TestSQLiteRejectsPageCycle(t *testing.T) {
+	db := &sqliteDB{data: make([]byte, 1024), pageSize: 512, usable: 512}
+	// Page 2 is an interior page whose right child is itself.
+	pg := db.data[512:]
+	pg[0] = 0x05
+	binary.BigEndian.PutUint32(pg[8:], 2)
+	if _, err := db.scan(2); err == nil || !strings.Contains(err.Error(), "referenced twice") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gotd/td/session"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/user/mexc-bot/internal/config"
+)
+
+// sessionMagic prefixes encrypted session files. The layout is
+// magic | scrypt salt (16) | GCM nonce (12) | ciphertext.
+const sessionMagic = "MXBSESS1"
+
+const (
+	sessionSaltSize = 16
+	scryptN         = 1 << 15
+	scryptR         = 8
+	scryptP         = 1
+)
+
+// SessionStorage is a gotd session.Storage kept at session_storage_path.
+// With a passphrase the file is sealed with AES-256-GCM under an scrypt key;
+// without one it is a plaintext gotd session, as FileStorage writes it.
+//
+// When the file does not exist yet the session is seeded from the
+// auth.telegram_session secret reference, which may hold either form. A
+// plaintext file found while a passphrase is configured is accepted and
+// encrypted on the next store, so existing deployments migrate in place.
+type SessionStorage struct {
+	path       string
+	seed       config.SecretRef
+	passphrase []byte
+
+	mu   sync.Mutex
+	salt []byte
+	key  []byte
+}
+
+// OpenSessionStorage builds the session storage described by the telegram and
+// auth sections, creating the session directory with owner-only permissions.
+func OpenSessionStorage(cfg config.TelegramConfig, auth config.AuthConfig) (*SessionStorage, error) {
+	var passphrase []byte
+	if auth.TelegramSessionPassphrase.Value != "" {
+		raw, err := auth.TelegramSessionPassphrase.Resolve()
+		if err != nil {
+			return nil, fmt.Errorf("resolve telegram_session_passphrase: %w", err)
+		}
+		if passphrase = bytes.TrimSpace(raw); len(passphrase) == 0 {
+			return nil, errors.New("telegram_session_passphrase resolved to an empty value")
+		}
+	} else if auth.TelegramSession.Value != "" {
+		return nil, errors.New("telegram_session requires telegram_session_passphrase")
+	}
+	return NewSessionStorage(cfg.SessionStoragePath, auth.TelegramSession, passphrase)
+}
+
+// NewSessionStorage returns storage for path, seeded from seed when the file
+// is missing and encrypted when passphrase is non-empty.
+func NewSessionStorage(path string, seed config.SecretRef, passphrase []byte) (*SessionStorage, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, errors.New("telegram session_storage_path must be provided")
+	}
+	path = filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("prepare session directory: %w", err)
+	}
+	return &SessionStorage{path: path, seed: seed, passphrase: passphrase}, nil
+}
+
+// Path reports the session file location.
+func (s *SessionStorage) Path() string { return s.path }
+
+// Encrypted reports whether stored sessions are sealed with a passphrase.
+func (s *SessionStorage) Encrypted() bool { return len(s.passphrase) > 0 }
+
+// LoadSession implements session.Storage.
+func (s *SessionStorage) LoadSession(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(s.path)
+	switch {
+	case err == nil:
+	case errors.Is(err, os.ErrNotExist):
+		if s.seed.Value == "" {
+			return nil, session.ErrNotFound
+		}
+		if data, err = s.seed.Resolve(); err != nil {
+			return nil, fmt.Errorf("resolve telegram_session: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("read session: %w", err)
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, session.ErrNotFound
+	}
+	if !bytes.HasPrefix(data, []byte(sessionMagic)) {
+		return data, nil
+	}
+	return s.open(data)
+}
+
+// StoreSession implements session.Storage. The file is replaced atomically
+// and always has 0600 permissions.
+func (s *SessionStorage) StoreSession(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Encrypted() {
+		sealed, err := s.seal(data)
+		if err != nil {
+			return err
+		}
+		data = sealed
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("restrict session file permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write session: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("write session: %w", err)
+	}
+	return nil
+}
+
+// open decrypts an encrypted session and keeps its key for later stores, so
+// scrypt runs once per process rather than on every salt update.
+func (s *SessionStorage) open(data []byte) ([]byte, error) {
+	if !s.Encrypted() {
+		return nil, errors.New("session is encrypted; set auth.telegram_session_passphrase")
+	}
+	body := data[len(sessionMagic):]
+	if len(body) < sessionSaltSize {
+		return nil, errors.New("encrypted session truncated")
+	}
+	salt := body[:sessionSaltSize]
+	key := s.key
+	if !bytes.Equal(salt, s.salt) {
+		var err error
+		if key, err = deriveSessionKey(s.passphrase, salt); err != nil {
+			return nil, err
+		}
+	}
+	aead, err := newSessionAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	body = body[sessionSaltSize:]
+	if len(body) < aead.NonceSize() {
+		return nil, errors.New("encrypted session truncated")
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], []byte(sessionMagic))
+	if err != nil {
+		return nil, errors.New("decrypt session: wrong passphrase or corrupted file")
+	}
+	s.salt, s.key = append([]byte(nil), salt...), key
+	return plain, nil
+}
+
+func (s *SessionStorage) seal(plain []byte) ([]byte, error) {
+	if s.key == nil {
+		salt := make([]byte, sessionSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, fmt.Errorf("generate session salt: %w", err)
+		}
+		key, err := deriveSessionKey(s.passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+		s.salt, s.key = salt, key
+	}
+	aead, err := newSessionAEAD(s.key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate session nonce: %w", err)
+	}
+	out := make([]byte, 0, len(sessionMagic)+len(s.salt)+len(nonce)+len(plain)+aead.Overhead())
+	out = append(out, sessionMagic...)
+	out = append(out, s.salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, []byte(sessionMagic)), nil
+}
+
+func deriveSessionKey(passphrase, salt []byte) ([]byte, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, fmt.Errorf("derive session key: %w", err)
+	}
+	return key, nil
+}
+
+func newSessionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("session cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
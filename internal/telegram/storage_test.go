@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gotd/td/session"
+
+	"github.com/user/mexc-bot/internal/config"
+)
+
+func TestSessionStorageEncrypts(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "telegram.session")
+	storage, err := NewSessionStorage(path, config.SecretRef{}, []byte("correct horse"))
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	if _, err := storage.LoadSession(ctx); err != session.ErrNotFound {
+		t.Fatalf("expected ErrNotFound for a missing session, got %v", err)
+	}
+
+	plain := []byte(`{"Version":1,"Data":{"DC":2}}`)
+	if err := storage.StoreSession(ctx, plain); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.HasPrefix(raw, []byte(sessionMagic)) || bytes.Contains(raw, []byte(`"DC"`)) {
+		t.Fatalf("session file is not encrypted: %q", raw)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 session file, got %v", info.Mode().Perm())
+	}
+
+	reopened, _ := NewSessionStorage(path, config.SecretRef{}, []byte("correct horse"))
+	got, err := reopened.LoadSession(ctx)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("load = %q, %v", got, err)
+	}
+
+	wrong, _ := NewSessionStorage(path, config.SecretRef{}, []byte("wrong"))
+	if _, err := wrong.LoadSession(ctx); err == nil {
+		t.Fatalf("expected wrong passphrase to fail")
+	}
+	unkeyed, _ := NewSessionStorage(path, config.SecretRef{}, nil)
+	if _, err := unkeyed.LoadSession(ctx); err == nil {
+		t.Fatalf("expected encrypted session without passphrase to fail")
+	}
+}
+
+func TestSessionStorageSeedsFromSecret(t *testing.T) {
+	ctx := context.Background()
+	plain := []byte(`{"Version":1,"Data":{"DC":4}}`)
+	t.Setenv("TEST_TELEGRAM_SESSION", string(plain))
+
+	path := filepath.Join(t.TempDir(), "telegram.session")
+	storage, err := NewSessionStorage(path, config.SecretRef{Value: "env:TEST_TELEGRAM_SESSION"}, []byte("pass"))
+	if err != nil {
+		t.Fatalf("storage: %v", err)
+	}
+	got, err := storage.LoadSession(ctx)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("seed load = %q, %v", got, err)
+	}
+
+	// Once written back, the encrypted file wins over the seed.
+	updated := []byte(`{"Version":1,"Data":{"DC":5}}`)
+	if err := storage.StoreSession(ctx, updated); err != nil {
+		t.Fatalf("store: %v", err)
+	}
+	got, err = storage.LoadSession(ctx)
+	if err != nil || !bytes.Equal(got, updated) {
+		t.Fatalf("load after store = %q, %v", got, err)
+	}
+}
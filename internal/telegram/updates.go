@@ -0,0 +1,318 @@
+package telegram
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketUpdateState   = []byte("state")
+	bucketChannelPts    = []byte("channel_pts")
+	bucketChannelHashes = []byte("channel_hashes")
+)
+
+// updateStore persists gotd's update state (pts, qts, seq, date, per-channel
+// pts and channel access hashes) in a bbolt file next to the session, so a
+// restart resumes from the last applied update and getDifference replays the
+// posts published while the bot was down.
+type updateStore struct {
+	db *bolt.DB
+}
+
+var (
+	_ updates.StateStorage        = (*updateStore)(nil)
+	_ updates.ChannelAccessHasher = (*updateStore)(nil)
+)
+
+func openUpdateStore(path string) (*updateStore, error) {
+	path = filepath.Clean(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("prepare update state directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open update state %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketUpdateState, bucketChannelPts, bucketChannelHashes} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initialise update state buckets: %w", err)
+	}
+	return &updateStore{db: db}, nil
+}
+
+func (s *updateStore) Close() error {
+	return s.db.Close()
+}
+
+func idKey(id int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+func (s *updateStore) GetState(ctx context.Context, userID int64) (state updates.State, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(bucketUpdateState).Get(idKey(userID))
+		if raw == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(raw, &state)
+	})
+	return state, found, err
+}
+
+// SetState replaces the common state and, as a fresh state invalidates them,
+// forgets the per-channel pts.
+func (s *updateStore) SetState(ctx context.Context, userID int64, state updates.State) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketUpdateState).Put(idKey(userID), raw); err != nil {
+			return err
+		}
+		channels := tx.Bucket(bucketChannelPts)
+		if channels.Bucket(idKey(userID)) != nil {
+			if err := channels.DeleteBucket(idKey(userID)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// modify applies fn to the stored state of userID.
+func (s *updateStore) modify(userID int64, fn func(*updates.State)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketUpdateState)
+		raw := b.Get(idKey(userID))
+		if raw == nil {
+			return errors.New("update state not found")
+		}
+		var state updates.State
+		if err := json.Unmarshal(raw, &state); err != nil {
+			return err
+		}
+		fn(&state)
+		out, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		return b.Put(idKey(userID), out)
+	})
+}
+
+func (s *updateStore) SetPts(ctx context.Context, userID int64, pts int) error {
+	return s.modify(userID, func(st *updates.State) { st.Pts = pts })
+}
+
+func (s *updateStore) SetQts(ctx context.Context, userID int64, qts int) error {
+	return s.modify(userID, func(st *updates.State) { st.Qts = qts })
+}
+
+func (s *updateStore) SetDate(ctx context.Context, userID int64, date int) error {
+	return s.modify(userID, func(st *updates.State) { st.Date = date })
+}
+
+func (s *updateStore) SetSeq(ctx context.Context, userID int64, seq int) error {
+	return s.modify(userID, func(st *updates.State) { st.Seq = seq })
+}
+
+func (s *updateStore) SetDateSeq(ctx context.Context, userID int64, date, seq int) error {
+	return s.modify(userID, func(st *updates.State) { st.Date, st.Seq = date, seq })
+}
+
+func (s *updateStore) GetChannelPts(ctx context.Context, userID, channelID int64) (pts int, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketChannelPts).Bucket(idKey(userID))
+		if b == nil {
+			return nil
+		}
+		if raw := b.Get(idKey(channelID)); len(raw) == 8 {
+			pts, found = int(binary.BigEndian.Uint64(raw)), true
+		}
+		return nil
+	})
+	return pts, found, err
+}
+
+func (s *updateStore) SetChannelPts(ctx context.Context, userID, channelID int64, pts int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(bucketChannelPts).CreateBucketIfNotExists(idKey(userID))
+		if err != nil {
+			return err
+		}
+		return b.Put(idKey(channelID), idKey(int64(pts)))
+	})
+}
+
+func (s *updateStore) ForEachChannels(ctx context.Context, userID int64, f func(ctx context.Context, channelID int64, pts int) error) error {
+	type channel struct {
+		id  int64
+		pts int
+	}
+	var channels []channel
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketChannelPts).Bucket(idKey(userID))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if len(k) == 8 && len(v) == 8 {
+				channels = append(channels, channel{int64(binary.BigEndian.Uint64(k)), int(binary.BigEndian.Uint64(v))})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	// f may call back into the store, so it runs outside the read transaction.
+	for _, ch := range channels {
+		if err := f(ctx, ch.id, ch.pts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *updateStore) SetChannelAccessHash(ctx context.Context, userID, channelID, accessHash int64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(bucketChannelHashes).CreateBucketIfNotExists(idKey(userID))
+		if err != nil {
+			return err
+		}
+		return b.Put(idKey(channelID), idKey(accessHash))
+	})
+}
+
+func (s *updateStore) GetChannelAccessHash(ctx context.Context, userID, channelID int64) (accessHash int64, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketChannelHashes).Bucket(idKey(userID))
+		if b == nil {
+			return nil
+		}
+		if raw := b.Get(idKey(channelID)); len(raw) == 8 {
+			accessHash, found = int64(binary.BigEndian.Uint64(raw)), true
+		}
+		return nil
+	})
+	return accessHash, found, err
+}
+
+// recoveryTTL bounds how long a message fetched by getDifference stays marked
+// if it never reaches consumeMessage (e.g. filtered by the allowlist).
+const recoveryTTL = 5 * time.Minute
+
+type recoveredKey struct {
+	peer peerKey
+	id   int
+}
+
+// recoveryTracker remembers which messages arrived through getDifference or
+// getChannelDifference, since the updates manager hands them to the handler
+// exactly like live updates.
+type recoveryTracker struct {
+	mu   sync.Mutex
+	seen map[recoveredKey]time.Time
+}
+
+func newRecoveryTracker() *recoveryTracker {
+	return &recoveryTracker{seen: make(map[recoveredKey]time.Time)}
+}
+
+func (t *recoveryTracker) mark(msgs []tg.MessageClass, others []tg.UpdateClass) {
+	for _, u := range others {
+		switch u := u.(type) {
+		case *tg.UpdateNewMessage:
+			msgs = append(msgs, u.Message)
+		case *tg.UpdateNewChannelMessage:
+			msgs = append(msgs, u.Message)
+		case *tg.UpdateEditMessage:
+			msgs = append(msgs, u.Message)
+		case *tg.UpdateEditChannelMessage:
+			msgs = append(msgs, u.Message)
+		}
+	}
+	if len(msgs) == 0 {
+		return
+	}
+
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for k, at := range t.seen {
+		if now.Sub(at) > recoveryTTL {
+			delete(t.seen, k)
+		}
+	}
+	for _, msg := range msgs {
+		m, ok := msg.(*tg.Message)
+		if !ok {
+			continue
+		}
+		if key, ok := keyOf(m.PeerID); ok {
+			t.seen[recoveredKey{key, m.ID}] = now
+		}
+	}
+}
+
+// take reports whether the message was recovered and forgets it.
+func (t *recoveryTracker) take(peer peerKey, id int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	k := recoveredKey{peer, id}
+	if _, ok := t.seen[k]; !ok {
+		return false
+	}
+	delete(t.seen, k)
+	return true
+}
+
+// recoveringAPI is the API the updates manager recovers gaps through; it
+// records every message a difference returns before the manager dispatches it.
+type recoveringAPI struct {
+	updates.API
+	tracker *recoveryTracker
+}
+
+func (a recoveringAPI) UpdatesGetDifference(ctx context.Context, req *tg.UpdatesGetDifferenceRequest) (tg.UpdatesDifferenceClass, error) {
+	diff, err := a.API.UpdatesGetDifference(ctx, req)
+	switch d := diff.(type) {
+	case *tg.UpdatesDifference:
+		a.tracker.mark(d.NewMessages, d.OtherUpdates)
+	case *tg.UpdatesDifferenceSlice:
+		a.tracker.mark(d.NewMessages, d.OtherUpdates)
+	}
+	return diff, err
+}
+
+func (a recoveringAPI) UpdatesGetChannelDifference(ctx context.Context, req *tg.UpdatesGetChannelDifferenceRequest) (tg.UpdatesChannelDifferenceClass, error) {
+	diff, err := a.API.UpdatesGetChannelDifference(ctx, req)
+	switch d := diff.(type) {
+	case *tg.UpdatesChannelDifference:
+		a.tracker.mark(d.NewMessages, d.OtherUpdates)
+	case *tg.UpdatesChannelDifferenceTooLong:
+		a.tracker.mark(d.Messages, nil)
+	}
+	return diff, err
+}
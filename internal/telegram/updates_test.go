@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+)
+
+func TestUpdateStorePersists(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "telegram.session.updates")
+	store, err := openUpdateStore(path)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+
+	if _, found, err := store.GetState(ctx, 7); err != nil || found {
+		t.Fatalf("expected no state, found=%v err=%v", found, err)
+	}
+	if err := store.SetPts(ctx, 7, 1); err == nil {
+		t.Fatalf("expected SetPts without state to fail")
+	}
+	if err := store.SetState(ctx, 7, updates.State{Pts: 10, Qts: 2, Date: 100, Seq: 5}); err != nil {
+		t.Fatalf("set state: %v", err)
+	}
+	if err := store.SetPts(ctx, 7, 11); err != nil {
+		t.Fatalf("set pts: %v", err)
+	}
+	if err := store.SetDateSeq(ctx, 7, 200, 6); err != nil {
+		t.Fatalf("set date/seq: %v", err)
+	}
+	if err := store.SetChannelPts(ctx, 7, 1001, 55); err != nil {
+		t.Fatalf("set channel pts: %v", err)
+	}
+	if err := store.SetChannelAccessHash(ctx, 7, 1001, -99); err != nil {
+		t.Fatalf("set access hash: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	store, err = openUpdateStore(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer store.Close()
+
+	state, found, err := store.GetState(ctx, 7)
+	if err != nil || !found || state != (updates.State{Pts: 11, Qts: 2, Date: 200, Seq: 6}) {
+		t.Fatalf("unexpected state %+v found=%v err=%v", state, found, err)
+	}
+	var channels []int64
+	err = store.ForEachChannels(ctx, 7, func(ctx context.Context, channelID int64, pts int) error {
+		if pts != 55 {
+			t.Fatalf("unexpected pts %d", pts)
+		}
+		channels = append(channels, channelID)
+		return nil
+	})
+	if err != nil || len(channels) != 1 || channels[0] != 1001 {
+		t.Fatalf("unexpected channels %v err=%v", channels, err)
+	}
+	if hash, found, _ := store.GetChannelAccessHash(ctx, 7, 1001); !found || hash != -99 {
+		t.Fatalf("unexpected access hash %d found=%v", hash, found)
+	}
+
+	// A fresh common state invalidates channel pts.
+	if err := store.SetState(ctx, 7, updates.State{Pts: 1}); err != nil {
+		t.Fatalf("reset state: %v", err)
+	}
+	if _, found, _ := store.GetChannelPts(ctx, 7, 1001); found {
+		t.Fatalf("expected channel pts cleared by SetState")
+	}
+}
+
+type diffAPI struct {
+	updates.API
+	channelDiff tg.UpdatesChannelDifferenceClass
+}
+
+func (d diffAPI) UpdatesGetChannelDifference(ctx context.Context, req *tg.UpdatesGetChannelDifferenceRequest) (tg.UpdatesChannelDifferenceClass, error) {
+	return d.channelDiff, nil
+}
+
+func TestRecoveringAPIMarksDifferenceMessages(t *testing.T) {
+	tracker := newRecoveryTracker()
+	api := recoveringAPI{
+		API: diffAPI{channelDiff: &tg.UpdatesChannelDifference{
+			NewMessages: []tg.MessageClass{&tg.Message{ID: 5, PeerID: &tg.PeerChannel{ChannelID: 1001}}},
+			OtherUpdates: []tg.UpdateClass{&tg.UpdateEditChannelMessage{
+				Message: &tg.Message{ID: 4, PeerID: &tg.PeerChannel{ChannelID: 1001}},
+			}},
+		}},
+		tracker: tracker,
+	}
+	if _, err := api.UpdatesGetChannelDifference(context.Background(), &tg.UpdatesGetChannelDifferenceRequest{}); err != nil {
+		t.Fatalf("channel difference: %v", err)
+	}
+
+	channel := peerKey{peerChannel, 1001}
+	if !tracker.take(channel, 5) || !tracker.take(channel, 4) {
+		t.Fatalf("expected difference messages marked as recovered")
+	}
+	if tracker.take(channel, 5) {
+		t.Fatalf("expected recovered mark consumed once")
+	}
+	if tracker.take(peerKey{peerUser, 1001}, 6) {
+		t.Fatalf("unexpected recovered mark for a live message")
+	}
+}